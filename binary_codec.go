@@ -1,6 +1,7 @@
 package ttml
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
 	"errors"
@@ -13,10 +14,39 @@ const (
 	// AMLX 二进制头与版本号。
 	amlxMagic        = "AMLX"
 	amlxVersion byte = 0x01
+	// amlxVersionLayout 用于设置了布局标记（globalFlagLayoutMask）的文件。
+	// 只认识 0x01 的旧解码器会直接丢弃 global_flags，版本号不同才能让它们报错而不是误解析。
+	amlxVersionLayout byte = 0x02
 	// 以 uint64 正数区间作为时间上限，避免后续运算溢出。
 	maxBinaryTimeMS = uint64(^uint64(0) >> 1)
 )
 
+const (
+	// 全局标记位（global_flags）。
+	// TimeScale：global_flags 之后紧跟 time_scale varint，所有时间字段以 1/time_scale 毫秒为单位。
+	globalFlagTimeScale uint8 = 1 << iota
+	// IndexFooter：文件末尾附带 amlxIndexFooterSize 字节的段偏移索引。
+	globalFlagIndexFooter
+	// CompactWords：词记录的 duration 左移一位，最低位表示其后是否有 word_flags 字节，
+	// 无标记的词省去该字节。
+	globalFlagCompactWords
+	// Untimed：歌词没有任何时间，行记录与词记录均省去时间字段，解码时全部还原为 0；
+	// 词记录固定带 word_flags 字节，因此不得与 CompactWords 同时设置。
+	globalFlagUntimed
+	// 改变已有段布局的标记，只能出现在 amlxVersionLayout 文件中；其余标记旧解码器可安全忽略。
+	globalFlagLayoutMask = globalFlagTimeScale | globalFlagCompactWords | globalFlagUntimed
+)
+
+// amlxIndexFooterSize 为索引尾部长度：header、string pool、lyric data 三个段的起始偏移，各为 uint64 小端。
+const amlxIndexFooterSize = 3 * 8
+
+// amlxIndex 记录各段相对文件开头的字节偏移；header 偏移指向 HeaderSize varint。
+type amlxIndex struct {
+	header     uint64
+	stringPool uint64
+	lyricData  uint64
+}
+
 const (
 	// 行级标记位（bit flags）。
 	lineFlagIsBG uint8 = 1 << iota
@@ -24,8 +54,13 @@ const (
 	lineFlagIgnoreSync
 	lineFlagHasTranslatedLyric
 	lineFlagHasRomanLyric
+	// 行级紧凑文本：整行只有一个覆盖行时间且无附加属性的词，直接存文本 ID。
+	lineFlagLineText
+	// 行角色：和声 / 即兴（ad-lib）。两位互斥，均为 0 表示主唱（或 IsBG 时的背景）。
+	lineFlagRoleHarmony
+	lineFlagRoleAdLib
 	// 已定义的合法行标记掩码。
-	lineFlagMask = lineFlagIsBG | lineFlagIsDuet | lineFlagIgnoreSync | lineFlagHasTranslatedLyric | lineFlagHasRomanLyric
+	lineFlagMask = lineFlagIsBG | lineFlagIsDuet | lineFlagIgnoreSync | lineFlagHasTranslatedLyric | lineFlagHasRomanLyric | lineFlagLineText | lineFlagRoleHarmony | lineFlagRoleAdLib
 )
 
 const (
@@ -34,35 +69,62 @@ const (
 	wordFlagHasEmptyBeat
 	wordFlagHasRomanWord
 	wordFlagRomanWarning
+	wordFlagHasPhoneme
+	// 行内单个背景（耳语/和声）词。
+	wordFlagBackground
+	// 有意留空的计时词（如间奏标记）。
+	wordFlagInstrumentalMarker
 	// 已定义的合法词标记掩码。
-	wordFlagMask = wordFlagObscene | wordFlagHasEmptyBeat | wordFlagHasRomanWord | wordFlagRomanWarning
+	wordFlagMask = wordFlagObscene | wordFlagHasEmptyBeat | wordFlagHasRomanWord | wordFlagRomanWarning | wordFlagHasPhoneme | wordFlagBackground | wordFlagInstrumentalMarker
 )
 
 // stringPoolBuilder 用于构建字符串池，并为字符串分配稳定 ID。
 type stringPoolBuilder struct {
 	values []string
 	index  map[string]uint64
+	// counts 记录每个字符串被引用的次数，仅供 BuildStringPool 统计使用。
+	counts map[string]int
+	// noDedup 为 true 时每次 add 都分配新 ID，
+	// occurrences 按顺序记录各字符串尚未被 get 取走的 ID。
+	noDedup     bool
+	occurrences map[string][]uint64
 }
 
 func newStringPoolBuilder() *stringPoolBuilder {
 	return &stringPoolBuilder{
-		values: []string{},
-		index:  map[string]uint64{},
+		values:      []string{},
+		index:       map[string]uint64{},
+		counts:      map[string]int{},
+		occurrences: map[string][]uint64{},
 	}
 }
 
 func (sp *stringPoolBuilder) add(value string) uint64 {
+	sp.counts[value]++
 	// 已存在则复用 ID，保证字符串去重。
-	if idx, ok := sp.index[value]; ok {
+	if idx, ok := sp.index[value]; ok && !sp.noDedup {
 		return idx
 	}
 	idx := uint64(len(sp.values))
 	sp.values = append(sp.values, value)
-	sp.index[value] = idx
+	if _, ok := sp.index[value]; !ok {
+		sp.index[value] = idx
+	}
+	if sp.noDedup {
+		sp.occurrences[value] = append(sp.occurrences[value], idx)
+	}
 	return idx
 }
 
+// get 返回 value 的 ID。不去重时依次返回各次出现的 ID，
+// 取完后重复返回最后一个。
 func (sp *stringPoolBuilder) get(value string) (uint64, bool) {
+	if ids := sp.occurrences[value]; len(ids) > 0 {
+		if len(ids) > 1 {
+			sp.occurrences[value] = ids[1:]
+		}
+		return ids[0], true
+	}
 	idx, ok := sp.index[value]
 	return idx, ok
 }
@@ -85,10 +147,70 @@ func BinaryToTTML(binaryData []byte, pretty bool) (string, error) {
 	return ExportTTMLText(lyric, pretty), nil
 }
 
+// BinaryToLRC 将 AMLX 二进制转换为逐行 LRC 文本，输出与 ExportLRCText 一致。
+func BinaryToLRC(binaryData []byte) (string, error) {
+	lyric, err := DecodeBinary(binaryData)
+	if err != nil {
+		return "", err
+	}
+	return ExportLRCText(lyric), nil
+}
+
+// TimeRounding 选择编码时将时间取整为整数刻度的方式。
+type TimeRounding uint8
+
+const (
+	// TimeRoundingNearest 四舍五入（.5 远离零），为默认值。
+	TimeRoundingNearest TimeRounding = iota
+	// TimeRoundingFloor 向下取整，保证时间不会晚于原值。
+	TimeRoundingFloor
+	// TimeRoundingCeil 向上取整，保证时间不会早于原值。
+	TimeRoundingCeil
+)
+
+// EncodeOptions 控制 AMLX 编码行为。
+type EncodeOptions struct {
+	// CompactLineText 对逐行歌词启用紧凑编码：
+	// 若某行仅有一个词、词时间与行时间一致且没有任何词级属性，
+	// 则设置 LineText 行标记，只写入文本 ID，省去完整的词记录。
+	CompactLineText bool
+	// TimeScale 为每毫秒的时间刻度数，用于保存亚毫秒精度（如 10 表示 0.1ms）。
+	// 0 或 1 表示整数毫秒（默认），此时不设置 TimeScale 全局标记，输出与旧版一致。
+	TimeScale uint64
+	// IndexFooter 在文件末尾追加段偏移索引并设置 IndexFooter 全局标记，
+	// 便于随机访问与仅元数据解码直接定位各段。
+	IndexFooter bool
+	// CompactWords 设置 CompactWords 全局标记，使没有任何词级标记的词省去 word_flags 字节，
+	// 对逐字歌词通常每词节省约 1 字节。
+	CompactWords bool
+	// TimeRounding 选择所有时间字段（行/词时间与空拍）的取整方式，默认四舍五入。
+	TimeRounding TimeRounding
+	// DisablePoolDedup 关闭字符串池去重：每次引用都写入一条新字符串，
+	// string_id 按文件中的引用顺序递增（元数据在前，随后逐行为各词与翻译、音译）。
+	// 文件更大，仅用于排查线格式问题；解码结果不变。
+	DisablePoolDedup bool
+	// Untimed 设置 Untimed 全局标记，省去所有行与词的时间字段，明确记录歌词为纯文本而非零时长。
+	// 要求所有行与词的起止时间均为 0，否则编码失败；设置后忽略 CompactWords。
+	// 这是布局标记，文件以版本 0x02 写出，只认识 0x01 的解码器会拒绝读取。
+	Untimed bool
+}
+
 // EncodeBinary 将结构化歌词编码为 AMLX 二进制。
 func EncodeBinary(ttmlLyric TTMLLyric) ([]byte, error) {
+	return EncodeBinaryWithOptions(ttmlLyric, EncodeOptions{})
+}
+
+// EncodeBinaryWithOptions 按 opts 将结构化歌词编码为 AMLX 二进制。
+func EncodeBinaryWithOptions(ttmlLyric TTMLLyric, opts EncodeOptions) ([]byte, error) {
+	if opts.Untimed {
+		if !isUntimedLyric(ttmlLyric.LyricLines) {
+			return nil, errors.New("untimed encoding requires every line and word time to be 0")
+		}
+		opts.CompactWords = false
+	}
+
 	// 先构建全局字符串池，后续段落通过 ID 引用字符串，减少体积。
-	stringPool := buildStringPool(ttmlLyric)
+	stringPool := buildStringPool(ttmlLyric, opts.DisablePoolDedup)
 
 	headerSection, err := encodeHeaderSection(ttmlLyric.Metadata, stringPool)
 	if err != nil {
@@ -97,77 +219,393 @@ func EncodeBinary(ttmlLyric TTMLLyric) ([]byte, error) {
 
 	stringPoolSection := encodeStringPoolSection(stringPool.values)
 
-	lyricDataSection, err := encodeLyricDataSection(ttmlLyric.LyricLines, stringPool)
+	lyricDataSection, err := encodeLyricDataSection(ttmlLyric.LyricLines, stringPool, opts)
 	if err != nil {
 		return nil, err
 	}
 
+	var globalFlags uint8
+	if opts.TimeScale > 1 {
+		globalFlags |= globalFlagTimeScale
+	}
+	if opts.IndexFooter {
+		globalFlags |= globalFlagIndexFooter
+	}
+	if opts.CompactWords {
+		globalFlags |= globalFlagCompactWords
+	}
+	if opts.Untimed {
+		globalFlags |= globalFlagUntimed
+	}
+
+	version := amlxVersion
+	if globalFlags&globalFlagLayoutMask != 0 {
+		version = amlxVersionLayout
+	}
+
 	var out bytes.Buffer
 	out.WriteString(amlxMagic)
-	out.WriteByte(amlxVersion)
-	out.WriteByte(0) // GlobalFlags（v1 暂未使用）
+	out.WriteByte(version)
+	out.WriteByte(globalFlags)
+	if globalFlags&globalFlagTimeScale != 0 {
+		writeUvarint(&out, opts.TimeScale)
+	}
+	var index amlxIndex
+	index.header = uint64(out.Len())
 	writeUvarint(&out, uint64(headerSection.Len()))
 	out.Write(headerSection.Bytes())
+	index.stringPool = uint64(out.Len())
 	out.Write(stringPoolSection.Bytes())
+	index.lyricData = uint64(out.Len())
 	out.Write(lyricDataSection.Bytes())
+	if opts.IndexFooter {
+		// 索引位于已知段之后，不识别该标记的旧解码器读完歌词段即停止，不受影响。
+		var footer [amlxIndexFooterSize]byte
+		binary.LittleEndian.PutUint64(footer[0:], index.header)
+		binary.LittleEndian.PutUint64(footer[8:], index.stringPool)
+		binary.LittleEndian.PutUint64(footer[16:], index.lyricData)
+		out.Write(footer[:])
+	}
 
 	return out.Bytes(), nil
 }
 
+// DecodeOptions 控制 AMLX 解码行为。
+type DecodeOptions struct {
+	// LegacyNoGlobalFlags 用于读取早期原型产出的 AMLX：
+	// 这类文件在 version 之后没有 global_flags 字节，header_size 紧跟 version。
+	LegacyNoGlobalFlags bool
+	// StrictTiming 拒绝词时间超出所在行时间范围的数据。
+	// 编码器始终保证该约束，违反通常意味着文件损坏或被篡改；默认宽松。
+	StrictTiming bool
+}
+
 // DecodeBinary 将 AMLX 二进制解码为结构化歌词。
 func DecodeBinary(binaryData []byte) (TTMLLyric, error) {
-	reader := bytes.NewReader(binaryData)
+	return DecodeBinaryWithOptions(binaryData, DecodeOptions{})
+}
+
+// DecodeBinaryWithOptions 按 opts 将 AMLX 二进制解码为结构化歌词。
+func DecodeBinaryWithOptions(binaryData []byte, opts DecodeOptions) (TTMLLyric, error) {
+	return decodeBinary(bytes.NewReader(binaryData), opts, &decodeBuffers{}, nil)
+}
+
+// DecodeBinaryInto 将 AMLX 二进制解码到 dst，结果与 DecodeBinary 一致。
+// dst 被完整覆盖（各切片长度先重置为 0），但行、词、元数据及其取值切片的底层数组在容量足够时被复用，
+// 因此 dst 先前的内容（包括从中取出的子切片）在调用后不再有效。出错时 dst 为空歌词。
+func DecodeBinaryInto(binaryData []byte, dst *TTMLLyric) error {
+	return decodeBinaryInto(bytes.NewReader(binaryData), DecodeOptions{}, &decodeBuffers{}, nil, dst)
+}
+
+// BinaryInfo 描述 AMLX 容器本身（而非歌词内容）的信息。
+type BinaryInfo struct {
+	Version     byte
+	GlobalFlags byte
+	// TimeScale 为每毫秒刻度数；未设置 TimeScale 全局标记时为 1。
+	TimeScale uint64
+	// HeaderSize、StringPoolSize、LyricDataSize 为各段字节数，
+	// 不含 header_size 本身与索引尾部。
+	HeaderSize     uint64
+	StringPoolSize uint64
+	LyricDataSize  uint64
+}
+
+// DecodeBinaryInfo 解码 AMLX，并同时返回容器的版本、全局标记与各段大小。
+// 解码失败时 BinaryInfo 仍包含出错前已读到的字段，
+// 例如版本不受支持时 Version 为文件中的版本号，便于迁移旧文件。
+func DecodeBinaryInfo(binaryData []byte) (TTMLLyric, BinaryInfo, error) {
+	var info BinaryInfo
+	lyric, err := decodeBinary(bytes.NewReader(binaryData), DecodeOptions{}, &decodeBuffers{}, &info)
+	return lyric, info, err
+}
+
+// Decoder 在多次解码之间复用读取器与临时缓冲，适合循环解码大量文件。
+// 零值即可使用；输出与 DecodeBinaryWithOptions 完全一致。Decoder 不是并发安全的。
+type Decoder struct {
+	// Options 为每次 Decode 使用的解码选项。
+	Options DecodeOptions
+
+	reader bytes.Reader
+	bufs   decodeBuffers
+}
+
+// Decode 解码一份 AMLX 数据，并保留内部缓冲容量供下次调用复用。
+func (d *Decoder) Decode(binaryData []byte) (TTMLLyric, error) {
+	d.reader.Reset(binaryData)
+	lyric, err := decodeBinary(&d.reader, d.Options, &d.bufs, nil)
+	// 不持有调用方数据，避免延长其生命周期。
+	d.reader.Reset(nil)
+	return lyric, err
+}
+
+// DecodeInto 与 Decode 相同，但把结果写入 dst，并复用 dst 中行、词与元数据切片的容量。
+func (d *Decoder) DecodeInto(binaryData []byte, dst *TTMLLyric) error {
+	d.reader.Reset(binaryData)
+	err := decodeBinaryInto(&d.reader, d.Options, &d.bufs, nil, dst)
+	d.reader.Reset(nil)
+	return err
+}
+
+// decodeBuffers 为解码期间的临时缓冲；解码结果不引用其中任何内存，因此可跨调用复用。
+type decodeBuffers struct {
+	header []byte
+	str    []byte
+	pool   []string
+}
+
+// DecodeBinaryAt 从 io.ReaderAt（如 mmap 的文件）的前 size 字节解码 AMLX，
+// 按需顺序读取而不是先把整个文件载入内存。各段都要解码，因此不按索引尾部跳转，
+// 只在读到末尾时校验其偏移。
+func DecodeBinaryAt(r io.ReaderAt, size int64) (TTMLLyric, error) {
+	if size < 0 {
+		return TTMLLyric{}, fmt.Errorf("invalid size: %d", size)
+	}
+	return decodeBinary(newReaderAtSource(r, size), DecodeOptions{}, &decodeBuffers{}, nil)
+}
+
+// amlxReader 是解码器所需的最小读取接口；Len 返回剩余字节数，用于在分配前校验长度。
+type amlxReader interface {
+	io.Reader
+	io.ByteReader
+	Len() int
+}
+
+// readerAtSource 通过带缓冲的 io.SectionReader 把 io.ReaderAt 适配为 amlxReader。
+type readerAtSource struct {
+	buf       *bufio.Reader
+	remaining int64
+}
+
+func newReaderAtSource(r io.ReaderAt, size int64) *readerAtSource {
+	return &readerAtSource{
+		buf:       bufio.NewReader(io.NewSectionReader(r, 0, size)),
+		remaining: size,
+	}
+}
+
+func (s *readerAtSource) Read(p []byte) (int, error) {
+	n, err := s.buf.Read(p)
+	s.remaining -= int64(n)
+	return n, err
+}
+
+func (s *readerAtSource) ReadByte() (byte, error) {
+	b, err := s.buf.ReadByte()
+	if err == nil {
+		s.remaining--
+	}
+	return b, err
+}
+
+func (s *readerAtSource) Len() int {
+	if maxInt := int64(^uint(0) >> 1); s.remaining > maxInt {
+		return int(maxInt)
+	}
+	return int(s.remaining)
+}
+
+// amlxPrefix 为固定头中解析出的全局信息。
+type amlxPrefix struct {
+	version     uint8
+	globalFlags uint8
+	timeScale   uint64
+}
+
+// decodePrefix 读取 magic、version、global_flags 及其附带字段，停在 HeaderSize 之前。
+func decodePrefix(reader amlxReader, opts DecodeOptions) (amlxPrefix, error) {
+	prefix := amlxPrefix{timeScale: 1}
 
 	// 读取并校验 magic，防止误解码非 AMLX 数据。
 	magic := make([]byte, len(amlxMagic))
 	if _, err := io.ReadFull(reader, magic); err != nil {
-		return TTMLLyric{}, fmt.Errorf("read magic: %w", err)
+		return prefix, fmt.Errorf("read magic: %w", err)
 	}
 	if string(magic) != amlxMagic {
-		return TTMLLyric{}, fmt.Errorf("invalid magic: %q", string(magic))
+		return prefix, fmt.Errorf("invalid magic: %q", string(magic))
 	}
 
 	version, err := reader.ReadByte()
 	if err != nil {
-		return TTMLLyric{}, fmt.Errorf("read version: %w", err)
+		return prefix, fmt.Errorf("read version: %w", err)
+	}
+	prefix.version = version
+	if version != amlxVersion && version != amlxVersionLayout {
+		return prefix, fmt.Errorf("unsupported version: %d", version)
+	}
+
+	if opts.LegacyNoGlobalFlags {
+		if version != amlxVersion {
+			return prefix, fmt.Errorf("legacy files without global flags must be version %d", amlxVersion)
+		}
+		return prefix, nil
+	}
+	prefix.globalFlags, err = reader.ReadByte()
+	if err != nil {
+		return prefix, fmt.Errorf("read global flags: %w", err)
+	}
+	// 未知标记按规范忽略：同一版本内新增的标记只能在已知段之后追加数据。
+	if version == amlxVersion && prefix.globalFlags&globalFlagLayoutMask != 0 {
+		return prefix, fmt.Errorf("global flags 0x%02x require version %d", prefix.globalFlags&globalFlagLayoutMask, amlxVersionLayout)
+	}
+	if prefix.globalFlags&globalFlagUntimed != 0 && prefix.globalFlags&globalFlagCompactWords != 0 {
+		return prefix, errors.New("untimed and compact words global flags are both set")
+	}
+	if prefix.globalFlags&globalFlagTimeScale != 0 {
+		prefix.timeScale, err = readUvarint(reader)
+		if err != nil {
+			return prefix, fmt.Errorf("read time scale: %w", err)
+		}
+		if prefix.timeScale == 0 {
+			return prefix, errors.New("time scale must be > 0")
+		}
+	}
+	return prefix, nil
+}
+
+// decodeBinary 从任意 amlxReader 顺序解码 AMLX；若带索引尾部，则校验其偏移与实际段位置一致。
+// info 非 nil 时随解码进度填入容器信息。
+func decodeBinary(reader amlxReader, opts DecodeOptions, bufs *decodeBuffers, info *BinaryInfo) (TTMLLyric, error) {
+	var lyric TTMLLyric
+	if err := decodeBinaryInto(reader, opts, bufs, info, &lyric); err != nil {
+		return TTMLLyric{}, err
 	}
-	if version != amlxVersion {
-		return TTMLLyric{}, fmt.Errorf("unsupported version: %d", version)
+	return lyric, nil
+}
+
+// decodeBinaryInto 与 decodeBinary 相同，但把结果写入 dst 并复用其切片底层数组。
+// 出错时 dst 被清空为零长度，仅保留容量。
+func decodeBinaryInto(reader amlxReader, opts DecodeOptions, bufs *decodeBuffers, info *BinaryInfo, dst *TTMLLyric) (err error) {
+	reuseMetadata, reuseLines := dst.Metadata, dst.LyricLines
+	*dst = TTMLLyric{Metadata: reuseMetadata[:0], LyricLines: reuseLines[:0]}
+	defer func() {
+		if err != nil {
+			*dst = TTMLLyric{Metadata: reuseMetadata[:0], LyricLines: reuseLines[:0]}
+		}
+	}()
+	if info == nil {
+		info = &BinaryInfo{}
 	}
+	total := uint64(reader.Len())
+	offset := func() uint64 { return total - uint64(reader.Len()) }
 
-	if _, err := reader.ReadByte(); err != nil {
-		return TTMLLyric{}, fmt.Errorf("read global flags: %w", err)
+	prefix, err := decodePrefix(reader, opts)
+	info.Version, info.GlobalFlags, info.TimeScale = prefix.version, prefix.globalFlags, prefix.timeScale
+	if err != nil {
+		return err
 	}
 
+	var actual amlxIndex
+	actual.header = offset()
 	// header 长度在主流中紧随固定头，先读出再单独解析。
 	headerSize, err := readUvarint(reader)
 	if err != nil {
-		return TTMLLyric{}, fmt.Errorf("read header size: %w", err)
+		return fmt.Errorf("read header size: %w", err)
 	}
-	headerBytes, err := readBytes(reader, headerSize, "header section")
+	info.HeaderSize = headerSize
+	bufs.header, err = readBytesInto(reader, bufs.header, headerSize, "header section")
 	if err != nil {
-		return TTMLLyric{}, err
+		return err
 	}
+	headerBytes := bufs.header
 
-	stringPool, err := decodeStringPoolSection(reader)
+	actual.stringPool = offset()
+	stringPool, err := decodeStringPoolSection(reader, bufs)
 	if err != nil {
-		return TTMLLyric{}, err
+		return err
 	}
+	info.StringPoolSize = offset() - actual.stringPool
 
-	metadata, err := decodeHeaderSection(headerBytes, stringPool)
+	metadata, err := decodeHeaderSection(headerBytes, stringPool, reuseMetadata)
 	if err != nil {
-		return TTMLLyric{}, err
+		return err
 	}
 
-	lines, err := decodeLyricDataSection(reader, stringPool)
+	actual.lyricData = offset()
+	lines, err := decodeLyricDataSection(reader, stringPool, prefix, opts, reuseLines)
 	if err != nil {
-		return TTMLLyric{}, err
+		return err
 	}
+	info.LyricDataSize = offset() - actual.lyricData
 
-	return TTMLLyric{
-		Metadata:   metadata,
-		LyricLines: lines,
-	}, nil
+	if prefix.globalFlags&globalFlagIndexFooter != 0 {
+		if reader.Len() != amlxIndexFooterSize {
+			return fmt.Errorf("index footer: expected %d trailing bytes, got %d", amlxIndexFooterSize, reader.Len())
+		}
+		footer, err := readBytes(reader, amlxIndexFooterSize, "index footer")
+		if err != nil {
+			return err
+		}
+		if index := parseIndexFooter(footer); index != actual {
+			return fmt.Errorf("index footer offsets %+v do not match sections %+v", index, actual)
+		}
+	}
+
+	dst.Metadata, dst.LyricLines = metadata, lines
+	return nil
+}
+
+// DecodeMetadataOnly 只解码元数据，跳过歌词段。
+// 文件带索引尾部时先校验其偏移：header 偏移须等于固定头之后的实际位置，头部段须恰好结束于
+// 字符串池偏移，字符串池须恰好填满到歌词段偏移；校验通过后按偏移截取字符串池段。
+// 否则顺序读到字符串池结束为止。
+func DecodeMetadataOnly(binaryData []byte) ([]TTMLMetadata, error) {
+	reader := bytes.NewReader(binaryData)
+	offset := func() uint64 { return uint64(len(binaryData) - reader.Len()) }
+	prefix, err := decodePrefix(reader, DecodeOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var index amlxIndex
+	hasIndex := prefix.globalFlags&globalFlagIndexFooter != 0
+	if hasIndex {
+		if len(binaryData) < amlxIndexFooterSize {
+			return nil, errors.New("index footer: file too short")
+		}
+		footerStart := uint64(len(binaryData) - amlxIndexFooterSize)
+		index = parseIndexFooter(binaryData[footerStart:])
+		if index.header != offset() {
+			return nil, fmt.Errorf("index footer header offset %d does not match section at %d", index.header, offset())
+		}
+		if index.stringPool > index.lyricData || index.lyricData > footerStart {
+			return nil, fmt.Errorf("index footer offsets out of range: %+v", index)
+		}
+	}
+
+	headerSize, err := readUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("read header size: %w", err)
+	}
+	headerBytes, err := readBytes(reader, headerSize, "header section")
+	if err != nil {
+		return nil, err
+	}
+
+	poolReader := reader
+	if hasIndex {
+		if index.stringPool != offset() {
+			return nil, fmt.Errorf("index footer string pool offset %d does not match section at %d", index.stringPool, offset())
+		}
+		poolReader = bytes.NewReader(binaryData[index.stringPool:index.lyricData])
+	}
+
+	stringPool, err := decodeStringPoolSection(poolReader, &decodeBuffers{})
+	if err != nil {
+		return nil, err
+	}
+	if hasIndex && poolReader.Len() != 0 {
+		return nil, fmt.Errorf("index footer lyric data offset %d does not match section at %d", index.lyricData, index.lyricData-uint64(poolReader.Len()))
+	}
+	return decodeHeaderSection(headerBytes, stringPool, nil)
+}
+
+// parseIndexFooter 解析 amlxIndexFooterSize 字节的索引尾部。
+func parseIndexFooter(footer []byte) amlxIndex {
+	return amlxIndex{
+		header:     binary.LittleEndian.Uint64(footer[0:]),
+		stringPool: binary.LittleEndian.Uint64(footer[8:]),
+		lyricData:  binary.LittleEndian.Uint64(footer[16:]),
+	}
 }
 
 // EncodeAMLX 是 EncodeBinary 的别名。
@@ -180,9 +618,18 @@ func DecodeAMLX(binaryData []byte) (TTMLLyric, error) {
 	return DecodeBinary(binaryData)
 }
 
-// buildStringPool 遍历元数据与歌词正文，收集所有可复用字符串。
-func buildStringPool(ttmlLyric TTMLLyric) *stringPoolBuilder {
+// BuildStringPool 返回 AMLX 编码该歌词时写入的去重字符串（按 string_id 顺序）
+// 以及每个字符串的引用次数，便于外部工具统计字符串复用情况。
+func BuildStringPool(lyric TTMLLyric) ([]string, map[string]int) {
+	pool := buildStringPool(lyric, false)
+	return pool.values, pool.counts
+}
+
+// buildStringPool 遍历元数据与歌词正文，收集所有可复用字符串；
+// noDedup 为 true 时保留每次出现。
+func buildStringPool(ttmlLyric TTMLLyric, noDedup bool) *stringPoolBuilder {
 	pool := newStringPoolBuilder() // 字符串池
+	pool.noDedup = noDedup
 
 	for _, meta := range ttmlLyric.Metadata {
 		pool.add(meta.Key)
@@ -192,17 +639,29 @@ func buildStringPool(ttmlLyric TTMLLyric) *stringPoolBuilder {
 	}
 
 	for _, line := range ttmlLyric.LyricLines {
-		if line.TranslatedLyric != "" {
-			pool.add(line.TranslatedLyric)
+		addLineTexts := func() {
+			if line.TranslatedLyric != "" {
+				pool.add(line.TranslatedLyric)
+			}
+			if line.RomanLyric != "" {
+				pool.add(line.RomanLyric)
+			}
 		}
-		if line.RomanLyric != "" {
-			pool.add(line.RomanLyric)
+		// 不去重时按歌词段中的引用顺序（先词、后行级文本）分配 ID。
+		if !noDedup {
+			addLineTexts()
 		}
 		for _, word := range line.Words {
 			pool.add(word.Word)
 			if word.RomanWord != "" {
 				pool.add(word.RomanWord)
 			}
+			if word.Phoneme != "" {
+				pool.add(word.Phoneme)
+			}
+		}
+		if noDedup {
+			addLineTexts()
 		}
 	}
 
@@ -253,16 +712,25 @@ func encodeStringPoolSection(values []string) *bytes.Buffer {
 }
 
 // encodeLyricDataSection 编码歌词段，包含行信息与逐词时间/文本信息。
-func encodeLyricDataSection(lines []LyricLine, stringPool *stringPoolBuilder) (*bytes.Buffer, error) {
+func encodeLyricDataSection(lines []LyricLine, stringPool *stringPoolBuilder, opts EncodeOptions) (*bytes.Buffer, error) {
 	var section bytes.Buffer
 	writeUvarint(&section, uint64(len(lines)))
 
+	// 时间字段按 TimeScale 放大后再取整，默认 1 即整数毫秒。
+	scale := float64(1)
+	if opts.TimeScale > 1 {
+		scale = float64(opts.TimeScale)
+	}
+	toTicks := func(value float64, field string) (uint64, error) {
+		return toMilliseconds(value*scale, opts.TimeRounding, field)
+	}
+
 	for lineIndex, line := range lines {
-		lineStartMS, err := toMilliseconds(line.StartTime, fmt.Sprintf("line[%d].start_time", lineIndex))
+		lineStartMS, err := toTicks(line.StartTime, fmt.Sprintf("line[%d].start_time", lineIndex))
 		if err != nil {
 			return nil, err
 		}
-		lineEndMS, err := toMilliseconds(line.EndTime, fmt.Sprintf("line[%d].end_time", lineIndex))
+		lineEndMS, err := toTicks(line.EndTime, fmt.Sprintf("line[%d].end_time", lineIndex))
 		if err != nil {
 			return nil, err
 		}
@@ -273,18 +741,20 @@ func encodeLyricDataSection(lines []LyricLine, stringPool *stringPoolBuilder) (*
 			hasEmptyBeat bool
 			emptyBeatMS  uint64
 			hasRomanWord bool
+			hasPhoneme   bool
 			textID       uint64
 			romanID      uint64
+			phonemeID    uint64
 			wordFlags    uint8
 		}
 		encodedWords := make([]encodedWord, 0, len(line.Words))
 
 		for wordIndex, word := range line.Words {
-			wordStartMS, err := toMilliseconds(word.StartTime, fmt.Sprintf("line[%d].word[%d].start_time", lineIndex, wordIndex))
+			wordStartMS, err := toTicks(word.StartTime, fmt.Sprintf("line[%d].word[%d].start_time", lineIndex, wordIndex))
 			if err != nil {
 				return nil, err
 			}
-			wordEndMS, err := toMilliseconds(word.EndTime, fmt.Sprintf("line[%d].word[%d].end_time", lineIndex, wordIndex))
+			wordEndMS, err := toTicks(word.EndTime, fmt.Sprintf("line[%d].word[%d].end_time", lineIndex, wordIndex))
 			if err != nil {
 				return nil, err
 			}
@@ -318,11 +788,22 @@ func encodeLyricDataSection(lines []LyricLine, stringPool *stringPoolBuilder) (*
 				}
 			}
 
+			hasPhoneme := word.Phoneme != ""
+
+			var phonemeID uint64
+			if hasPhoneme {
+				var ok bool
+				phonemeID, ok = stringPool.get(word.Phoneme)
+				if !ok {
+					return nil, fmt.Errorf("line[%d].word[%d].phoneme missing from string pool", lineIndex, wordIndex)
+				}
+			}
+
 			hasEmptyBeat := false
 			emptyBeatMS := uint64(0)
 			// 仅接受有限且大于 0 的 emptyBeat。
 			if !math.IsNaN(word.EmptyBeat) && !math.IsInf(word.EmptyBeat, 0) && word.EmptyBeat > 0 {
-				parsedEmptyBeatMS, err := toMilliseconds(word.EmptyBeat, fmt.Sprintf("line[%d].word[%d].empty_beat", lineIndex, wordIndex))
+				parsedEmptyBeatMS, err := toTicks(word.EmptyBeat, fmt.Sprintf("line[%d].word[%d].empty_beat", lineIndex, wordIndex))
 				if err != nil {
 					return nil, err
 				}
@@ -345,6 +826,15 @@ func encodeLyricDataSection(lines []LyricLine, stringPool *stringPoolBuilder) (*
 			if word.RomanWarning {
 				wordFlags |= wordFlagRomanWarning
 			}
+			if hasPhoneme {
+				wordFlags |= wordFlagHasPhoneme
+			}
+			if word.Background {
+				wordFlags |= wordFlagBackground
+			}
+			if word.InstrumentalMarker {
+				wordFlags |= wordFlagInstrumentalMarker
+			}
 
 			encodedWords = append(encodedWords, encodedWord{
 				startMS:      wordStartMS,
@@ -352,8 +842,10 @@ func encodeLyricDataSection(lines []LyricLine, stringPool *stringPoolBuilder) (*
 				hasEmptyBeat: hasEmptyBeat,
 				emptyBeatMS:  emptyBeatMS,
 				hasRomanWord: hasRomanWord,
+				hasPhoneme:   hasPhoneme,
 				textID:       textID,
 				romanID:      romanID,
+				phonemeID:    phonemeID,
 				wordFlags:    wordFlags,
 			})
 		}
@@ -361,12 +853,17 @@ func encodeLyricDataSection(lines []LyricLine, stringPool *stringPoolBuilder) (*
 			lineEndMS = lineStartMS
 		}
 
-		writeUvarint(&section, lineStartMS)
-		writeUvarint(&section, lineEndMS)
+		if !opts.Untimed {
+			writeUvarint(&section, lineStartMS)
+			writeUvarint(&section, lineEndMS)
+		}
 
 		hasTranslatedLyric := line.TranslatedLyric != ""
 		hasRomanLyric := line.RomanLyric != ""
 
+		if problem := line.roleProblem(); problem != "" {
+			return nil, fmt.Errorf("line[%d] %s", lineIndex, problem)
+		}
 		var lineFlags uint8
 		if line.IsBG {
 			lineFlags |= lineFlagIsBG
@@ -377,15 +874,36 @@ func encodeLyricDataSection(lines []LyricLine, stringPool *stringPoolBuilder) (*
 		if line.IgnoreSync {
 			lineFlags |= lineFlagIgnoreSync
 		}
+		switch line.Role {
+		case LineRoleHarmony:
+			lineFlags |= lineFlagRoleHarmony
+		case LineRoleAdLib:
+			lineFlags |= lineFlagRoleAdLib
+		}
 		if hasTranslatedLyric {
 			lineFlags |= lineFlagHasTranslatedLyric
 		}
 		if hasRomanLyric {
 			lineFlags |= lineFlagHasRomanLyric
 		}
+
+		lineText := opts.CompactLineText &&
+			len(encodedWords) == 1 &&
+			encodedWords[0].wordFlags == 0 &&
+			encodedWords[0].startMS == lineStartMS &&
+			encodedWords[0].endMS == lineEndMS
+		if lineText {
+			lineFlags |= lineFlagLineText
+		}
 		section.WriteByte(lineFlags)
 
-		writeUvarint(&section, uint64(len(line.Words)))
+		if lineText {
+			// 紧凑模式下以文本 ID 取代 word_count，行内不再写词记录。
+			writeUvarint(&section, encodedWords[0].textID)
+			encodedWords = encodedWords[:0]
+		} else {
+			writeUvarint(&section, uint64(len(line.Words)))
+		}
 
 		if hasTranslatedLyric {
 			translatedID, ok := stringPool.get(line.TranslatedLyric)
@@ -409,10 +927,27 @@ func encodeLyricDataSection(lines []LyricLine, stringPool *stringPoolBuilder) (*
 			deltaStart := word.startMS - lineStartMS
 			duration := word.endMS - word.startMS
 
-			writeUvarint(&section, deltaStart)
-			writeUvarint(&section, duration)
-			writeUvarint(&section, word.textID)
-			section.WriteByte(word.wordFlags)
+			if opts.Untimed {
+				writeUvarint(&section, word.textID)
+				section.WriteByte(word.wordFlags)
+			} else if opts.CompactWords {
+				writeUvarint(&section, deltaStart)
+				// 最低位标记其后是否有 word_flags；duration 不超过 maxBinaryTimeMS，左移不会溢出。
+				packed := duration << 1
+				if word.wordFlags != 0 {
+					packed |= 1
+				}
+				writeUvarint(&section, packed)
+				writeUvarint(&section, word.textID)
+				if word.wordFlags != 0 {
+					section.WriteByte(word.wordFlags)
+				}
+			} else {
+				writeUvarint(&section, deltaStart)
+				writeUvarint(&section, duration)
+				writeUvarint(&section, word.textID)
+				section.WriteByte(word.wordFlags)
+			}
 
 			if word.hasRomanWord {
 				writeUvarint(&section, word.romanID)
@@ -421,6 +956,10 @@ func encodeLyricDataSection(lines []LyricLine, stringPool *stringPoolBuilder) (*
 			if word.hasEmptyBeat {
 				writeUvarint(&section, word.emptyBeatMS)
 			}
+
+			if word.hasPhoneme {
+				writeUvarint(&section, word.phonemeID)
+			}
 		}
 	}
 
@@ -428,7 +967,8 @@ func encodeLyricDataSection(lines []LyricLine, stringPool *stringPoolBuilder) (*
 }
 
 // decodeStringPoolSection 解码字符串池段。
-func decodeStringPoolSection(reader *bytes.Reader) ([]string, error) {
+// bufs 的字符串切片与字节缓冲会被复用，返回的切片在下次使用 bufs 前有效。
+func decodeStringPoolSection(reader amlxReader, bufs *decodeBuffers) ([]string, error) {
 	stringCountU64, err := readUvarint(reader)
 	if err != nil {
 		return nil, fmt.Errorf("read string_count: %w", err)
@@ -438,24 +978,37 @@ func decodeStringPoolSection(reader *bytes.Reader) ([]string, error) {
 		return nil, err
 	}
 
-	stringPool := make([]string, 0, stringCount)
+	stringPool := bufs.pool[:0]
+	if cap(stringPool) < stringCount {
+		stringPool = make([]string, 0, stringCount)
+	}
 	for i := 0; i < stringCount; i++ {
 		lengthU64, err := readUvarint(reader)
 		if err != nil {
 			return nil, fmt.Errorf("read string[%d].length: %w", i, err)
 		}
-		raw, err := readBytes(reader, lengthU64, fmt.Sprintf("string[%d].bytes", i))
-		if err != nil {
-			return nil, err
+		// 仅在出错时格式化字段名，避免每个字符串一次 Sprintf 分配。
+		if lengthU64 > uint64(reader.Len()) {
+			return nil, fmt.Errorf("string[%d].bytes exceeds remaining bytes", i)
+		}
+		n := int(lengthU64)
+		if cap(bufs.str) < n {
+			bufs.str = make([]byte, n)
+		}
+		raw := bufs.str[:n]
+		if _, err := io.ReadFull(reader, raw); err != nil {
+			return nil, fmt.Errorf("read string[%d].bytes: %w", i, err)
 		}
 		stringPool = append(stringPool, string(raw))
 	}
+	bufs.pool = stringPool
 
 	return stringPool, nil
 }
 
 // decodeHeaderSection 解码头部段，并检查是否存在尾随垃圾字节。
-func decodeHeaderSection(header []byte, stringPool []string) ([]TTMLMetadata, error) {
+// reuse 的底层数组（含各项 Value）容量足够时被复用，可为 nil。
+func decodeHeaderSection(header []byte, stringPool []string, reuse []TTMLMetadata) ([]TTMLMetadata, error) {
 	reader := bytes.NewReader(header)
 
 	metadataCountU64, err := readUvarint(reader)
@@ -467,7 +1020,11 @@ func decodeHeaderSection(header []byte, stringPool []string) ([]TTMLMetadata, er
 		return nil, err
 	}
 
-	metadata := make([]TTMLMetadata, 0, metadataCount)
+	reuse = reuse[:cap(reuse)]
+	metadata := reuse[:0]
+	if metadata == nil || cap(metadata) < metadataCount {
+		metadata = make([]TTMLMetadata, 0, metadataCount)
+	}
 	for metaIndex := 0; metaIndex < metadataCount; metaIndex++ {
 		keyID, err := readUvarint(reader)
 		if err != nil {
@@ -487,7 +1044,12 @@ func decodeHeaderSection(header []byte, stringPool []string) ([]TTMLMetadata, er
 			return nil, err
 		}
 
-		values := make([]string, 0, valueCount)
+		var values []string
+		if metaIndex < len(reuse) && reuse[metaIndex].Value != nil && cap(reuse[metaIndex].Value) >= valueCount {
+			values = reuse[metaIndex].Value[:0]
+		} else {
+			values = make([]string, 0, valueCount)
+		}
 		for valueIndex := 0; valueIndex < valueCount; valueIndex++ {
 			valueID, err := readUvarint(reader)
 			if err != nil {
@@ -520,7 +1082,8 @@ func decodeHeaderSection(header []byte, stringPool []string) ([]TTMLMetadata, er
 }
 
 // decodeLyricDataSection 解码歌词段，并按标记位恢复可选字段。
-func decodeLyricDataSection(reader *bytes.Reader, stringPool []string) ([]LyricLine, error) {
+// reuse 的底层数组（含各行 Words）容量足够时被复用，可为 nil。
+func decodeLyricDataSection(reader amlxReader, stringPool []string, prefix amlxPrefix, opts DecodeOptions, reuse []LyricLine) ([]LyricLine, error) {
 	lineCountU64, err := readUvarint(reader)
 	if err != nil {
 		return nil, fmt.Errorf("read line_count: %w", err)
@@ -530,25 +1093,35 @@ func decodeLyricDataSection(reader *bytes.Reader, stringPool []string) ([]LyricL
 		return nil, err
 	}
 
-	lines := make([]LyricLine, 0, lineCount)
+	scale := float64(prefix.timeScale)
+	compactWords := prefix.globalFlags&globalFlagCompactWords != 0
+	untimed := prefix.globalFlags&globalFlagUntimed != 0
+	reuse = reuse[:cap(reuse)]
+	lines := reuse[:0]
+	if lines == nil || cap(lines) < lineCount {
+		lines = make([]LyricLine, 0, lineCount)
+	}
 	for lineIndex := 0; lineIndex < lineCount; lineIndex++ {
-		lineStartMS, err := readUvarint(reader)
-		if err != nil {
-			return nil, fmt.Errorf("read line[%d].start_time: %w", lineIndex, err)
-		}
-		if lineStartMS > maxBinaryTimeMS {
-			return nil, fmt.Errorf("line[%d].start_time overflow", lineIndex)
-		}
+		var lineStartMS, lineEndMS uint64
+		if !untimed {
+			lineStartMS, err = readUvarint(reader)
+			if err != nil {
+				return nil, fmt.Errorf("read line[%d].start_time: %w", lineIndex, err)
+			}
+			if lineStartMS > maxBinaryTimeMS {
+				return nil, fmt.Errorf("line[%d].start_time overflow", lineIndex)
+			}
 
-		lineEndMS, err := readUvarint(reader)
-		if err != nil {
-			return nil, fmt.Errorf("read line[%d].end_time: %w", lineIndex, err)
-		}
-		if lineEndMS > maxBinaryTimeMS {
-			return nil, fmt.Errorf("line[%d].end_time overflow", lineIndex)
-		}
-		if lineEndMS < lineStartMS {
-			return nil, fmt.Errorf("line[%d] end_time < start_time", lineIndex)
+			lineEndMS, err = readUvarint(reader)
+			if err != nil {
+				return nil, fmt.Errorf("read line[%d].end_time: %w", lineIndex, err)
+			}
+			if lineEndMS > maxBinaryTimeMS {
+				return nil, fmt.Errorf("line[%d].end_time overflow", lineIndex)
+			}
+			if lineEndMS < lineStartMS {
+				return nil, fmt.Errorf("line[%d] end_time < start_time", lineIndex)
+			}
 		}
 
 		lineFlags, err := reader.ReadByte()
@@ -559,23 +1132,55 @@ func decodeLyricDataSection(reader *bytes.Reader, stringPool []string) ([]LyricL
 			// 显式拒绝未知保留位，防止把未来版本数据静默当作当前格式解析。
 			return nil, fmt.Errorf("line[%d] reserved line flags are set: 0x%02x", lineIndex, lineFlags&^lineFlagMask)
 		}
-
-		wordCountU64, err := readUvarint(reader)
-		if err != nil {
-			return nil, fmt.Errorf("read line[%d].word_count: %w", lineIndex, err)
+		if lineFlags&lineFlagRoleHarmony != 0 && lineFlags&lineFlagRoleAdLib != 0 {
+			return nil, fmt.Errorf("line[%d] both harmony and ad-lib role flags are set", lineIndex)
 		}
-		wordCount, err := toInt(wordCountU64, fmt.Sprintf("line[%d].word_count", lineIndex))
-		if err != nil {
-			return nil, err
+		if lineFlags&lineFlagIsBG != 0 && lineFlags&(lineFlagRoleHarmony|lineFlagRoleAdLib) != 0 {
+			return nil, fmt.Errorf("line[%d] background line has a harmony or ad-lib role flag", lineIndex)
+		}
+
+		wordCount := 0
+		lineText := ""
+		if lineFlags&lineFlagLineText != 0 {
+			textID, err := readUvarint(reader)
+			if err != nil {
+				return nil, fmt.Errorf("read line[%d].line_text_string_id: %w", lineIndex, err)
+			}
+			lineText, err = stringByID(stringPool, textID, fmt.Sprintf("line[%d].line_text_string_id", lineIndex))
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			wordCountU64, err := readUvarint(reader)
+			if err != nil {
+				return nil, fmt.Errorf("read line[%d].word_count: %w", lineIndex, err)
+			}
+			wordCount, err = toInt(wordCountU64, fmt.Sprintf("line[%d].word_count", lineIndex))
+			if err != nil {
+				return nil, err
+			}
 		}
 
 		line := NewLyricLine()
-		line.StartTime = float64(lineStartMS)
-		line.EndTime = float64(lineEndMS)
+		line.StartTime = float64(lineStartMS) / scale
+		line.EndTime = float64(lineEndMS) / scale
 		line.IsBG = lineFlags&lineFlagIsBG != 0
 		line.IsDuet = lineFlags&lineFlagIsDuet != 0
 		line.IgnoreSync = lineFlags&lineFlagIgnoreSync != 0
-		line.Words = make([]LyricWord, 0, wordCount)
+		switch {
+		case lineFlags&lineFlagRoleHarmony != 0:
+			line.Role = LineRoleHarmony
+		case lineFlags&lineFlagRoleAdLib != 0:
+			line.Role = LineRoleAdLib
+		}
+		// 紧凑行没有 word_count，但会还原出一个词。
+		wordCap := max(wordCount, 1)
+		if lineIndex < len(reuse) && cap(reuse[lineIndex].Words) >= wordCap {
+			// 在 append 覆盖 reuse[lineIndex] 之前取出其词切片。
+			line.Words = reuse[lineIndex].Words[:0]
+		} else {
+			line.Words = make([]LyricWord, 0, wordCount)
+		}
 
 		if lineFlags&lineFlagHasTranslatedLyric != 0 {
 			translatedID, err := readUvarint(reader)
@@ -601,23 +1206,43 @@ func decodeLyricDataSection(reader *bytes.Reader, stringPool []string) ([]LyricL
 			line.RomanLyric = roman
 		}
 
+		if lineFlags&lineFlagLineText != 0 {
+			// 紧凑行还原为一个覆盖整行时间的词。
+			word := NewLyricWord()
+			word.StartTime = line.StartTime
+			word.EndTime = line.EndTime
+			word.Word = lineText
+			line.Words = append(line.Words, word)
+		}
+
 		for wordIndex := 0; wordIndex < wordCount; wordIndex++ {
-			deltaStart, err := readUvarint(reader)
-			if err != nil {
-				return nil, fmt.Errorf("read line[%d].word[%d].delta_start_time: %w", lineIndex, wordIndex, err)
-			}
-			duration, err := readUvarint(reader)
-			if err != nil {
-				return nil, fmt.Errorf("read line[%d].word[%d].duration: %w", lineIndex, wordIndex, err)
+			var deltaStart, duration uint64
+			hasWordFlags := true
+			if !untimed {
+				deltaStart, err = readUvarint(reader)
+				if err != nil {
+					return nil, fmt.Errorf("read line[%d].word[%d].delta_start_time: %w", lineIndex, wordIndex, err)
+				}
+				duration, err = readUvarint(reader)
+				if err != nil {
+					return nil, fmt.Errorf("read line[%d].word[%d].duration: %w", lineIndex, wordIndex, err)
+				}
+				if compactWords {
+					hasWordFlags = duration&1 != 0
+					duration >>= 1
+				}
 			}
 			textID, err := readUvarint(reader)
 			if err != nil {
 				return nil, fmt.Errorf("read line[%d].word[%d].text_string_id: %w", lineIndex, wordIndex, err)
 			}
 
-			wordFlags, err := reader.ReadByte()
-			if err != nil {
-				return nil, fmt.Errorf("read line[%d].word[%d].word_flags: %w", lineIndex, wordIndex, err)
+			var wordFlags uint8
+			if hasWordFlags {
+				wordFlags, err = reader.ReadByte()
+				if err != nil {
+					return nil, fmt.Errorf("read line[%d].word[%d].word_flags: %w", lineIndex, wordIndex, err)
+				}
 			}
 			if wordFlags&^wordFlagMask != 0 {
 				// 词级保留位同样严格校验。
@@ -632,6 +1257,10 @@ func decodeLyricDataSection(reader *bytes.Reader, stringPool []string) ([]LyricL
 			if err != nil {
 				return nil, err
 			}
+			// 起点由行起点加无符号增量得到，不会早于行起点，只需检查终点。
+			if opts.StrictTiming && wordEndMS > lineEndMS {
+				return nil, fmt.Errorf("line[%d].word[%d] end_time %d exceeds line end_time %d", lineIndex, wordIndex, wordEndMS, lineEndMS)
+			}
 
 			wordText, err := stringByID(stringPool, textID, fmt.Sprintf("line[%d].word[%d].text_string_id", lineIndex, wordIndex))
 			if err != nil {
@@ -639,11 +1268,13 @@ func decodeLyricDataSection(reader *bytes.Reader, stringPool []string) ([]LyricL
 			}
 
 			word := NewLyricWord()
-			word.StartTime = float64(wordStartMS)
-			word.EndTime = float64(wordEndMS)
+			word.StartTime = float64(wordStartMS) / scale
+			word.EndTime = float64(wordEndMS) / scale
 			word.Word = wordText
 			word.Obscene = wordFlags&wordFlagObscene != 0
 			word.RomanWarning = wordFlags&wordFlagRomanWarning != 0
+			word.Background = wordFlags&wordFlagBackground != 0
+			word.InstrumentalMarker = wordFlags&wordFlagInstrumentalMarker != 0
 
 			if wordFlags&wordFlagHasRomanWord != 0 {
 				romanID, err := readUvarint(reader)
@@ -665,7 +1296,19 @@ func decodeLyricDataSection(reader *bytes.Reader, stringPool []string) ([]LyricL
 				if emptyBeatMS > maxBinaryTimeMS {
 					return nil, fmt.Errorf("line[%d].word[%d].empty_beat_ms overflow", lineIndex, wordIndex)
 				}
-				word.EmptyBeat = float64(emptyBeatMS)
+				word.EmptyBeat = float64(emptyBeatMS) / scale
+			}
+
+			if wordFlags&wordFlagHasPhoneme != 0 {
+				phonemeID, err := readUvarint(reader)
+				if err != nil {
+					return nil, fmt.Errorf("read line[%d].word[%d].phoneme_string_id: %w", lineIndex, wordIndex, err)
+				}
+				phoneme, err := stringByID(stringPool, phonemeID, fmt.Sprintf("line[%d].word[%d].phoneme_string_id", lineIndex, wordIndex))
+				if err != nil {
+					return nil, err
+				}
+				word.Phoneme = phoneme
 			}
 
 			line.Words = append(line.Words, word)
@@ -688,19 +1331,36 @@ func safeAddMillis(base uint64, delta uint64, field string) (uint64, error) {
 	return base + delta, nil
 }
 
-// toMilliseconds 将浮点毫秒值规整为 uint64（四舍五入）。
-func toMilliseconds(value float64, field string) (uint64, error) {
+// toMilliseconds 按 rounding 将浮点毫秒值规整为 uint64。
+func toMilliseconds(value float64, rounding TimeRounding, field string) (uint64, error) {
+	ticks, problem := roundTicks(value, rounding)
+	if problem != "" {
+		return 0, fmt.Errorf("%s %s", field, problem)
+	}
+	return ticks, nil
+}
+
+// roundTicks 是 toMilliseconds 的核心，无法编码时返回问题描述，供 CheckEncode 复用。
+func roundTicks(value float64, rounding TimeRounding) (uint64, string) {
 	if math.IsNaN(value) || math.IsInf(value, 0) {
-		return 0, fmt.Errorf("%s must be a finite number", field)
+		return 0, "must be a finite number"
 	}
 	if value < 0 {
-		return 0, fmt.Errorf("%s must be >= 0", field)
+		return 0, "must be >= 0"
+	}
+	var rounded float64
+	switch rounding {
+	case TimeRoundingFloor:
+		rounded = math.Floor(value)
+	case TimeRoundingCeil:
+		rounded = math.Ceil(value)
+	default:
+		rounded = math.Round(value)
 	}
-	rounded := math.Round(value)
 	if rounded > float64(maxBinaryTimeMS) {
-		return 0, fmt.Errorf("%s overflow", field)
+		return 0, "overflow"
 	}
-	return uint64(rounded), nil
+	return uint64(rounded), ""
 }
 
 // stringByID 从字符串池按 ID 读取字符串并做越界检查。
@@ -719,7 +1379,7 @@ func writeUvarint(buf *bytes.Buffer, value uint64) {
 }
 
 // readUvarint 读取无符号 varint，并把 EOF 统一为 UnexpectedEOF。
-func readUvarint(reader *bytes.Reader) (uint64, error) {
+func readUvarint(reader amlxReader) (uint64, error) {
 	value, err := binary.ReadUvarint(reader)
 	if err == nil {
 		return value, nil
@@ -731,7 +1391,12 @@ func readUvarint(reader *bytes.Reader) (uint64, error) {
 }
 
 // readBytes 从 reader 读取定长字节切片，并保证不会超过剩余长度。
-func readBytes(reader *bytes.Reader, length uint64, field string) ([]byte, error) {
+func readBytes(reader amlxReader, length uint64, field string) ([]byte, error) {
+	return readBytesInto(reader, nil, length, field)
+}
+
+// readBytesInto 与 readBytes 相同，但在容量足够时复用 dst 的底层数组。
+func readBytesInto(reader amlxReader, dst []byte, length uint64, field string) ([]byte, error) {
 	if length > uint64(reader.Len()) {
 		return nil, fmt.Errorf("%s exceeds remaining bytes", field)
 	}
@@ -739,7 +1404,11 @@ func readBytes(reader *bytes.Reader, length uint64, field string) ([]byte, error
 	if err != nil {
 		return nil, err
 	}
-	raw := make([]byte, n)
+	raw := dst[:0]
+	if cap(raw) < n {
+		raw = make([]byte, n)
+	}
+	raw = raw[:n]
 	if _, err := io.ReadFull(reader, raw); err != nil {
 		return nil, fmt.Errorf("read %s: %w", field, err)
 	}
@@ -8,8 +8,10 @@ import (
 	"math"
 	"os"
 	"reflect"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestEncodeDecodeBinaryRoundTrip(t *testing.T) {
@@ -52,6 +54,7 @@ func TestEncodeDecodeBinaryRoundTrip(t *testing.T) {
 						EndTime:   2200,
 						Word:      "come",
 						EmptyBeat: 120,
+						Phoneme:   "kʌm",
 					},
 				},
 			},
@@ -375,6 +378,13 @@ func TestEncodeBinarySectionDiagnostics(t *testing.T) {
 	if err != nil {
 		t.Fatalf("read global_flags failed: %v", err)
 	}
+	timeScale := uint64(1)
+	if globalFlags&globalFlagTimeScale != 0 {
+		timeScale, _, err = readTestUvarintWithSize(reader, "time_scale")
+		if err != nil {
+			t.Fatalf("read time_scale failed: %v", err)
+		}
+	}
 
 	headerSize, headerSizeVarintBytes, err := readTestUvarintWithSize(reader, "header_size")
 	if err != nil {
@@ -385,7 +395,7 @@ func TestEncodeBinarySectionDiagnostics(t *testing.T) {
 		t.Fatalf("read header_section failed: %v", err)
 	}
 
-	t.Logf("container: total=%dB magic=%q version=0x%02x global_flags=0x%02x", len(encoded), string(magic), version, globalFlags)
+	t.Logf("container: total=%dB magic=%q version=0x%02x global_flags=0x%02x time_scale=%d", len(encoded), string(magic), version, globalFlags, timeScale)
 
 	headerReader := bytes.NewReader(headerBytes)
 	metadataCount, metadataCountVarintBytes, err := readTestUvarintWithSize(headerReader, "metadata_count")
@@ -498,6 +508,11 @@ func TestEncodeBinarySectionDiagnostics(t *testing.T) {
 		}
 
 		optionalLineFields := []string{}
+		if lineFlags&lineFlagLineText != 0 {
+			// 紧凑行：该 varint 实为行文本 ID，行内没有词记录。
+			optionalLineFields = append(optionalLineFields, fmt.Sprintf("line_text_id=%d(%dB)", wordCount, wordCountVarintBytes))
+			wordCount = 0
+		}
 		if lineFlags&lineFlagHasTranslatedLyric != 0 {
 			translatedID, translatedBytes, err := readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].translated_id", lineIndex))
 			if err != nil {
@@ -565,6 +580,13 @@ func TestEncodeBinarySectionDiagnostics(t *testing.T) {
 				}
 				optionalWordFields = append(optionalWordFields, fmt.Sprintf("empty_beat_ms=%d(%dB)", emptyBeatMS, emptyBeatBytes))
 			}
+			if wordFlags&wordFlagHasPhoneme != 0 {
+				phonemeID, phonemeBytes, err := readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].word[%d].phoneme_id", lineIndex, wordIndex))
+				if err != nil {
+					t.Fatalf("read line[%d].word[%d].phoneme_id failed: %v", lineIndex, wordIndex, err)
+				}
+				optionalWordFields = append(optionalWordFields, fmt.Sprintf("phoneme_id=%d(%dB)", phonemeID, phonemeBytes))
+			}
 			if len(optionalWordFields) == 0 {
 				optionalWordFields = append(optionalWordFields, "none")
 			}
@@ -757,7 +779,7 @@ func readTestByteWithSize(reader *bytes.Reader, field string) (byte, int, error)
 }
 
 func formatLineFlagsForTest(flags uint8) string {
-	names := make([]string, 0, 5)
+	names := make([]string, 0, 8)
 	if flags&lineFlagIsBG != 0 {
 		names = append(names, "is_bg")
 	}
@@ -773,6 +795,15 @@ func formatLineFlagsForTest(flags uint8) string {
 	if flags&lineFlagHasRomanLyric != 0 {
 		names = append(names, "has_roman")
 	}
+	if flags&lineFlagLineText != 0 {
+		names = append(names, "line_text")
+	}
+	if flags&lineFlagRoleHarmony != 0 {
+		names = append(names, "harmony")
+	}
+	if flags&lineFlagRoleAdLib != 0 {
+		names = append(names, "adlib")
+	}
 	if len(names) == 0 {
 		return "none"
 	}
@@ -780,7 +811,7 @@ func formatLineFlagsForTest(flags uint8) string {
 }
 
 func formatWordFlagsForTest(flags uint8) string {
-	names := make([]string, 0, 4)
+	names := make([]string, 0, 5)
 	if flags&wordFlagObscene != 0 {
 		names = append(names, "obscene")
 	}
@@ -793,8 +824,871 @@ func formatWordFlagsForTest(flags uint8) string {
 	if flags&wordFlagRomanWarning != 0 {
 		names = append(names, "roman_warning")
 	}
+	if flags&wordFlagHasPhoneme != 0 {
+		names = append(names, "has_phoneme")
+	}
+	if flags&wordFlagBackground != 0 {
+		names = append(names, "background")
+	}
+	if flags&wordFlagInstrumentalMarker != 0 {
+		names = append(names, "instrumental_marker")
+	}
 	if len(names) == 0 {
 		return "none"
 	}
 	return strings.Join(names, "|")
 }
+
+func TestDecodeBinaryLegacyNoGlobalFlags(t *testing.T) {
+	// 早期原型文件没有 global_flags 字节，需要显式开启兼容模式。
+	encoded, err := EncodeBinary(TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: "k", Value: []string{"v"}}},
+		LyricLines: []LyricLine{
+			{
+				StartTime: 100,
+				EndTime:   200,
+				Words:     []LyricWord{{StartTime: 100, EndTime: 200, Word: "w"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	fixedHeader := len(amlxMagic) + 1
+	legacy := append(append([]byte(nil), encoded[:fixedHeader]...), encoded[fixedHeader+1:]...)
+
+	if _, err := DecodeBinary(legacy); err == nil {
+		t.Fatalf("expected default decode of legacy payload to fail")
+	}
+	got, err := DecodeBinaryWithOptions(legacy, DecodeOptions{LegacyNoGlobalFlags: true})
+	if err != nil {
+		t.Fatalf("legacy decode failed: %v", err)
+	}
+	want, _ := DecodeBinary(encoded)
+	if !got.Equal(want) {
+		t.Fatalf("legacy decode mismatch\nexpected: %#v\nactual: %#v", want, got)
+	}
+}
+
+func TestDecodeBinaryStrictTiming(t *testing.T) {
+	var header bytes.Buffer
+	writeTestUvarint(&header, 0) // metadata_count
+
+	var payload bytes.Buffer
+	payload.WriteString(amlxMagic)
+	payload.WriteByte(amlxVersion)
+	payload.WriteByte(0) // global_flags
+	writeTestUvarint(&payload, uint64(header.Len()))
+	payload.Write(header.Bytes())
+
+	writeTestUvarint(&payload, 1) // string_count
+	writeTestUvarint(&payload, 1) // string[0].byte_length
+	payload.WriteByte('a')
+
+	writeTestUvarint(&payload, 1)    // line_count
+	writeTestUvarint(&payload, 1000) // line_start
+	writeTestUvarint(&payload, 1200) // line_end
+	payload.WriteByte(0)             // line_flags
+	writeTestUvarint(&payload, 1)    // word_count
+	writeTestUvarint(&payload, 100)  // delta_start
+	writeTestUvarint(&payload, 500)  // duration（越过行尾）
+	writeTestUvarint(&payload, 0)    // text_string_id
+	payload.WriteByte(0)             // word_flags
+
+	if _, err := DecodeBinary(payload.Bytes()); err != nil {
+		t.Fatalf("lenient decode should accept overlong word: %v", err)
+	}
+	if _, err := DecodeBinaryWithOptions(payload.Bytes(), DecodeOptions{StrictTiming: true}); err == nil {
+		t.Fatalf("strict decode should reject word past line end")
+	}
+}
+
+func TestEncodeBinaryCompactLineText(t *testing.T) {
+	// 逐行歌词：每行仅一个覆盖整行时间的词，紧凑模式应更小且往返一致。
+	lyric := TTMLLyric{}
+	for i := 0; i < 200; i++ {
+		start := float64(i * 3000)
+		lyric.LyricLines = append(lyric.LyricLines, LyricLine{
+			StartTime:       start,
+			EndTime:         start + 2500,
+			TranslatedLyric: fmt.Sprintf("translation %d", i),
+			Words: []LyricWord{
+				{StartTime: start, EndTime: start + 2500, Word: fmt.Sprintf("line-synced lyric %d", i)},
+			},
+		})
+	}
+	// 有词级属性的行不能走紧凑模式。
+	lyric.LyricLines[0].Words[0].Obscene = true
+
+	full, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	compact, err := EncodeBinaryWithOptions(lyric, EncodeOptions{CompactLineText: true})
+	if err != nil {
+		t.Fatalf("compact encode failed: %v", err)
+	}
+	if len(compact) >= len(full) {
+		t.Fatalf("compact encoding is not smaller: %d >= %d", len(compact), len(full))
+	}
+	t.Logf("line-synced corpus: full=%dB compact=%dB saved=%.2f%%", len(full), len(compact), float64(len(full)-len(compact))*100/float64(len(full)))
+
+	decoded, err := DecodeBinary(compact)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if !decoded.Equal(lyric) {
+		t.Fatalf("compact round-trip mismatch")
+	}
+}
+
+func TestEncodeBinaryTimeScale(t *testing.T) {
+	lyric := TTMLLyric{
+		LyricLines: []LyricLine{
+			{
+				StartTime: 100.25,
+				EndTime:   300.5,
+				Words: []LyricWord{
+					{StartTime: 100.25, EndTime: 200.75, Word: "a", EmptyBeat: 12.5},
+					{StartTime: 200.75, EndTime: 300.5, Word: "b"},
+				},
+			},
+		},
+	}
+
+	defaultEncoded, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if defaultEncoded[len(amlxMagic)+1] != 0 {
+		t.Fatalf("default encode must not set global flags")
+	}
+	rounded, err := DecodeBinary(defaultEncoded)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if rounded.LyricLines[0].Words[0].EndTime != 201 {
+		t.Fatalf("default encode should round to ms, got %v", rounded.LyricLines[0].Words[0].EndTime)
+	}
+
+	encoded, err := EncodeBinaryWithOptions(lyric, EncodeOptions{TimeScale: 4})
+	if err != nil {
+		t.Fatalf("scaled encode failed: %v", err)
+	}
+	if encoded[len(amlxMagic)+1] != globalFlagTimeScale {
+		t.Fatalf("expected TimeScale global flag, got 0x%02x", encoded[len(amlxMagic)+1])
+	}
+	if defaultEncoded[len(amlxMagic)] != amlxVersion || encoded[len(amlxMagic)] != amlxVersionLayout {
+		t.Fatalf("unexpected versions: default 0x%02x, scaled 0x%02x", defaultEncoded[len(amlxMagic)], encoded[len(amlxMagic)])
+	}
+	decoded, err := DecodeBinary(encoded)
+	if err != nil {
+		t.Fatalf("scaled decode failed: %v", err)
+	}
+	if !decoded.Equal(lyric) {
+		t.Fatalf("sub-ms timings not preserved: %v", decoded.LyricLines)
+	}
+
+	// 版本 1 文件不得带改变布局的标记，否则只认版本号的旧解码器会误解析。
+	downgraded := append([]byte(nil), encoded...)
+	downgraded[len(amlxMagic)] = amlxVersion
+	if _, err := DecodeBinary(downgraded); err == nil {
+		t.Fatalf("expected TimeScale in a version 1 file to be rejected")
+	}
+
+	// 未知标记位按规范忽略。
+	reserved := append([]byte(nil), defaultEncoded...)
+	reserved[len(amlxMagic)+1] = 0x80
+	if got, err := DecodeBinary(reserved); err != nil || !got.Equal(rounded) {
+		t.Fatalf("expected reserved global flags to be ignored: %v", err)
+	}
+}
+
+func TestEncodeBinaryTimeRounding(t *testing.T) {
+	lyric := TTMLLyric{
+		LyricLines: []LyricLine{
+			{
+				StartTime: 100.5,
+				EndTime:   300.2,
+				Words: []LyricWord{
+					{StartTime: 100.5, EndTime: 200.7, Word: "a", EmptyBeat: 12.5},
+					{StartTime: 200.7, EndTime: 300.2, Word: "b"},
+				},
+			},
+		},
+	}
+	cases := []struct {
+		rounding TimeRounding
+		want     []float64
+	}{
+		{TimeRoundingNearest, []float64{101, 201, 13, 201, 300}},
+		{TimeRoundingFloor, []float64{100, 200, 12, 200, 300}},
+		{TimeRoundingCeil, []float64{101, 201, 13, 201, 301}},
+	}
+	for _, c := range cases {
+		encoded, err := EncodeBinaryWithOptions(lyric, EncodeOptions{TimeRounding: c.rounding})
+		if err != nil {
+			t.Fatalf("rounding %d: encode failed: %v", c.rounding, err)
+		}
+		decoded, err := DecodeBinary(encoded)
+		if err != nil {
+			t.Fatalf("rounding %d: decode failed: %v", c.rounding, err)
+		}
+		words := decoded.LyricLines[0].Words
+		got := []float64{words[0].StartTime, words[0].EndTime, words[0].EmptyBeat, words[1].StartTime, words[1].EndTime}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("rounding %d: expected %v, got %v", c.rounding, c.want, got)
+		}
+	}
+}
+
+func TestBuildStringPool(t *testing.T) {
+	lyric := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: "k", Value: []string{"la"}}},
+		LyricLines: []LyricLine{
+			{Words: []LyricWord{{Word: "la"}, {Word: " "}, {Word: "la"}}, TranslatedLyric: "t"},
+			{Words: []LyricWord{{Word: "la", RomanWord: "t"}}},
+		},
+	}
+
+	values, counts := BuildStringPool(lyric)
+	wantValues := []string{"k", "la", "t", " "}
+	if len(values) != len(wantValues) {
+		t.Fatalf("unexpected pool: %q", values)
+	}
+	for i := range wantValues {
+		if values[i] != wantValues[i] {
+			t.Fatalf("unexpected pool order: %q", values)
+		}
+	}
+	if counts["la"] != 4 || counts["t"] != 2 || counts[" "] != 1 || counts["k"] != 1 {
+		t.Fatalf("unexpected counts: %v", counts)
+	}
+
+	encoded, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	reader := bytes.NewReader(encoded[len(amlxMagic)+2:])
+	headerSize, _ := readUvarint(reader)
+	if _, err := readBytes(reader, headerSize, "header section"); err != nil {
+		t.Fatalf("skip header failed: %v", err)
+	}
+	decodedPool, err := decodeStringPoolSection(reader, &decodeBuffers{})
+	if err != nil {
+		t.Fatalf("decode string pool failed: %v", err)
+	}
+	if len(decodedPool) != len(values) {
+		t.Fatalf("BuildStringPool disagrees with encoder: %q vs %q", values, decodedPool)
+	}
+}
+
+func TestEncodeDecodeEmptyLyric(t *testing.T) {
+	encoded, err := EncodeBinary(TTMLLyric{})
+	if err != nil {
+		t.Fatalf("encode empty lyric failed: %v", err)
+	}
+	want := []byte{'A', 'M', 'L', 'X', amlxVersion, 0, 1, 0, 0, 0}
+	if !bytes.Equal(encoded, want) {
+		t.Fatalf("unexpected minimal payload: % x", encoded)
+	}
+
+	decoded, err := DecodeBinary(encoded)
+	if err != nil {
+		t.Fatalf("decode minimal payload failed: %v", err)
+	}
+	if len(decoded.Metadata) != 0 || len(decoded.LyricLines) != 0 {
+		t.Fatalf("expected empty lyric, got %v", decoded)
+	}
+	ttmlText, err := BinaryToTTML(encoded, false)
+	if err != nil {
+		t.Fatalf("BinaryToTTML on minimal payload failed: %v", err)
+	}
+	if reparsed, err := ParseLyric(ttmlText); err != nil || len(reparsed.LyricLines) != 0 {
+		t.Fatalf("empty TTML did not reparse cleanly: %v, %v", reparsed, err)
+	}
+
+	partials := []TTMLLyric{
+		{Metadata: []TTMLMetadata{{Key: MetaKeyMusicName, Value: []string{"Song"}}}},
+		{LyricLines: []LyricLine{{StartTime: 0, EndTime: 1000, Words: []LyricWord{{StartTime: 0, EndTime: 1000, Word: "x"}}}}},
+		{LyricLines: []LyricLine{{}}},
+	}
+	for i, lyric := range partials {
+		encoded, err := EncodeBinary(lyric)
+		if err != nil {
+			t.Fatalf("partials[%d]: encode failed: %v", i, err)
+		}
+		decoded, err := DecodeBinary(encoded)
+		if err != nil {
+			t.Fatalf("partials[%d]: decode failed: %v", i, err)
+		}
+		if !decoded.Equal(lyric) {
+			t.Fatalf("partials[%d]: round trip mismatch: %v", i, decoded)
+		}
+	}
+}
+
+func TestDecodeBinaryAt(t *testing.T) {
+	lyric := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: MetaKeyMusicName, Value: []string{"Song"}}},
+		LyricLines: []LyricLine{
+			{StartTime: 0, EndTime: 1000, TranslatedLyric: "t", Words: []LyricWord{{StartTime: 0, EndTime: 1000, Word: "x", RomanWord: "r"}}},
+		},
+	}
+	encoded, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	// Surround the payload to make sure only [0, size) of the ReaderAt is used.
+	container := append(append([]byte(nil), encoded...), "trailing"...)
+	decoded, err := DecodeBinaryAt(bytes.NewReader(container), int64(len(encoded)))
+	if err != nil {
+		t.Fatalf("DecodeBinaryAt failed: %v", err)
+	}
+	if !decoded.Equal(lyric) {
+		t.Fatalf("DecodeBinaryAt mismatch: %v", decoded)
+	}
+
+	if _, err := DecodeBinaryAt(bytes.NewReader(encoded), int64(len(encoded)-1)); err == nil {
+		t.Fatalf("expected truncated size to fail")
+	}
+}
+
+func TestDecoderReuse(t *testing.T) {
+	first := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: MetaKeyMusicName, Value: []string{"第一首"}}},
+		LyricLines: []LyricLine{
+			{StartTime: 0, EndTime: 1000, TranslatedLyric: "翻译", Words: []LyricWord{{StartTime: 0, EndTime: 1000, Word: "alpha", RomanWord: "a"}}},
+		},
+	}
+	second := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: MetaKeyArtists, Value: []string{"x", "y"}}},
+		LyricLines: []LyricLine{
+			{StartTime: 500, EndTime: 900, Words: []LyricWord{{StartTime: 500, EndTime: 900, Word: "b"}}},
+		},
+	}
+	firstBin, err := EncodeBinary(first)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	secondBin, err := EncodeBinary(second)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	var dec Decoder
+	gotFirst, err := dec.Decode(firstBin)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if _, err := dec.Decode(firstBin[:len(firstBin)-1]); err == nil {
+		t.Fatalf("expected truncated data to fail")
+	}
+	gotSecond, err := dec.Decode(secondBin)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	// 复用缓冲后，先前的解码结果必须保持不变。
+	for _, tc := range []struct {
+		got, want TTMLLyric
+		data      []byte
+	}{{gotFirst, first, firstBin}, {gotSecond, second, secondBin}} {
+		ref, err := DecodeBinary(tc.data)
+		if err != nil {
+			t.Fatalf("DecodeBinary failed: %v", err)
+		}
+		if !tc.got.Equal(tc.want) || !tc.got.Equal(ref) {
+			t.Fatalf("Decoder output mismatch:\n got: %v\nwant: %v", tc.got, tc.want)
+		}
+	}
+
+	pooled := testing.AllocsPerRun(20, func() { _, _ = dec.Decode(firstBin) })
+	fresh := testing.AllocsPerRun(20, func() { _, _ = DecodeBinary(firstBin) })
+	if pooled >= fresh {
+		t.Fatalf("Decoder should allocate less than DecodeBinary: %v >= %v", pooled, fresh)
+	}
+}
+
+func TestEncodeBinaryIndexFooter(t *testing.T) {
+	lyric := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: MetaKeyMusicName, Value: []string{"Song"}}, {Key: MetaKeyArtists, Value: []string{"A", "B"}}},
+		LyricLines: []LyricLine{
+			{StartTime: 0, EndTime: 1000, Words: []LyricWord{{StartTime: 0, EndTime: 1000, Word: "x"}}},
+		},
+	}
+	plain, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	indexed, err := EncodeBinaryWithOptions(lyric, EncodeOptions{IndexFooter: true})
+	if err != nil {
+		t.Fatalf("encode with footer failed: %v", err)
+	}
+	if len(indexed) != len(plain)+amlxIndexFooterSize || !bytes.Equal(indexed[len(amlxMagic)+2:len(plain)], plain[len(amlxMagic)+2:]) {
+		t.Fatalf("footer must only set the flag and trail the known sections")
+	}
+
+	decoded, err := DecodeBinary(indexed)
+	if err != nil {
+		t.Fatalf("decode with footer failed: %v", err)
+	}
+	if !decoded.Equal(lyric) {
+		t.Fatalf("decode mismatch: %v", decoded)
+	}
+	if decoded, err := DecodeBinaryAt(bytes.NewReader(indexed), int64(len(indexed))); err != nil || !decoded.Equal(lyric) {
+		t.Fatalf("DecodeBinaryAt with footer failed: %v", err)
+	}
+
+	for _, data := range [][]byte{plain, indexed} {
+		metadata, err := DecodeMetadataOnly(data)
+		if err != nil {
+			t.Fatalf("DecodeMetadataOnly failed: %v", err)
+		}
+		if !reflect.DeepEqual(metadata, lyric.Metadata) {
+			t.Fatalf("unexpected metadata: %#v", metadata)
+		}
+	}
+
+	corrupt := append([]byte(nil), indexed...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	if _, err := DecodeBinary(corrupt); err == nil {
+		t.Fatalf("expected mismatched footer offsets to be rejected")
+	}
+
+	// DecodeMetadataOnly trusts the footer only after checking every offset
+	// against the sections it actually reads.
+	footerStart := len(indexed) - amlxIndexFooterSize
+	for field := 0; field < 3; field++ {
+		for _, delta := range []int64{-1, 1} {
+			shifted := append([]byte(nil), indexed...)
+			slot := shifted[footerStart+field*8:]
+			binary.LittleEndian.PutUint64(slot, uint64(int64(binary.LittleEndian.Uint64(slot))+delta))
+			if _, err := DecodeMetadataOnly(shifted); err == nil {
+				t.Fatalf("expected footer offset %d shifted by %d to be rejected", field, delta)
+			}
+		}
+	}
+}
+
+func TestBinaryToLRC(t *testing.T) {
+	lyric := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: MetaKeyMusicName, Value: []string{"歌名"}}},
+		LyricLines: []LyricLine{
+			{StartTime: 1000, EndTime: 2000, Words: []LyricWord{{StartTime: 1000, EndTime: 2000, Word: "第一行"}}},
+			{StartTime: 2500, EndTime: 4000, Words: []LyricWord{{StartTime: 2500, EndTime: 4000, Word: "第二行"}}},
+		},
+	}
+	encoded, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	decoded, err := DecodeBinary(encoded)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	lrc, err := BinaryToLRC(encoded)
+	if err != nil {
+		t.Fatalf("BinaryToLRC failed: %v", err)
+	}
+	if want := ExportLRCText(decoded); lrc != want {
+		t.Fatalf("BinaryToLRC mismatch:\n got: %q\nwant: %q", lrc, want)
+	}
+	if !strings.Contains(lrc, "[00:01.00]第一行") {
+		t.Fatalf("unexpected LRC output: %q", lrc)
+	}
+
+	if _, err := BinaryToLRC([]byte("AMLY")); err == nil {
+		t.Fatalf("expected invalid AMLX to be rejected")
+	}
+}
+
+func TestDecodeBinaryInfo(t *testing.T) {
+	lyric := TTMLLyric{
+		Metadata:   []TTMLMetadata{{Key: MetaKeyMusicName, Value: []string{"歌名"}}},
+		LyricLines: []LyricLine{{StartTime: 0, EndTime: 1000, Words: []LyricWord{{StartTime: 0, EndTime: 1000, Word: "x"}}}},
+	}
+	encoded, err := EncodeBinaryWithOptions(lyric, EncodeOptions{TimeScale: 10, IndexFooter: true})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	decoded, info, err := DecodeBinaryInfo(encoded)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if !decoded.Equal(lyric) {
+		t.Fatalf("decode mismatch: %v", decoded)
+	}
+	if info.Version != amlxVersionLayout || info.GlobalFlags != globalFlagTimeScale|globalFlagIndexFooter || info.TimeScale != 10 {
+		t.Fatalf("unexpected container info: %+v", info)
+	}
+	// 魔数、版本、全局标记、TimeScale 与 header_size 各占 1 字节。
+	if total := 8 + info.HeaderSize + info.StringPoolSize + info.LyricDataSize + amlxIndexFooterSize; total != uint64(len(encoded)) {
+		t.Fatalf("section sizes %+v do not add up to %d bytes", info, len(encoded))
+	}
+
+	plain, _ := EncodeBinary(lyric)
+	if _, info, err := DecodeBinaryInfo(plain); err != nil || info.GlobalFlags != 0 || info.TimeScale != 1 {
+		t.Fatalf("unexpected info for default encoding: %+v, %v", info, err)
+	}
+
+	future := append([]byte(nil), plain...)
+	future[len(amlxMagic)] = amlxVersionLayout + 1
+	if _, info, err := DecodeBinaryInfo(future); err == nil || info.Version != amlxVersionLayout+1 {
+		t.Fatalf("unsupported version should fail but still report it: %+v, %v", info, err)
+	}
+}
+
+func TestEncodeBinaryCompactWords(t *testing.T) {
+	// 构造逐字歌词：每行 8 个词，词间有空白词，少数词带标记。
+	var lyric TTMLLyric
+	for lineIndex := 0; lineIndex < 40; lineIndex++ {
+		start := float64(lineIndex * 4000)
+		line := LyricLine{StartTime: start, EndTime: start + 3600}
+		for wordIndex := 0; wordIndex < 8; wordIndex++ {
+			wordStart := start + float64(wordIndex*450)
+			word := LyricWord{StartTime: wordStart, EndTime: wordStart + 120 + float64(wordIndex*40), Word: fmt.Sprintf("w%d", wordIndex)}
+			if wordIndex == 3 && lineIndex%5 == 0 {
+				word.Obscene = true
+				word.RomanWord = "roman"
+			}
+			if wordIndex > 0 {
+				line.Words = append(line.Words, LyricWord{Word: " ", StartTime: wordStart, EndTime: wordStart})
+			}
+			line.Words = append(line.Words, word)
+		}
+		lyric.LyricLines = append(lyric.LyricLines, line)
+	}
+
+	plain, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	compact, err := EncodeBinaryWithOptions(lyric, EncodeOptions{CompactWords: true})
+	if err != nil {
+		t.Fatalf("compact encode failed: %v", err)
+	}
+	if compact[len(amlxMagic)+1] != globalFlagCompactWords {
+		t.Fatalf("expected CompactWords global flag, got 0x%02x", compact[len(amlxMagic)+1])
+	}
+	if compact[len(amlxMagic)] != amlxVersionLayout {
+		t.Fatalf("CompactWords must be written as version 0x%02x, got 0x%02x", amlxVersionLayout, compact[len(amlxMagic)])
+	}
+	downgraded := append([]byte(nil), compact...)
+	downgraded[len(amlxMagic)] = amlxVersion
+	if _, err := DecodeBinary(downgraded); err == nil {
+		t.Fatalf("expected CompactWords in a version 1 file to be rejected")
+	}
+	t.Logf("word-synced lyric: plain=%dB compact=%dB (%.1f%% smaller)", len(plain), len(compact), 100*float64(len(plain)-len(compact))/float64(len(plain)))
+	if len(compact) >= len(plain) {
+		t.Fatalf("compact words should be smaller: plain=%d compact=%d", len(plain), len(compact))
+	}
+
+	if err := VerifyBinaryIntegrity(compact); err != nil {
+		t.Fatalf("compact data failed integrity check: %v", err)
+	}
+	decoded, err := DecodeBinary(compact)
+	if err != nil {
+		t.Fatalf("compact decode failed: %v", err)
+	}
+	if !decoded.Equal(lyric) {
+		t.Fatalf("compact round trip mismatch")
+	}
+	if fromPlain, _ := DecodeBinary(plain); !fromPlain.Equal(decoded) {
+		t.Fatalf("compact and plain encodings must decode identically")
+	}
+}
+
+func TestEncodeBinaryDisablePoolDedup(t *testing.T) {
+	lyric := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: "album", Value: []string{"oh"}}, {Key: "album", Value: []string{"B"}}},
+		LyricLines: []LyricLine{
+			{
+				StartTime:       1000,
+				EndTime:         2000,
+				TranslatedLyric: "oh",
+				Words: []LyricWord{
+					{StartTime: 1000, EndTime: 1500, Word: "oh", RomanWord: "o"},
+					{StartTime: 1500, EndTime: 1500, Word: " "},
+					{StartTime: 1500, EndTime: 2000, Word: "oh"},
+				},
+			},
+			{StartTime: 2000, EndTime: 3000, RomanLyric: "o", Words: []LyricWord{{StartTime: 2000, EndTime: 3000, Word: "oh"}}},
+		},
+	}
+
+	deduped, err := EncodeBinaryWithOptions(lyric, EncodeOptions{IndexFooter: true})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	plain, err := EncodeBinaryWithOptions(lyric, EncodeOptions{IndexFooter: true, DisablePoolDedup: true, CompactLineText: true})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if len(plain) <= len(deduped) {
+		t.Fatalf("undeduplicated pool should be larger: %d <= %d", len(plain), len(deduped))
+	}
+	if err := VerifyBinaryIntegrity(plain); err != nil {
+		t.Fatalf("integrity check failed: %v", err)
+	}
+	decoded, err := DecodeBinary(plain)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if !decoded.Equal(lyric) {
+		t.Fatalf("round trip mismatch:\n got: %v\nwant: %v", decoded, lyric)
+	}
+
+	// 字符串池按引用顺序逐条写入：元数据，随后每行先词后行级文本。
+	index := parseIndexFooter(plain[len(plain)-amlxIndexFooterSize:])
+	pool, err := decodeStringPoolSection(bytes.NewReader(plain[index.stringPool:index.lyricData]), &decodeBuffers{})
+	if err != nil {
+		t.Fatalf("decode string pool failed: %v", err)
+	}
+	want := []string{"album", "oh", "album", "B", "oh", "o", " ", "oh", "oh", "oh", "o"}
+	if !reflect.DeepEqual(pool, want) {
+		t.Fatalf("expected pool %q, got %q", want, pool)
+	}
+}
+
+func TestDecodeBinaryInto(t *testing.T) {
+	large := TTMLLyric{
+		Metadata: []TTMLMetadata{
+			{Key: MetaKeyMusicName, Value: []string{"第一首"}},
+			{Key: MetaKeyArtists, Value: []string{"x", "y", "z"}},
+		},
+		LyricLines: []LyricLine{
+			{StartTime: 0, EndTime: 1000, TranslatedLyric: "翻译", Words: []LyricWord{
+				{StartTime: 0, EndTime: 500, Word: "alpha", RomanWord: "a"},
+				{StartTime: 500, EndTime: 1000, Word: "beta"},
+			}},
+			{StartTime: 1000, EndTime: 2000, Words: []LyricWord{{StartTime: 1000, EndTime: 2000, Word: "gamma"}}},
+			{StartTime: 2000, EndTime: 3000, IsBG: true, Words: []LyricWord{
+				{StartTime: 2000, EndTime: 2500, Word: "delta", Obscene: true},
+				{StartTime: 2500, EndTime: 3000, Word: "epsilon"},
+			}},
+		},
+	}
+	small := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: MetaKeyArtists}},
+		LyricLines: []LyricLine{
+			{StartTime: 500, EndTime: 900, Words: []LyricWord{{StartTime: 500, EndTime: 900, Word: "b"}}},
+		},
+	}
+	largeBin, err := EncodeBinary(large)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	smallBin, err := EncodeBinary(small)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	var dst TTMLLyric
+	for _, data := range [][]byte{largeBin, smallBin, largeBin} {
+		if err := DecodeBinaryInto(data, &dst); err != nil {
+			t.Fatalf("DecodeBinaryInto failed: %v", err)
+		}
+		ref, err := DecodeBinary(data)
+		if err != nil {
+			t.Fatalf("DecodeBinary failed: %v", err)
+		}
+		if !dst.Equal(ref) || !reflect.DeepEqual(normalizeLyricForCompare(dst), normalizeLyricForCompare(ref)) {
+			t.Fatalf("DecodeBinaryInto output mismatch:\n got: %+v\nwant: %+v", dst, ref)
+		}
+	}
+
+	// 第二次解码小文件时应复用第一行的底层数组。
+	firstWords := &dst.LyricLines[0].Words[:1][0]
+	if err := DecodeBinaryInto(smallBin, &dst); err != nil {
+		t.Fatalf("DecodeBinaryInto failed: %v", err)
+	}
+	if &dst.LyricLines[0].Words[0] != firstWords {
+		t.Fatalf("expected word slice to be reused")
+	}
+	if dst.Metadata[0].Value == nil {
+		t.Fatalf("empty metadata value should decode as an empty slice, as DecodeBinary does")
+	}
+
+	if err := DecodeBinaryInto(largeBin[:len(largeBin)-1], &dst); err == nil {
+		t.Fatalf("expected truncated data to fail")
+	}
+	if len(dst.Metadata) != 0 || len(dst.LyricLines) != 0 {
+		t.Fatalf("dst should be empty after a failed decode: %+v", dst)
+	}
+
+	var dec Decoder
+	if err := dec.DecodeInto(largeBin, &dst); err != nil {
+		t.Fatalf("Decoder.DecodeInto failed: %v", err)
+	}
+	into := testing.AllocsPerRun(20, func() { _ = dec.DecodeInto(largeBin, &dst) })
+	fresh := testing.AllocsPerRun(20, func() { _, _ = dec.Decode(largeBin) })
+	if into >= fresh {
+		t.Fatalf("DecodeInto should allocate less than Decode: %v >= %v", into, fresh)
+	}
+}
+
+func benchmarkDecodeLyric() []byte {
+	var lyric TTMLLyric
+	for i := 0; i < 200; i++ {
+		start := float64(i * 1000)
+		line := LyricLine{StartTime: start, EndTime: start + 1000}
+		for j := 0; j < 8; j++ {
+			wordStart := start + float64(j*125)
+			line.Words = append(line.Words, LyricWord{StartTime: wordStart, EndTime: wordStart + 125, Word: fmt.Sprintf("w%d", j)})
+		}
+		lyric.LyricLines = append(lyric.LyricLines, line)
+	}
+	data, err := EncodeBinary(lyric)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func BenchmarkDecodeBinary(b *testing.B) {
+	data := benchmarkDecodeLyric()
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := DecodeBinary(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeBinaryInto(b *testing.B) {
+	data := benchmarkDecodeLyric()
+	var dst TTMLLyric
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := DecodeBinaryInto(data, &dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestLargeLyricRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large lyric round trip in short mode")
+	}
+	// 5 万行、50 万词，模拟超长 DJ 混音歌词；每三行附带一行背景歌词。
+	const lineCount, wordsPerLine = 50000, 10
+	lyric := TTMLLyric{Metadata: []TTMLMetadata{{Key: MetaKeyMusicName, Value: []string{"mix"}}}}
+	for i := 0; len(lyric.LyricLines) < lineCount; i++ {
+		start := float64(i) * 1000
+		line := LyricLine{StartTime: start, EndTime: start + 900, TranslatedLyric: fmt.Sprintf("第%d行", i), IsBG: i%3 == 2}
+		for j := 0; j < wordsPerLine; j++ {
+			wordStart := start + float64(j*90)
+			line.Words = append(line.Words, LyricWord{StartTime: wordStart, EndTime: wordStart + 90, Word: fmt.Sprintf("w%d ", (i+j)%997)})
+		}
+		lyric.LyricLines = append(lyric.LyricLines, line)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	started := time.Now()
+	data, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	decoded, err := DecodeBinary(data)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	elapsed := time.Since(started)
+	runtime.ReadMemStats(&after)
+	if !decoded.Equal(lyric) {
+		t.Fatalf("large lyric changed after binary round trip")
+	}
+
+	// 阈值远高于实测值，只用于发现平方级退化。
+	words := lineCount * wordsPerLine
+	t.Logf("binary round trip: %d bytes allocated per word in %v", (after.TotalAlloc-before.TotalAlloc)/uint64(words), elapsed)
+	if perWord := (after.TotalAlloc - before.TotalAlloc) / uint64(words); perWord > 2048 {
+		t.Fatalf("binary round trip allocated %d bytes per word", perWord)
+	}
+	if elapsed > 20*time.Second {
+		t.Fatalf("binary round trip took %v", elapsed)
+	}
+
+	started = time.Now()
+	parsed, err := ParseLyric(ExportTTMLText(lyric, false))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 60*time.Second {
+		t.Fatalf("TTML round trip took %v", elapsed)
+	}
+	if len(parsed.LyricLines) != lineCount {
+		t.Fatalf("TTML round trip returned %d lines, want %d", len(parsed.LyricLines), lineCount)
+	}
+	if !parsed.Equal(lyric) {
+		t.Fatalf("large lyric changed after TTML round trip")
+	}
+}
+
+func TestEncodeBinaryUntimed(t *testing.T) {
+	lyric := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: MetaKeyMusicName, Value: []string{"plain"}}},
+		LyricLines: []LyricLine{
+			{TranslatedLyric: "纯文本", Words: []LyricWord{{Word: "hello"}, {Word: " "}, {Word: "world", RomanWord: "w", Obscene: true}}},
+			{IsBG: true, Words: []LyricWord{{Word: "ooh"}}},
+		},
+	}
+	plain, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	// CompactWords 与 Untimed 的词记录布局冲突，编码器忽略前者。
+	untimed, err := EncodeBinaryWithOptions(lyric, EncodeOptions{Untimed: true, CompactWords: true, IndexFooter: true})
+	if err != nil {
+		t.Fatalf("untimed encode failed: %v", err)
+	}
+	if flags := untimed[len(amlxMagic)+1]; flags != globalFlagUntimed|globalFlagIndexFooter {
+		t.Fatalf("unexpected global flags: 0x%02x", flags)
+	}
+	if untimed[len(amlxMagic)] != amlxVersionLayout {
+		t.Fatalf("Untimed must be written as version 0x%02x, got 0x%02x", amlxVersionLayout, untimed[len(amlxMagic)])
+	}
+	// 每行省去 2 个时间字段，每词省去 2 个，均为单字节 0。
+	if saved := len(plain) + amlxIndexFooterSize - len(untimed); saved != 2*2+2*4 {
+		t.Fatalf("untimed encoding saved %d bytes, want %d", saved, 2*2+2*4)
+	}
+	if err := VerifyBinaryIntegrity(untimed); err != nil {
+		t.Fatalf("untimed data failed integrity check: %v", err)
+	}
+	decoded, err := DecodeBinary(untimed)
+	if err != nil {
+		t.Fatalf("untimed decode failed: %v", err)
+	}
+	if !decoded.Equal(lyric) {
+		t.Fatalf("untimed round trip mismatch:\n got: %v\nwant: %v", decoded, lyric)
+	}
+	if out := ExportTTMLText(decoded, false); !strings.Contains(out, `itunes:timing="None"`) {
+		t.Fatalf("untimed lyric should export without timing:\n%s", out)
+	}
+
+	timed := lyric.clone()
+	timed.LyricLines[1].EndTime = 500
+	timed.LyricLines[1].Words[0].EndTime = 500
+	if _, err := EncodeBinaryWithOptions(timed, EncodeOptions{Untimed: true}); err == nil {
+		t.Fatalf("expected timed lyric to be rejected by untimed encoding")
+	}
+	issues := CheckEncodeWithOptions(timed, EncodeOptions{Untimed: true})
+	if len(issues) != 1 || issues[0].Severity != ValidationError || issues[0].Path != "line[1]" {
+		t.Fatalf("unexpected CheckEncode issues: %v", issues)
+	}
+
+	corrupted := bytes.Clone(untimed)
+	corrupted[len(amlxMagic)+1] |= globalFlagCompactWords
+	if _, err := DecodeBinary(corrupted); err == nil {
+		t.Fatalf("expected untimed and compact words flags together to be rejected")
+	}
+	downgraded := bytes.Clone(untimed)
+	downgraded[len(amlxMagic)] = amlxVersion
+	if _, err := DecodeBinary(downgraded); err == nil {
+		t.Fatalf("expected Untimed in a version 1 file to be rejected")
+	}
+}
@@ -0,0 +1,73 @@
+package ttml
+
+import "fmt"
+
+// MergeMode 控制 MergeBinary 如何把 overlay 的翻译与音译写入 base。
+type MergeMode int
+
+const (
+	// MergeFillEmpty 只填充 base 中为空的翻译与音译，已有内容保持不变。
+	MergeFillEmpty MergeMode = iota
+	// MergeOverwrite 用 overlay 中非空的翻译与音译覆盖 base；overlay 为空的字段不会清空 base。
+	MergeOverwrite
+)
+
+// MergeBinary 解码两份 AMLX，把 overlay 各行的 TranslatedLyric 与 RomanLyric 合并到 base 的对应行后重新编码，
+// 适合把单独存放的翻译文件并入原文文件，而无需经过 TTML。
+// 两者行数相同时按行序号对应；否则按起止时间完全相同对应，多个同时间的行依次配对，没有对应的行保持不变。
+// 结果只取 base 的元数据、词与其余字段，并沿用 base 的 TimeScale、IndexFooter、CompactWords 与 Untimed 全局标记。
+func MergeBinary(base, overlay []byte, mode MergeMode) ([]byte, error) {
+	if mode != MergeFillEmpty && mode != MergeOverwrite {
+		return nil, fmt.Errorf("unknown merge mode: %d", mode)
+	}
+	baseLyric, info, err := DecodeBinaryInfo(base)
+	if err != nil {
+		return nil, fmt.Errorf("decode base: %w", err)
+	}
+	overlayLyric, err := DecodeBinary(overlay)
+	if err != nil {
+		return nil, fmt.Errorf("decode overlay: %w", err)
+	}
+
+	mergeAuxiliary(baseLyric.LyricLines, overlayLyric.LyricLines, mode)
+
+	return EncodeBinaryWithOptions(baseLyric, EncodeOptions{
+		TimeScale:    info.TimeScale,
+		IndexFooter:  info.GlobalFlags&globalFlagIndexFooter != 0,
+		CompactWords: info.GlobalFlags&globalFlagCompactWords != 0,
+		Untimed:      info.GlobalFlags&globalFlagUntimed != 0,
+	})
+}
+
+// mergeAuxiliary 按 MergeBinary 的对应规则把 overlay 的翻译与音译写入 lines。
+func mergeAuxiliary(lines, overlay []LyricLine, mode MergeMode) {
+	merge := func(dst *string, src string) {
+		if src != "" && (mode == MergeOverwrite || *dst == "") {
+			*dst = src
+		}
+	}
+
+	if len(lines) == len(overlay) {
+		for i := range lines {
+			merge(&lines[i].TranslatedLyric, overlay[i].TranslatedLyric)
+			merge(&lines[i].RomanLyric, overlay[i].RomanLyric)
+		}
+		return
+	}
+
+	byTiming := make(map[[2]float64][]int, len(overlay))
+	for i, line := range overlay {
+		key := [2]float64{line.StartTime, line.EndTime}
+		byTiming[key] = append(byTiming[key], i)
+	}
+	for i := range lines {
+		key := [2]float64{lines[i].StartTime, lines[i].EndTime}
+		candidates := byTiming[key]
+		if len(candidates) == 0 {
+			continue
+		}
+		byTiming[key] = candidates[1:]
+		merge(&lines[i].TranslatedLyric, overlay[candidates[0]].TranslatedLyric)
+		merge(&lines[i].RomanLyric, overlay[candidates[0]].RomanLyric)
+	}
+}
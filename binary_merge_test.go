@@ -0,0 +1,83 @@
+package ttml
+
+import "testing"
+
+func TestMergeBinary(t *testing.T) {
+	base := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: MetaKeyMusicName, Value: []string{"Song"}}},
+		LyricLines: []LyricLine{
+			{StartTime: 0, EndTime: 1000, TranslatedLyric: "旧翻译", Words: []LyricWord{{StartTime: 0, EndTime: 1000, Word: "one"}}},
+			{StartTime: 1000, EndTime: 2000, Words: []LyricWord{{StartTime: 1000, EndTime: 2000, Word: "two"}}},
+			{StartTime: 2000, EndTime: 3000, RomanLyric: "san", Words: []LyricWord{{StartTime: 2000, EndTime: 3000, Word: "three"}}},
+		},
+	}
+	overlay := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: MetaKeyMusicName, Value: []string{"Other"}}},
+		LyricLines: []LyricLine{
+			{StartTime: 0, EndTime: 1000, TranslatedLyric: "一", Words: []LyricWord{{StartTime: 0, EndTime: 1000, Word: "x"}}},
+			{StartTime: 1000, EndTime: 2000, TranslatedLyric: "二", RomanLyric: "ni", Words: []LyricWord{{StartTime: 1000, EndTime: 2000, Word: "x"}}},
+			{StartTime: 2000, EndTime: 3000, Words: []LyricWord{{StartTime: 2000, EndTime: 3000, Word: "x"}}},
+		},
+	}
+	baseBin, err := EncodeBinaryWithOptions(base, EncodeOptions{IndexFooter: true, CompactWords: true})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	overlayBin, err := EncodeBinary(overlay)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	merge := func(overlayBin []byte, mode MergeMode) TTMLLyric {
+		t.Helper()
+		merged, err := MergeBinary(baseBin, overlayBin, mode)
+		if err != nil {
+			t.Fatalf("MergeBinary failed: %v", err)
+		}
+		lyric, info, err := DecodeBinaryInfo(merged)
+		if err != nil {
+			t.Fatalf("decode merged failed: %v", err)
+		}
+		if info.GlobalFlags != globalFlagIndexFooter|globalFlagCompactWords {
+			t.Fatalf("base global flags not kept: 0x%02x", info.GlobalFlags)
+		}
+		return lyric
+	}
+
+	fill := merge(overlayBin, MergeFillEmpty)
+	want := base.clone()
+	want.LyricLines[1].TranslatedLyric = "二"
+	want.LyricLines[1].RomanLyric = "ni"
+	if !fill.Equal(want) {
+		t.Fatalf("fill-empty merge mismatch:\n got: %v\nwant: %v", fill, want)
+	}
+
+	overwrite := merge(overlayBin, MergeOverwrite)
+	want.LyricLines[0].TranslatedLyric = "一"
+	if !overwrite.Equal(want) {
+		t.Fatalf("overwrite merge mismatch:\n got: %v\nwant: %v", overwrite, want)
+	}
+
+	// 行数不同时按起止时间对应，找不到对应行的 base 行保持不变。
+	partial := TTMLLyric{LyricLines: []LyricLine{
+		{StartTime: 2000, EndTime: 3000, TranslatedLyric: "三", Words: []LyricWord{{StartTime: 2000, EndTime: 3000, Word: "x"}}},
+		{StartTime: 5000, EndTime: 6000, TranslatedLyric: "无", Words: []LyricWord{{StartTime: 5000, EndTime: 6000, Word: "x"}}},
+	}}
+	partialBin, err := EncodeBinary(partial)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	byTiming := merge(partialBin, MergeFillEmpty)
+	want = base.clone()
+	want.LyricLines[2].TranslatedLyric = "三"
+	if !byTiming.Equal(want) {
+		t.Fatalf("timing merge mismatch:\n got: %v\nwant: %v", byTiming, want)
+	}
+
+	if _, err := MergeBinary(baseBin, overlayBin, MergeMode(99)); err == nil {
+		t.Fatalf("expected unknown merge mode to fail")
+	}
+	if _, err := MergeBinary(baseBin, overlayBin[:4], MergeFillEmpty); err == nil {
+		t.Fatalf("expected invalid overlay to fail")
+	}
+}
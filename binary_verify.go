@@ -0,0 +1,331 @@
+package ttml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+)
+
+// VerifyBinaryIntegrity 对 AMLX 做廉价的结构校验，而不构建歌词对象：
+// header_size 与头部段实际长度一致、字符串池与歌词段记录完整且字符串 ID 均在池内、
+// 索引尾部（如有）与实际段位置一致，并且文件末尾没有多余字节。
+// 通过校验的数据一定能被 DecodeBinary 解码。
+func VerifyBinaryIntegrity(data []byte) error {
+	reader := bytes.NewReader(data)
+	offset := func() uint64 { return uint64(len(data) - reader.Len()) }
+
+	prefix, err := decodePrefix(reader, DecodeOptions{})
+	if err != nil {
+		return err
+	}
+
+	var actual amlxIndex
+	actual.header = offset()
+	headerSize, err := readUvarint(reader)
+	if err != nil {
+		return fmt.Errorf("read header size: %w", err)
+	}
+	if headerSize > uint64(reader.Len()) {
+		return fmt.Errorf("header size %d exceeds remaining bytes", headerSize)
+	}
+	headerStart := offset()
+	header := data[headerStart : headerStart+headerSize]
+	if _, err := reader.Seek(int64(headerSize), io.SeekCurrent); err != nil {
+		return fmt.Errorf("skip header section: %w", err)
+	}
+
+	actual.stringPool = offset()
+	poolSize, err := skipStringPoolSection(reader)
+	if err != nil {
+		return err
+	}
+	// 头部段引用字符串池，因此需在得知池大小后再校验。
+	if err := verifyHeaderSection(header, poolSize); err != nil {
+		return err
+	}
+
+	actual.lyricData = offset()
+	if err := verifyLyricDataSection(reader, poolSize, prefix.globalFlags); err != nil {
+		return err
+	}
+
+	if prefix.globalFlags&globalFlagIndexFooter != 0 {
+		if reader.Len() < amlxIndexFooterSize {
+			return fmt.Errorf("index footer: expected %d trailing bytes, got %d", amlxIndexFooterSize, reader.Len())
+		}
+		footerStart := offset()
+		if index := parseIndexFooter(data[footerStart : footerStart+amlxIndexFooterSize]); index != actual {
+			return fmt.Errorf("index footer offsets %+v do not match sections %+v", index, actual)
+		}
+		reader.Seek(amlxIndexFooterSize, io.SeekCurrent)
+	}
+
+	if reader.Len() != 0 {
+		return fmt.Errorf("%d unexpected trailing bytes", reader.Len())
+	}
+	return nil
+}
+
+// skipStringPoolSection 跳过字符串池段，只返回字符串数量。
+func skipStringPoolSection(reader *bytes.Reader) (uint64, error) {
+	stringCount, err := readUvarint(reader)
+	if err != nil {
+		return 0, fmt.Errorf("read string_count: %w", err)
+	}
+	for i := uint64(0); i < stringCount; i++ {
+		length, err := readUvarint(reader)
+		if err != nil {
+			return 0, fmt.Errorf("read string[%d].length: %w", i, err)
+		}
+		if length > uint64(reader.Len()) {
+			return 0, fmt.Errorf("string[%d].bytes exceeds remaining bytes", i)
+		}
+		reader.Seek(int64(length), io.SeekCurrent)
+	}
+	return stringCount, nil
+}
+
+// verifyHeaderSection 按 decodeHeaderSection 的结构走读头部段，检查字符串 ID 与尾随字节。
+func verifyHeaderSection(header []byte, poolSize uint64) error {
+	reader := bytes.NewReader(header)
+	metadataCount, err := readUvarint(reader)
+	if err != nil {
+		return fmt.Errorf("read metadata_count: %w", err)
+	}
+	for metaIndex := uint64(0); metaIndex < metadataCount; metaIndex++ {
+		keyID, err := readUvarint(reader)
+		if err != nil {
+			return fmt.Errorf("read metadata[%d].key_string_id: %w", metaIndex, err)
+		}
+		if keyID >= poolSize {
+			return fmt.Errorf("metadata[%d].key_string_id out of bounds: %d (pool size %d)", metaIndex, keyID, poolSize)
+		}
+		valueCount, err := readUvarint(reader)
+		if err != nil {
+			return fmt.Errorf("read metadata[%d].value_count: %w", metaIndex, err)
+		}
+		for valueIndex := uint64(0); valueIndex < valueCount; valueIndex++ {
+			valueID, err := readUvarint(reader)
+			if err != nil {
+				return fmt.Errorf("read metadata[%d].value[%d]_string_id: %w", metaIndex, valueIndex, err)
+			}
+			if valueID >= poolSize {
+				return fmt.Errorf("metadata[%d].value[%d]_string_id out of bounds: %d (pool size %d)", metaIndex, valueIndex, valueID, poolSize)
+			}
+		}
+		if _, err := reader.ReadByte(); err != nil {
+			return fmt.Errorf("read metadata[%d].error_flag: %w", metaIndex, err)
+		}
+	}
+	if reader.Len() != 0 {
+		return fmt.Errorf("header section has %d unexpected trailing bytes", reader.Len())
+	}
+	return nil
+}
+
+// verifyLyricDataSection 按 decodeLyricDataSection 的结构走读歌词段，
+// 执行相同的标记位、时间与字符串 ID 检查，但不构建 LyricLine。
+func verifyLyricDataSection(reader *bytes.Reader, poolSize uint64, globalFlags uint8) error {
+	compactWords := globalFlags&globalFlagCompactWords != 0
+	untimed := globalFlags&globalFlagUntimed != 0
+	lineCount, err := readUvarint(reader)
+	if err != nil {
+		return fmt.Errorf("read line_count: %w", err)
+	}
+	for lineIndex := uint64(0); lineIndex < lineCount; lineIndex++ {
+		var lineStart, lineEnd uint64
+		if !untimed {
+			if lineStart, err = readUvarint(reader); err != nil {
+				return fmt.Errorf("read line[%d].start_time: %w", lineIndex, err)
+			}
+			if lineEnd, err = readUvarint(reader); err != nil {
+				return fmt.Errorf("read line[%d].end_time: %w", lineIndex, err)
+			}
+			if lineStart > maxBinaryTimeMS || lineEnd > maxBinaryTimeMS {
+				return fmt.Errorf("line[%d] time overflow", lineIndex)
+			}
+			if lineEnd < lineStart {
+				return fmt.Errorf("line[%d] end_time < start_time", lineIndex)
+			}
+		}
+
+		lineFlags, err := reader.ReadByte()
+		if err != nil {
+			return fmt.Errorf("read line[%d].line_flags: %w", lineIndex, err)
+		}
+		if lineFlags&^lineFlagMask != 0 {
+			return fmt.Errorf("line[%d] reserved line flags are set: 0x%02x", lineIndex, lineFlags&^lineFlagMask)
+		}
+		if lineFlags&lineFlagRoleHarmony != 0 && lineFlags&lineFlagRoleAdLib != 0 {
+			return fmt.Errorf("line[%d] both harmony and ad-lib role flags are set", lineIndex)
+		}
+		if lineFlags&lineFlagIsBG != 0 && lineFlags&(lineFlagRoleHarmony|lineFlagRoleAdLib) != 0 {
+			return fmt.Errorf("line[%d] background line has a harmony or ad-lib role flag", lineIndex)
+		}
+
+		// 行级字符串引用依次为：紧凑文本（或 word_count）、翻译、音译。
+		wordCount := uint64(0)
+		if lineFlags&lineFlagLineText != 0 {
+			if err := verifyStringID(reader, poolSize, "line[%d].line_text_string_id", lineIndex); err != nil {
+				return err
+			}
+		} else if wordCount, err = readUvarint(reader); err != nil {
+			return fmt.Errorf("read line[%d].word_count: %w", lineIndex, err)
+		}
+		if lineFlags&lineFlagHasTranslatedLyric != 0 {
+			if err := verifyStringID(reader, poolSize, "line[%d].translated_string_id", lineIndex); err != nil {
+				return err
+			}
+		}
+		if lineFlags&lineFlagHasRomanLyric != 0 {
+			if err := verifyStringID(reader, poolSize, "line[%d].roman_string_id", lineIndex); err != nil {
+				return err
+			}
+		}
+
+		for wordIndex := uint64(0); wordIndex < wordCount; wordIndex++ {
+			var deltaStart, duration uint64
+			hasWordFlags := true
+			if !untimed {
+				if deltaStart, err = readUvarint(reader); err != nil {
+					return fmt.Errorf("read line[%d].word[%d].delta_start_time: %w", lineIndex, wordIndex, err)
+				}
+				if duration, err = readUvarint(reader); err != nil {
+					return fmt.Errorf("read line[%d].word[%d].duration: %w", lineIndex, wordIndex, err)
+				}
+				if compactWords {
+					hasWordFlags = duration&1 != 0
+					duration >>= 1
+				}
+			}
+			wordStart, err := safeAddMillis(lineStart, deltaStart, "word start_time")
+			if err == nil {
+				_, err = safeAddMillis(wordStart, duration, "word end_time")
+			}
+			if err != nil {
+				return fmt.Errorf("line[%d].word[%d]: %w", lineIndex, wordIndex, err)
+			}
+			if err := verifyStringID(reader, poolSize, "line[%d].word[%d].text_string_id", lineIndex, wordIndex); err != nil {
+				return err
+			}
+
+			var wordFlags uint8
+			if hasWordFlags {
+				if wordFlags, err = reader.ReadByte(); err != nil {
+					return fmt.Errorf("read line[%d].word[%d].word_flags: %w", lineIndex, wordIndex, err)
+				}
+			}
+			if wordFlags&^wordFlagMask != 0 {
+				return fmt.Errorf("line[%d].word[%d] reserved word flags are set: 0x%02x", lineIndex, wordIndex, wordFlags&^wordFlagMask)
+			}
+			if wordFlags&wordFlagHasRomanWord != 0 {
+				if err := verifyStringID(reader, poolSize, "line[%d].word[%d].roman_string_id", lineIndex, wordIndex); err != nil {
+					return err
+				}
+			}
+			if wordFlags&wordFlagHasEmptyBeat != 0 {
+				emptyBeat, err := readUvarint(reader)
+				if err != nil {
+					return fmt.Errorf("read line[%d].word[%d].empty_beat_ms: %w", lineIndex, wordIndex, err)
+				}
+				if emptyBeat > maxBinaryTimeMS {
+					return fmt.Errorf("line[%d].word[%d].empty_beat_ms overflow", lineIndex, wordIndex)
+				}
+			}
+			if wordFlags&wordFlagHasPhoneme != 0 {
+				if err := verifyStringID(reader, poolSize, "line[%d].word[%d].phoneme_string_id", lineIndex, wordIndex); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// verifyStringID 读取一个字符串 ID 并检查其在池内；字段名仅在出错时格式化。
+func verifyStringID(reader *bytes.Reader, poolSize uint64, fieldFormat string, args ...any) error {
+	id, err := readUvarint(reader)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", fmt.Sprintf(fieldFormat, args...), err)
+	}
+	if id >= poolSize {
+		return fmt.Errorf("%s out of bounds: %d (pool size %d)", fmt.Sprintf(fieldFormat, args...), id, poolSize)
+	}
+	return nil
+}
+
+// CanEncode 在不生成字节的情况下检查 lyric 能否以默认选项编码为 AMLX，
+// 返回与 EncodeBinary 相同的第一个错误；可编码时返回 nil。
+func CanEncode(lyric TTMLLyric) error {
+	for _, issue := range CheckEncode(lyric) {
+		if issue.Severity == ValidationError {
+			return fmt.Errorf("%s %s", issue.Path, issue.Message)
+		}
+	}
+	return nil
+}
+
+// CheckEncode 以默认选项执行 CheckEncodeWithOptions。
+func CheckEncode(lyric TTMLLyric) []ValidationIssue {
+	return CheckEncodeWithOptions(lyric, EncodeOptions{})
+}
+
+// CheckEncodeWithOptions 按编码器的规则走读 lyric，按文档顺序返回全部问题：
+// 使编码失败的时间（NaN、负值、溢出）为 ValidationError；
+// 编码器会静默改写的数据为 ValidationWarning，包括结束早于开始的词（按零时长保存）、
+// 超出行时间的词（行时间被扩展）以及被丢弃的空拍。
+// opts.Untimed 时，带有非零时间的行同样为 ValidationError；无法存储的行角色（见 LyricLine.Role）也是。
+func CheckEncodeWithOptions(lyric TTMLLyric, opts EncodeOptions) []ValidationIssue {
+	var issues []ValidationIssue
+	scale := float64(1)
+	if opts.TimeScale > 1 {
+		scale = float64(opts.TimeScale)
+	}
+	// ticks 与编码器的 toTicks 一致，出错时记录问题并返回 false。
+	ticks := func(value float64, field string) (uint64, bool) {
+		t, problem := roundTicks(value*scale, opts.TimeRounding)
+		if problem != "" {
+			issues = append(issues, ValidationIssue{Severity: ValidationError, Path: field, Message: problem})
+			return 0, false
+		}
+		return t, true
+	}
+	warn := func(path, format string, args ...any) {
+		issues = append(issues, ValidationIssue{Severity: ValidationWarning, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for lineIndex, line := range lyric.LyricLines {
+		if opts.Untimed && !isUntimedLyric(lyric.LyricLines[lineIndex:lineIndex+1]) {
+			issues = append(issues, ValidationIssue{Severity: ValidationError, Path: fmt.Sprintf("line[%d]", lineIndex), Message: "has timing, which untimed encoding cannot store"})
+		}
+		if problem := line.roleProblem(); problem != "" {
+			issues = append(issues, ValidationIssue{Severity: ValidationError, Path: fmt.Sprintf("line[%d]", lineIndex), Message: problem})
+		}
+		lineStart, startOK := ticks(line.StartTime, fmt.Sprintf("line[%d].start_time", lineIndex))
+		lineEnd, endOK := ticks(line.EndTime, fmt.Sprintf("line[%d].end_time", lineIndex))
+		for wordIndex, word := range line.Words {
+			path := fmt.Sprintf("line[%d].word[%d]", lineIndex, wordIndex)
+			wordStart, wordStartOK := ticks(word.StartTime, path+".start_time")
+			wordEnd, wordEndOK := ticks(word.EndTime, path+".end_time")
+			if wordStartOK && wordEndOK {
+				if wordEnd < wordStart {
+					warn(path, "end %s before start %s, encoded with zero duration", MsToTimestamp(word.EndTime), MsToTimestamp(word.StartTime))
+					wordEnd = wordStart
+				}
+				if startOK && endOK && (wordStart < lineStart || wordEnd > lineEnd) {
+					warn(path, "word %s-%s outside line %s-%s, line time is extended", MsToTimestamp(word.StartTime), MsToTimestamp(word.EndTime), MsToTimestamp(line.StartTime), MsToTimestamp(line.EndTime))
+				}
+			}
+			if word.EmptyBeat == 0 {
+				continue
+			}
+			if math.IsNaN(word.EmptyBeat) || math.IsInf(word.EmptyBeat, 0) || word.EmptyBeat < 0 {
+				warn(path, "empty beat %v is dropped", word.EmptyBeat)
+			} else if emptyBeat, ok := ticks(word.EmptyBeat, path+".empty_beat"); ok && emptyBeat == 0 {
+				warn(path, "empty beat %v rounds to zero and is dropped", word.EmptyBeat)
+			}
+		}
+	}
+	return issues
+}
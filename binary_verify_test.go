@@ -0,0 +1,112 @@
+package ttml
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestVerifyBinaryIntegrity(t *testing.T) {
+	lyric := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: MetaKeyMusicName, Value: []string{"Song"}}},
+		LyricLines: []LyricLine{
+			{StartTime: 0, EndTime: 1000, TranslatedLyric: "t", Words: []LyricWord{{StartTime: 0, EndTime: 1000, Word: "x", RomanWord: "r", EmptyBeat: 5}}},
+			{StartTime: 1000, EndTime: 2000, Words: []LyricWord{{StartTime: 1000, EndTime: 2000, Word: "line"}}},
+		},
+	}
+	plain, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	withFooter, err := EncodeBinaryWithOptions(lyric, EncodeOptions{IndexFooter: true, CompactLineText: true})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	for _, data := range [][]byte{plain, withFooter} {
+		if err := VerifyBinaryIntegrity(data); err != nil {
+			t.Fatalf("valid data rejected: %v", err)
+		}
+	}
+
+	// header_size 紧跟 magic、version 与 global_flags。
+	headerSizeAt := len(amlxMagic) + 2
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"trailing garbage", append(append([]byte(nil), plain...), 0), "trailing bytes"},
+		{"truncated", plain[:len(plain)-1], "EOF"},
+		{"header too long", withByte(plain, headerSizeAt, plain[headerSizeAt]+1), ""},
+		{"header too short", withByte(plain, headerSizeAt, plain[headerSizeAt]-1), ""},
+		{"footer mismatch", withByte(withFooter, len(withFooter)-1, 1), "index footer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyBinaryIntegrity(tt.data)
+			if err == nil {
+				t.Fatalf("expected corruption to be reported")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q does not mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func withByte(data []byte, index int, value byte) []byte {
+	out := append([]byte(nil), data...)
+	out[index] = value
+	return out
+}
+
+func TestCheckEncode(t *testing.T) {
+	valid := TTMLLyric{LyricLines: []LyricLine{
+		{StartTime: 0, EndTime: 1000, Words: []LyricWord{{StartTime: 0, EndTime: 1000, Word: "x", EmptyBeat: 5}}},
+	}}
+	if err := CanEncode(valid); err != nil {
+		t.Fatalf("valid lyric rejected: %v", err)
+	}
+	if issues := CheckEncode(valid); issues != nil {
+		t.Fatalf("expected no issues, got %v", issues)
+	}
+
+	lyric := TTMLLyric{LyricLines: []LyricLine{
+		{StartTime: 1000, EndTime: 2000, Words: []LyricWord{
+			{StartTime: 500, EndTime: 1500, Word: "early"},
+			{StartTime: 1800, EndTime: 1600, Word: "backwards"},
+			{StartTime: 1600, EndTime: 1700, Word: "beat", EmptyBeat: math.NaN()},
+		}},
+		{StartTime: -1, EndTime: 3000, Words: []LyricWord{
+			{StartTime: 2000, EndTime: math.Inf(1), Word: "inf"},
+		}},
+	}}
+	var got []string
+	for _, issue := range CheckEncode(lyric) {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		"warning: line[0].word[0]: word 00:00.500-00:01.500 outside line 00:01.000-00:02.000, line time is extended",
+		"warning: line[0].word[1]: end 00:01.600 before start 00:01.800, encoded with zero duration",
+		"warning: line[0].word[2]: empty beat NaN is dropped",
+		"error: line[1].start_time: must be >= 0",
+		"error: line[1].word[0].end_time: must be a finite number",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected issues:\n%s", strings.Join(got, "\n"))
+	}
+
+	err := CanEncode(lyric)
+	_, encodeErr := EncodeBinary(lyric)
+	if err == nil || encodeErr == nil || err.Error() != encodeErr.Error() {
+		t.Fatalf("CanEncode should report the encoder error %v, got %v", encodeErr, err)
+	}
+
+	overflow := TTMLLyric{LyricLines: []LyricLine{{StartTime: 0, EndTime: float64(maxBinaryTimeMS)}}}
+	if err := CanEncode(overflow); err != nil {
+		t.Fatalf("max time rejected at scale 1: %v", err)
+	}
+	if issues := CheckEncodeWithOptions(overflow, EncodeOptions{TimeScale: 10}); len(issues) != 1 || issues[0].Path != "line[0].end_time" {
+		t.Fatalf("expected overflow with TimeScale, got %v", issues)
+	}
+}
@@ -22,6 +22,8 @@ type extremeFileLog struct {
 	BinaryToTTMLMs         float64 `json:"binary_to_ttml_ms"`
 	TotalMs                float64 `json:"total_ms"`
 	Success                bool    `json:"success"`
+	SemanticMatch          bool    `json:"semantic_match"`
+	Mismatch               string  `json:"mismatch,omitempty"`
 	Error                  string  `json:"error,omitempty"`
 }
 
@@ -35,6 +37,7 @@ type extremeSummary struct {
 	TotalFiles         int     `json:"total_files"`
 	SuccessFiles       int     `json:"success_files"`
 	FailedFiles        int     `json:"failed_files"`
+	MismatchFiles      int     `json:"mismatch_files"`
 	AvgTTMLToBinaryMs  float64 `json:"avg_ttml_to_binary_ms"`
 	AvgBinaryToTTMLMs  float64 `json:"avg_binary_to_ttml_ms"`
 	AvgTotalMs         float64 `json:"avg_total_ms"`
@@ -90,6 +93,7 @@ func TestExtremeTTMLBinaryPipeline(t *testing.T) {
 	var sumTTMLToBinary time.Duration
 	var sumBinaryToTTML time.Duration
 	var successCount int
+	var mismatchCount int
 
 	for _, inputPath := range inputFiles {
 		relativePath, err := filepath.Rel(inputDir, inputPath)
@@ -165,6 +169,27 @@ func TestExtremeTTMLBinaryPipeline(t *testing.T) {
 		fileLog.RoundTripTTMLPath = roundTripRelativePath
 		fileLog.RoundTripTTMLSizeBytes = len(roundTripTTML)
 
+		// 语义比对：原始 TTML 与往返 TTML 解析后的结构应一致（忽略运行期 ID）。
+		originalLyric, err := ParseLyric(string(rawTTML))
+		if err != nil {
+			fileLog.Error = fmt.Sprintf("ParseLyric(original): %v", err)
+			fileLog.TotalMs = fileLog.TTMLToBinaryMs + fileLog.BinaryToTTMLMs
+			fileLogs = append(fileLogs, fileLog)
+			continue
+		}
+		roundTripLyric, err := ParseLyric(roundTripTTML)
+		if err != nil {
+			fileLog.Error = fmt.Sprintf("ParseLyric(round-trip): %v", err)
+			fileLog.TotalMs = fileLog.TTMLToBinaryMs + fileLog.BinaryToTTMLMs
+			fileLogs = append(fileLogs, fileLog)
+			continue
+		}
+		fileLog.SemanticMatch = originalLyric.Equal(roundTripLyric)
+		if !fileLog.SemanticMatch {
+			fileLog.Mismatch = describeLyricMismatch(originalLyric, roundTripLyric)
+			mismatchCount++
+		}
+
 		fileLog.TotalMs = fileLog.TTMLToBinaryMs + fileLog.BinaryToTTMLMs
 		fileLog.Success = true
 		fileLogs = append(fileLogs, fileLog)
@@ -197,6 +222,7 @@ func TestExtremeTTMLBinaryPipeline(t *testing.T) {
 			TotalFiles:         len(fileLogs),
 			SuccessFiles:       successCount,
 			FailedFiles:        failedCount,
+			MismatchFiles:      mismatchCount,
 			AvgTTMLToBinaryMs:  avgTTMLToBinaryMs,
 			AvgBinaryToTTMLMs:  avgBinaryToTTMLMs,
 			AvgTotalMs:         avgTotalMs,
@@ -218,14 +244,17 @@ func TestExtremeTTMLBinaryPipeline(t *testing.T) {
 		t.Fatalf("write json log: %v", err)
 	}
 
-	t.Logf("extreme test finished: total=%d success=%d failed=%d avg_ttml_to_binary_ms=%.3f avg_binary_to_ttml_ms=%.3f",
-		report.Summary.TotalFiles, report.Summary.SuccessFiles, report.Summary.FailedFiles,
+	t.Logf("extreme test finished: total=%d success=%d failed=%d mismatch=%d avg_ttml_to_binary_ms=%.3f avg_binary_to_ttml_ms=%.3f",
+		report.Summary.TotalFiles, report.Summary.SuccessFiles, report.Summary.FailedFiles, report.Summary.MismatchFiles,
 		report.Summary.AvgTTMLToBinaryMs, report.Summary.AvgBinaryToTTMLMs)
 	t.Logf("logs: %s, %s", logTextPath, logJSONPath)
 
 	if failedCount > 0 {
 		t.Fatalf("extreme test has %d failed files, see %s", failedCount, logTextPath)
 	}
+	if mismatchCount > 0 {
+		t.Fatalf("extreme test has %d semantic mismatches after round-trip, see %s", mismatchCount, logTextPath)
+	}
 }
 
 func collectTTMLFiles(root string) ([]string, error) {
@@ -271,11 +300,19 @@ func renderExtremeTextLog(report extremeReport) string {
 	sb.WriteString(fmt.Sprintf("TotalFiles: %d\n", s.TotalFiles))
 	sb.WriteString(fmt.Sprintf("SuccessFiles: %d\n", s.SuccessFiles))
 	sb.WriteString(fmt.Sprintf("FailedFiles: %d\n", s.FailedFiles))
+	sb.WriteString(fmt.Sprintf("MismatchFiles: %d\n", s.MismatchFiles))
 	sb.WriteString(fmt.Sprintf("AvgTTMLToBinaryMs: %.3f\n", s.AvgTTMLToBinaryMs))
 	sb.WriteString(fmt.Sprintf("AvgBinaryToTTMLMs: %.3f\n", s.AvgBinaryToTTMLMs))
 	sb.WriteString(fmt.Sprintf("AvgTotalMs: %.3f\n", s.AvgTotalMs))
 	sb.WriteString("\nPerFile:\n")
 	for _, f := range report.Files {
+		if f.Success && !f.SemanticMatch {
+			sb.WriteString(fmt.Sprintf(
+				"MISMATCH | %s | ttml->binary=%.3fms | binary->ttml=%.3fms | total=%.3fms | mismatch=%s\n",
+				f.InputPath, f.TTMLToBinaryMs, f.BinaryToTTMLMs, f.TotalMs, f.Mismatch,
+			))
+			continue
+		}
 		if f.Success {
 			sb.WriteString(fmt.Sprintf(
 				"OK | %s | ttml->binary=%.3fms | binary->ttml=%.3fms | total=%.3fms | input=%dB | binary=%dB | output=%dB\n",
@@ -292,6 +329,42 @@ func renderExtremeTextLog(report extremeReport) string {
 	return sb.String()
 }
 
+// describeLyricMismatch 返回第一处语义差异的位置，便于在报告中定位问题。
+func describeLyricMismatch(expected, actual TTMLLyric) string {
+	if len(expected.Metadata) != len(actual.Metadata) {
+		return fmt.Sprintf("metadata count %d != %d", len(expected.Metadata), len(actual.Metadata))
+	}
+	for i := range expected.Metadata {
+		if !metadataEqual(expected.Metadata[i], actual.Metadata[i]) {
+			return fmt.Sprintf("metadata[%d]: %+v != %+v", i, expected.Metadata[i], actual.Metadata[i])
+		}
+	}
+	if len(expected.LyricLines) != len(actual.LyricLines) {
+		return fmt.Sprintf("line count %d != %d", len(expected.LyricLines), len(actual.LyricLines))
+	}
+	for i := range expected.LyricLines {
+		a := expected.LyricLines[i]
+		b := actual.LyricLines[i]
+		if lineEqual(a, b) {
+			continue
+		}
+		if len(a.Words) != len(b.Words) {
+			return fmt.Sprintf("line[%d] word count %d != %d", i, len(a.Words), len(b.Words))
+		}
+		for j := range a.Words {
+			if !wordEqual(a.Words[j], b.Words[j]) {
+				return fmt.Sprintf("line[%d].word[%d]: %+v != %+v", i, j, a.Words[j], b.Words[j])
+			}
+		}
+		a.Words = nil
+		b.Words = nil
+		a.ID = ""
+		b.ID = ""
+		return fmt.Sprintf("line[%d]: %+v != %+v", i, a, b)
+	}
+	return ""
+}
+
 func durationToMS(d time.Duration) float64 {
 	return float64(d) / float64(time.Millisecond)
 }
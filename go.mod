@@ -2,10 +2,13 @@ module github.com/xiaowumin-mark/amll-ttml
 
 go 1.25.1
 
-require github.com/spf13/cobra v1.10.2
+require (
+	github.com/fatih/color v1.18.0
+	github.com/spf13/cobra v1.10.2
+	golang.org/x/text v0.21.0
+)
 
 require (
-	github.com/fatih/color v1.18.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
@@ -0,0 +1,165 @@
+package ttml
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"math"
+)
+
+// Equal reports whether two lyrics carry the same content.
+// Runtime IDs, preserved xml:ids and itunes:keys, the WasPretty hint and the
+// TTML-only DocumentProfile, TranslatedWords and RawSpans are ignored, and
+// empty beats that the binary codec would drop (non-finite or <= 0) are
+// treated as absent.
+func (l TTMLLyric) Equal(other TTMLLyric) bool {
+	if len(l.Metadata) != len(other.Metadata) || len(l.LyricLines) != len(other.LyricLines) {
+		return false
+	}
+	for i := range l.Metadata {
+		if !metadataEqual(l.Metadata[i], other.Metadata[i]) {
+			return false
+		}
+	}
+	for i := range l.LyricLines {
+		if !lineEqual(l.LyricLines[i], other.LyricLines[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func metadataEqual(a, b TTMLMetadata) bool {
+	if a.Key != b.Key || a.Error != b.Error || len(a.Value) != len(b.Value) {
+		return false
+	}
+	for i := range a.Value {
+		if a.Value[i] != b.Value[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func lineEqual(a, b LyricLine) bool {
+	if a.TranslatedLyric != b.TranslatedLyric ||
+		a.RomanLyric != b.RomanLyric ||
+		a.IsBG != b.IsBG ||
+		a.EffectiveRole() != b.EffectiveRole() ||
+		a.IsDuet != b.IsDuet ||
+		a.IgnoreSync != b.IgnoreSync ||
+		!timeEqual(a.StartTime, b.StartTime) ||
+		!timeEqual(a.EndTime, b.EndTime) ||
+		len(a.Words) != len(b.Words) {
+		return false
+	}
+	for i := range a.Words {
+		if !wordEqual(a.Words[i], b.Words[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func wordEqual(a, b LyricWord) bool {
+	return a.Word == b.Word &&
+		a.Obscene == b.Obscene &&
+		a.RomanWord == b.RomanWord &&
+		a.RomanWarning == b.RomanWarning &&
+		a.Phoneme == b.Phoneme &&
+		a.Background == b.Background &&
+		a.InstrumentalMarker == b.InstrumentalMarker &&
+		timeEqual(a.StartTime, b.StartTime) &&
+		timeEqual(a.EndTime, b.EndTime) &&
+		normalizeEmptyBeat(a.EmptyBeat) == normalizeEmptyBeat(b.EmptyBeat)
+}
+
+func timeEqual(a, b float64) bool {
+	if math.IsNaN(a) && math.IsNaN(b) {
+		return true
+	}
+	return a == b
+}
+
+// normalizeEmptyBeat maps empty beats the writer and encoder ignore to 0.
+func normalizeEmptyBeat(value float64) float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) || value <= 0 {
+		return 0
+	}
+	return value
+}
+
+// ContentHash returns a SHA-256 digest of the lyric's canonical content.
+// It covers exactly the fields Equal compares, so lyrics that are Equal
+// always hash identically regardless of IDs or source formatting.
+func (l TTMLLyric) ContentHash() [32]byte {
+	h := sha256.New()
+	writeHashUint(h, uint64(len(l.Metadata)))
+	for _, meta := range l.Metadata {
+		writeHashString(h, meta.Key)
+		writeHashBool(h, meta.Error)
+		writeHashUint(h, uint64(len(meta.Value)))
+		for _, value := range meta.Value {
+			writeHashString(h, value)
+		}
+	}
+	writeHashUint(h, uint64(len(l.LyricLines)))
+	for _, line := range l.LyricLines {
+		writeHashString(h, line.TranslatedLyric)
+		writeHashString(h, line.RomanLyric)
+		writeHashBool(h, line.IsBG)
+		writeHashUint(h, uint64(line.EffectiveRole()))
+		writeHashBool(h, line.IsDuet)
+		writeHashBool(h, line.IgnoreSync)
+		writeHashFloat(h, line.StartTime)
+		writeHashFloat(h, line.EndTime)
+		writeHashUint(h, uint64(len(line.Words)))
+		for _, word := range line.Words {
+			writeHashString(h, word.Word)
+			writeHashBool(h, word.Obscene)
+			writeHashString(h, word.RomanWord)
+			writeHashBool(h, word.RomanWarning)
+			writeHashString(h, word.Phoneme)
+			writeHashBool(h, word.Background)
+			writeHashBool(h, word.InstrumentalMarker)
+			writeHashFloat(h, word.StartTime)
+			writeHashFloat(h, word.EndTime)
+			writeHashFloat(h, normalizeEmptyBeat(word.EmptyBeat))
+		}
+	}
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+func writeHashUint(h hash.Hash, value uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], value)
+	h.Write(tmp[:n])
+}
+
+// writeHashString length-prefixes the value so adjacent fields cannot collide.
+func writeHashString(h hash.Hash, value string) {
+	writeHashUint(h, uint64(len(value)))
+	h.Write([]byte(value))
+}
+
+func writeHashBool(h hash.Hash, value bool) {
+	if value {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+}
+
+// writeHashFloat canonicalizes NaN and -0 so they hash like Equal compares them.
+func writeHashFloat(h hash.Hash, value float64) {
+	if math.IsNaN(value) {
+		value = math.NaN()
+	} else if value == 0 {
+		value = 0
+	}
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(value))
+	h.Write(tmp[:])
+}
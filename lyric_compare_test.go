@@ -0,0 +1,49 @@
+package ttml
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLyricEqualIgnoresIDsAndDroppedEmptyBeat(t *testing.T) {
+	a := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: "album", Value: []string{"1989"}}},
+		LyricLines: []LyricLine{
+			{
+				ID:        "a",
+				StartTime: 100,
+				EndTime:   300,
+				Words: []LyricWord{
+					{ID: "a-w", StartTime: 100, EndTime: 300, Word: "hi", EmptyBeat: math.NaN()},
+				},
+			},
+		},
+	}
+	b := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: "album", Value: []string{"1989"}}},
+		LyricLines: []LyricLine{
+			{
+				ID:        "b",
+				StartTime: 100,
+				EndTime:   300,
+				Words: []LyricWord{
+					{ID: "b-w", StartTime: 100, EndTime: 300, Word: "hi"},
+				},
+			},
+		},
+	}
+	if !a.Equal(b) {
+		t.Fatalf("expected lyrics to be equal")
+	}
+	if a.ContentHash() != b.ContentHash() {
+		t.Fatalf("equal lyrics must hash identically")
+	}
+
+	b.LyricLines[0].Words[0].EndTime = 301
+	if a.Equal(b) {
+		t.Fatalf("expected timing change to be detected")
+	}
+	if a.ContentHash() == b.ContentHash() {
+		t.Fatalf("expected timing change to alter the hash")
+	}
+}
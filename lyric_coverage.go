@@ -0,0 +1,81 @@
+package ttml
+
+import "strings"
+
+// CoverageStats counts translation and romanization coverage over one kind
+// of line. Wordless separator lines and whitespace tokens are not counted.
+type CoverageStats struct {
+	Lines           int
+	TranslatedLines int
+	Words           int
+	RomanizedWords  int
+	// MissingTranslation lists the LyricLines indices of lines without a
+	// TranslatedLyric.
+	MissingTranslation []int
+	// MissingRomanization lists the LyricLines indices of lines with at least
+	// one word lacking a RomanWord.
+	MissingRomanization []int
+}
+
+// TranslationRatio returns the fraction of lines with a translation, or 1
+// when there are no lines.
+func (s CoverageStats) TranslationRatio() float64 {
+	if s.Lines == 0 {
+		return 1
+	}
+	return float64(s.TranslatedLines) / float64(s.Lines)
+}
+
+// RomanizationRatio returns the fraction of words with a romanization, or 1
+// when there are no words.
+func (s CoverageStats) RomanizationRatio() float64 {
+	if s.Words == 0 {
+		return 1
+	}
+	return float64(s.RomanizedWords) / float64(s.Words)
+}
+
+// CoverageReport is the result of Coverage, with main and background lines
+// counted separately.
+type CoverageReport struct {
+	Main       CoverageStats
+	Background CoverageStats
+}
+
+// Coverage reports how much of the lyric is translated (per line) and
+// romanized (per word via RomanWord; a line-level RomanLyric does not count),
+// e.g. to find lines a localization pass still has to cover.
+func (l TTMLLyric) Coverage() CoverageReport {
+	var report CoverageReport
+	for lineIndex, line := range l.LyricLines {
+		if len(line.Words) == 0 {
+			continue
+		}
+		stats := &report.Main
+		if line.IsBG {
+			stats = &report.Background
+		}
+		stats.Lines++
+		if strings.TrimSpace(line.TranslatedLyric) != "" {
+			stats.TranslatedLines++
+		} else {
+			stats.MissingTranslation = append(stats.MissingTranslation, lineIndex)
+		}
+		missingRoman := false
+		for _, word := range line.Words {
+			if word.IsBlank() {
+				continue
+			}
+			stats.Words++
+			if strings.TrimSpace(word.RomanWord) != "" {
+				stats.RomanizedWords++
+			} else {
+				missingRoman = true
+			}
+		}
+		if missingRoman {
+			stats.MissingRomanization = append(stats.MissingRomanization, lineIndex)
+		}
+	}
+	return report
+}
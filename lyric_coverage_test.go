@@ -0,0 +1,52 @@
+package ttml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	lyric := TTMLLyric{LyricLines: []LyricLine{
+		{TranslatedLyric: "你好", Words: []LyricWord{
+			{Word: "hel", RomanWord: "hel"},
+			{Word: " "},
+			{Word: "lo", RomanWord: "lo"},
+		}},
+		{IsBG: true, Words: []LyricWord{{Word: "ooh"}}},
+		{},
+		{RomanLyric: "line-level only", Words: []LyricWord{
+			{Word: "a", RomanWord: "a"},
+			{Word: "b"},
+		}},
+	}}
+
+	want := CoverageReport{
+		Main: CoverageStats{
+			Lines:               2,
+			TranslatedLines:     1,
+			Words:               4,
+			RomanizedWords:      3,
+			MissingTranslation:  []int{3},
+			MissingRomanization: []int{3},
+		},
+		Background: CoverageStats{
+			Lines:               1,
+			Words:               1,
+			MissingTranslation:  []int{1},
+			MissingRomanization: []int{1},
+		},
+	}
+	report := lyric.Coverage()
+	if !reflect.DeepEqual(report, want) {
+		t.Fatalf("unexpected report:\n got: %+v\nwant: %+v", report, want)
+	}
+	if got := report.Main.TranslationRatio(); got != 0.5 {
+		t.Fatalf("translation ratio = %v", got)
+	}
+	if got := report.Main.RomanizationRatio(); got != 0.75 {
+		t.Fatalf("romanization ratio = %v", got)
+	}
+	if got := (CoverageStats{}).TranslationRatio(); got != 1 {
+		t.Fatalf("empty stats should be fully covered, got %v", got)
+	}
+}
@@ -0,0 +1,412 @@
+package ttml
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// StripAuxiliary returns a copy of the lyric without translations and/or
+// romanizations, e.g. for an "original only" export with a smaller string pool.
+func (l TTMLLyric) StripAuxiliary(dropTranslation, dropRomanization bool) TTMLLyric {
+	out := l.clone()
+	for i := range out.LyricLines {
+		line := &out.LyricLines[i]
+		if dropTranslation {
+			line.TranslatedLyric = ""
+			line.TranslatedWords = nil
+		}
+		if dropRomanization {
+			line.RomanLyric = ""
+			for j := range line.Words {
+				line.Words[j].RomanWord = ""
+				line.Words[j].RomanWarning = false
+			}
+		}
+	}
+	return out
+}
+
+// clone deep-copies the metadata values, lines and words so edits on the
+// result never alias the receiver.
+func (l TTMLLyric) clone() TTMLLyric {
+	out := TTMLLyric{
+		Metadata:        make([]TTMLMetadata, len(l.Metadata)),
+		LyricLines:      make([]LyricLine, len(l.LyricLines)),
+		DocumentProfile: l.DocumentProfile,
+		WasPretty:       l.WasPretty,
+	}
+	for i, meta := range l.Metadata {
+		meta.Value = append([]string(nil), meta.Value...)
+		out.Metadata[i] = meta
+	}
+	for i, line := range l.LyricLines {
+		line.Words = append([]LyricWord(nil), line.Words...)
+		if line.TranslatedWords != nil {
+			line.TranslatedWords = append([]LyricWord(nil), line.TranslatedWords...)
+		}
+		if line.RawSpans != nil {
+			line.RawSpans = append([]string(nil), line.RawSpans...)
+		}
+		out.LyricLines[i] = line
+	}
+	return out
+}
+
+// DedupMetadata removes repeated (key, value) pairs, keeping the first
+// occurrence. Entries that lose all their values are dropped; distinct values
+// under the same key are preserved in order.
+func (l *TTMLLyric) DedupMetadata() {
+	type pair struct{ key, value string }
+	seen := map[pair]bool{}
+	emptySeen := map[string]bool{}
+	out := l.Metadata[:0]
+	for _, meta := range l.Metadata {
+		if len(meta.Value) == 0 {
+			if emptySeen[meta.Key] {
+				continue
+			}
+			emptySeen[meta.Key] = true
+			out = append(out, meta)
+			continue
+		}
+		values := make([]string, 0, len(meta.Value))
+		for _, value := range meta.Value {
+			p := pair{meta.Key, value}
+			if seen[p] {
+				continue
+			}
+			seen[p] = true
+			values = append(values, value)
+		}
+		if len(values) == 0 {
+			continue
+		}
+		meta.Value = values
+		out = append(out, meta)
+	}
+	l.Metadata = out
+}
+
+// InsertWord inserts w into line lineIdx before position wordIdx (len(Words)
+// appends) and widens the line's StartTime/EndTime to cover its words.
+func (l *TTMLLyric) InsertWord(lineIdx, wordIdx int, w LyricWord) error {
+	if lineIdx < 0 || lineIdx >= len(l.LyricLines) {
+		return fmt.Errorf("line index %d out of range [0, %d)", lineIdx, len(l.LyricLines))
+	}
+	line := &l.LyricLines[lineIdx]
+	if wordIdx < 0 || wordIdx > len(line.Words) {
+		return fmt.Errorf("line[%d]: word index %d out of range [0, %d]", lineIdx, wordIdx, len(line.Words))
+	}
+	if w.ID == "" {
+		w.ID = newUID()
+	}
+	line.Words = append(line.Words, LyricWord{})
+	copy(line.Words[wordIdx+1:], line.Words[wordIdx:])
+	line.Words[wordIdx] = w
+	line.updateEnvelope()
+	return nil
+}
+
+// RemoveWord deletes word wordIdx from line lineIdx and shrinks the line's
+// StartTime/EndTime to its remaining words.
+func (l *TTMLLyric) RemoveWord(lineIdx, wordIdx int) error {
+	if lineIdx < 0 || lineIdx >= len(l.LyricLines) {
+		return fmt.Errorf("line index %d out of range [0, %d)", lineIdx, len(l.LyricLines))
+	}
+	line := &l.LyricLines[lineIdx]
+	if wordIdx < 0 || wordIdx >= len(line.Words) {
+		return fmt.Errorf("line[%d]: word index %d out of range [0, %d)", lineIdx, wordIdx, len(line.Words))
+	}
+	line.Words = append(line.Words[:wordIdx], line.Words[wordIdx+1:]...)
+	line.updateEnvelope()
+	return nil
+}
+
+// InsertLine inserts line before position lineIdx (len(LyricLines) appends).
+// The inserted line's envelope is recomputed from its words.
+func (l *TTMLLyric) InsertLine(lineIdx int, line LyricLine) error {
+	if lineIdx < 0 || lineIdx > len(l.LyricLines) {
+		return fmt.Errorf("line index %d out of range [0, %d]", lineIdx, len(l.LyricLines))
+	}
+	if line.ID == "" {
+		line.ID = newUID()
+	}
+	line.Words = append([]LyricWord(nil), line.Words...)
+	for i := range line.Words {
+		if line.Words[i].ID == "" {
+			line.Words[i].ID = newUID()
+		}
+	}
+	line.updateEnvelope()
+	l.LyricLines = append(l.LyricLines, LyricLine{})
+	copy(l.LyricLines[lineIdx+1:], l.LyricLines[lineIdx:])
+	l.LyricLines[lineIdx] = line
+	return nil
+}
+
+// RemoveLine deletes line lineIdx.
+func (l *TTMLLyric) RemoveLine(lineIdx int) error {
+	if lineIdx < 0 || lineIdx >= len(l.LyricLines) {
+		return fmt.Errorf("line index %d out of range [0, %d)", lineIdx, len(l.LyricLines))
+	}
+	l.LyricLines = append(l.LyricLines[:lineIdx], l.LyricLines[lineIdx+1:]...)
+	return nil
+}
+
+// updateEnvelope sets StartTime/EndTime to span the non-blank words; the
+// parser leaves whitespace tokens at 0, so they never count. Lines without
+// non-blank words keep their existing timing.
+func (line *LyricLine) updateEnvelope() {
+	if start, end, ok := wordEnvelope(line.Words); ok {
+		line.StartTime, line.EndTime = start, end
+	}
+}
+
+// SortWords stably reorders the non-blank words of the line by start time.
+// Whitespace tokens keep their positions, so the spacing of the text is
+// preserved while the words move between them.
+func (line *LyricLine) SortWords() {
+	var slots []int
+	var words []LyricWord
+	for i, word := range line.Words {
+		if !word.IsBlank() {
+			slots = append(slots, i)
+			words = append(words, word)
+		}
+	}
+	sort.SliceStable(words, func(i, j int) bool { return words[i].StartTime < words[j].StartTime })
+	for i, slot := range slots {
+		line.Words[slot] = words[i]
+	}
+}
+
+// SplitTranslationDelimiter splits bilingual line-timed lines such as
+// "original / translation": for each line with a single word containing
+// delim, the text after the first delim moves into TranslatedLyric. Lines that
+// already have a translation are left alone.
+func (l *TTMLLyric) SplitTranslationDelimiter(delim string) {
+	if delim == "" {
+		return
+	}
+	for i := range l.LyricLines {
+		line := &l.LyricLines[i]
+		if len(line.Words) != 1 || line.TranslatedLyric != "" {
+			continue
+		}
+		original, translation, found := strings.Cut(line.Words[0].Word, delim)
+		if !found {
+			continue
+		}
+		line.Words[0].Word = strings.TrimSpace(original)
+		line.TranslatedLyric = strings.TrimSpace(translation)
+	}
+}
+
+// Quantize snaps every word start/end to the nearest multiple of stepMS and
+// re-derives line envelopes from the snapped non-blank words. Lines without
+// non-blank words have their own timing snapped. A non-positive or non-finite
+// stepMS is a no-op.
+func (l *TTMLLyric) Quantize(stepMS float64) {
+	if !(stepMS > 0) || math.IsInf(stepMS, 0) {
+		return
+	}
+	snap := func(ms float64) float64 {
+		return math.Round(ms/stepMS) * stepMS
+	}
+	for i := range l.LyricLines {
+		line := &l.LyricLines[i]
+		for j := range line.Words {
+			line.Words[j].StartTime = snap(line.Words[j].StartTime)
+			line.Words[j].EndTime = snap(line.Words[j].EndTime)
+		}
+		for j := range line.TranslatedWords {
+			line.TranslatedWords[j].StartTime = snap(line.TranslatedWords[j].StartTime)
+			line.TranslatedWords[j].EndTime = snap(line.TranslatedWords[j].EndTime)
+		}
+		if _, _, ok := wordEnvelope(line.Words); !ok {
+			line.StartTime = snap(line.StartTime)
+			line.EndTime = snap(line.EndTime)
+			continue
+		}
+		line.updateEnvelope()
+	}
+}
+
+// DedupAdjacentWords merges consecutive words with identical text whose gap
+// (second start minus first end) is at most maxGapMS, as ASR alignment
+// sometimes emits a word twice. The merged word keeps the first word's fields
+// and spans from the first start to the later end. Whitespace tokens are
+// never merged and are skipped when looking for the previous word; any that
+// sat between two merged words are dropped. It returns the number of merges;
+// a negative or NaN maxGapMS merges nothing.
+func (l *TTMLLyric) DedupAdjacentWords(maxGapMS float64) int {
+	if !(maxGapMS >= 0) {
+		return 0
+	}
+	merges := 0
+	for i := range l.LyricLines {
+		line := &l.LyricLines[i]
+		if len(line.Words) < 2 {
+			continue
+		}
+		out := line.Words[:0]
+		prev := -1 // index in out of the last non-blank word
+		for _, word := range line.Words {
+			if word.IsBlank() {
+				out = append(out, word)
+				continue
+			}
+			if prev >= 0 && word.Word == out[prev].Word &&
+				word.StartTime-out[prev].EndTime <= maxGapMS {
+				out = out[:prev+1]
+				out[prev].EndTime = math.Max(out[prev].EndTime, word.EndTime)
+				merges++
+				continue
+			}
+			out = append(out, word)
+			prev = len(out) - 1
+		}
+		line.Words = out
+	}
+	return merges
+}
+
+// Slice returns a copy holding only the lines that overlap the half-open
+// window [startMS, endMS). Lines crossing a window edge keep just the words
+// that overlap it (plus the whitespace between them) and get their envelope
+// re-derived; word times are not clipped. Separator lines are kept only
+// between retained lines. Metadata is copied unchanged. Use Shift(-startMS)
+// on the result to rebase it to zero.
+func (l TTMLLyric) Slice(startMS, endMS float64) TTMLLyric {
+	src := l.clone()
+	out := src
+	out.LyricLines = make([]LyricLine, 0, len(src.LyricLines))
+	overlaps := func(start, end float64) bool {
+		return end > startMS && start < endMS
+	}
+	pendingSeparator := -1
+	for i, line := range src.LyricLines {
+		if len(line.Words) == 0 {
+			if len(out.LyricLines) > 0 && pendingSeparator < 0 {
+				pendingSeparator = i
+			}
+			continue
+		}
+		if !overlaps(line.StartTime, line.EndTime) {
+			continue
+		}
+		first, last := -1, -1
+		for j, word := range line.Words {
+			if !word.IsBlank() && overlaps(word.StartTime, word.EndTime) {
+				if first < 0 {
+					first = j
+				}
+				last = j
+			}
+		}
+		if first < 0 {
+			continue
+		}
+		if first > 0 || last < len(line.Words)-1 {
+			line.Words = line.Words[first : last+1]
+			line.StartTime, line.EndTime, _ = wordEnvelope(line.Words)
+		}
+		if pendingSeparator >= 0 {
+			out.LyricLines = append(out.LyricLines, src.LyricLines[pendingSeparator])
+			pendingSeparator = -1
+		}
+		out.LyricLines = append(out.LyricLines, line)
+	}
+	return out
+}
+
+// Shift moves every line and word time by offsetMS, e.g. Shift(-startMS)
+// after Slice to rebase a snippet to zero.
+func (l *TTMLLyric) Shift(offsetMS float64) {
+	for i := range l.LyricLines {
+		line := &l.LyricLines[i]
+		line.StartTime += offsetMS
+		line.EndTime += offsetMS
+		for j := range line.Words {
+			line.Words[j].StartTime += offsetMS
+			line.Words[j].EndTime += offsetMS
+		}
+		for j := range line.TranslatedWords {
+			line.TranslatedWords[j].StartTime += offsetMS
+			line.TranslatedWords[j].EndTime += offsetMS
+		}
+	}
+}
+
+// ReflowByGaps re-splits the lyric into lines by timing, e.g. to turn a flat
+// stream of force-aligned words into karaoke lines: all words are flattened
+// in order and a new line starts wherever the gap between consecutive words
+// exceeds lineBreakGapMS. Wordless separator lines stay where they are and
+// always break. Whitespace at the new line edges is dropped, each new line
+// takes its flags from the line its first word came from, and envelopes are
+// re-derived. Translations and romanizations cannot be split along with the
+// words, so a lyric with any of them, or with background lines, is left
+// unchanged, as it is for a non-positive or non-finite lineBreakGapMS.
+func (l *TTMLLyric) ReflowByGaps(lineBreakGapMS float64) {
+	if !(lineBreakGapMS > 0) || math.IsInf(lineBreakGapMS, 0) {
+		return
+	}
+	for _, line := range l.LyricLines {
+		if line.IsBG || line.TranslatedLyric != "" || len(line.TranslatedWords) > 0 || line.RomanLyric != "" {
+			return
+		}
+	}
+
+	out := make([]LyricLine, 0, len(l.LyricLines))
+	var current *LyricLine
+	// pending holds the whitespace seen since the last word; it is kept
+	// only if another word follows on the same line.
+	var pending []LyricWord
+	lastEnd := 0.0
+	flush := func() {
+		if current != nil {
+			if start, end, ok := wordEnvelope(current.Words); ok {
+				current.StartTime, current.EndTime = start, end
+			} else {
+				current.updateEnvelope()
+			}
+			out = append(out, *current)
+			current = nil
+		}
+		pending = nil
+	}
+	for _, line := range l.LyricLines {
+		if len(line.Words) == 0 {
+			flush()
+			out = append(out, line)
+			continue
+		}
+		for _, word := range line.Words {
+			if word.IsBlank() {
+				if current != nil {
+					pending = append(pending, word)
+				}
+				continue
+			}
+			if current != nil && word.StartTime-lastEnd > lineBreakGapMS {
+				flush()
+			}
+			if current == nil {
+				next := line
+				next.ID = newUID()
+				next.XMLID = ""
+				next.ITunesKey = ""
+				next.Words = nil
+				current = &next
+			}
+			current.Words = append(current.Words, pending...)
+			current.Words = append(current.Words, word)
+			pending = nil
+			lastEnd = word.EndTime
+		}
+	}
+	flush()
+	l.LyricLines = out
+}
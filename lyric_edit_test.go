@@ -0,0 +1,392 @@
+package ttml
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStripAuxiliaryDropsStringsFromPool(t *testing.T) {
+	lyric := TTMLLyric{
+		LyricLines: []LyricLine{
+			{
+				StartTime:       0,
+				EndTime:         1000,
+				TranslatedLyric: "translation",
+				RomanLyric:      "line-roman",
+				Words: []LyricWord{
+					{StartTime: 0, EndTime: 1000, Word: "word", RomanWord: "word-roman", RomanWarning: true},
+				},
+			},
+		},
+	}
+
+	stripped := lyric.StripAuxiliary(true, true)
+	if lyric.LyricLines[0].TranslatedLyric == "" || lyric.LyricLines[0].Words[0].RomanWord == "" {
+		t.Fatalf("StripAuxiliary must not modify the receiver")
+	}
+	if stripped.LyricLines[0].Words[0].RomanWarning {
+		t.Fatalf("roman warning should be cleared with romanization")
+	}
+
+	pool := buildStringPool(stripped, false)
+	for _, dropped := range []string{"translation", "line-roman", "word-roman"} {
+		if _, ok := pool.get(dropped); ok {
+			t.Fatalf("%q still in string pool", dropped)
+		}
+	}
+	if _, ok := pool.get("word"); !ok {
+		t.Fatalf("original text missing from string pool")
+	}
+
+	onlyTranslation := lyric.StripAuxiliary(true, false)
+	if onlyTranslation.LyricLines[0].TranslatedLyric != "" || onlyTranslation.LyricLines[0].RomanLyric != "line-roman" {
+		t.Fatalf("unexpected partial strip: %#v", onlyTranslation.LyricLines[0])
+	}
+}
+
+func TestInsertRemoveWordMaintainsEnvelope(t *testing.T) {
+	// Parsed lines carry whitespace tokens timed 0/0, which must not pull the
+	// envelope back to 0.
+	lyric, err := ParseLyric(`<tt xmlns="http://www.w3.org/ns/ttml"><body><div>` +
+		`<p begin="00:01.000" end="00:02.000"><span begin="00:01.000" end="00:01.500">b</span> <span begin="00:01.500" end="00:02.000">b</span></p>` +
+		`</div></body></tt>`)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if words := lyric.LyricLines[0].Words; len(words) != 3 || !words[1].IsBlank() || words[1].StartTime != 0 {
+		t.Fatalf("expected a 0-timed whitespace token between the words: %#v", words)
+	}
+
+	if err := lyric.InsertWord(0, 1, LyricWord{Word: " "}); err != nil {
+		t.Fatalf("InsertWord failed: %v", err)
+	}
+	if line := lyric.LyricLines[0]; line.StartTime != 1000 || line.EndTime != 2000 {
+		t.Fatalf("inserting whitespace moved the envelope to %v-%v", line.StartTime, line.EndTime)
+	}
+	if err := lyric.RemoveWord(0, 1); err != nil {
+		t.Fatalf("RemoveWord failed: %v", err)
+	}
+
+	if err := lyric.InsertWord(0, 0, LyricWord{StartTime: 500, EndTime: 1000, Word: "a"}); err != nil {
+		t.Fatalf("InsertWord failed: %v", err)
+	}
+	if err := lyric.InsertWord(0, 4, LyricWord{StartTime: 2000, EndTime: 2500, Word: "c"}); err != nil {
+		t.Fatalf("InsertWord failed: %v", err)
+	}
+	line := lyric.LyricLines[0]
+	if line.StartTime != 500 || line.EndTime != 2500 || line.Words[0].Word != "a" || line.Words[4].Word != "c" {
+		t.Fatalf("unexpected line after insert: %#v", line)
+	}
+	if line.Words[0].ID == "" {
+		t.Fatalf("inserted word should get an ID")
+	}
+
+	if err := lyric.RemoveWord(0, 4); err != nil {
+		t.Fatalf("RemoveWord failed: %v", err)
+	}
+	if got := lyric.LyricLines[0]; got.StartTime != 500 || got.EndTime != 2000 {
+		t.Fatalf("envelope not shrunk after remove: %v-%v", got.StartTime, got.EndTime)
+	}
+
+	// A line of whitespace only keeps its timing.
+	blank := TTMLLyric{LyricLines: []LyricLine{{StartTime: 3000, EndTime: 4000, Words: []LyricWord{{Word: " "}}}}}
+	if err := blank.InsertWord(0, 0, LyricWord{Word: " "}); err != nil {
+		t.Fatalf("InsertWord failed: %v", err)
+	}
+	if got := blank.LyricLines[0]; got.StartTime != 3000 || got.EndTime != 4000 {
+		t.Fatalf("whitespace-only line lost its timing: %v-%v", got.StartTime, got.EndTime)
+	}
+
+	if err := lyric.InsertWord(1, 0, LyricWord{}); err == nil {
+		t.Fatalf("expected error for bad line index")
+	}
+	if err := lyric.RemoveWord(0, 5); err == nil {
+		t.Fatalf("expected error for bad word index")
+	}
+}
+
+func TestInsertRemoveLine(t *testing.T) {
+	var lyric TTMLLyric
+	if err := lyric.InsertLine(0, LyricLine{Words: []LyricWord{{StartTime: 3000, EndTime: 4000, Word: "x"}}}); err != nil {
+		t.Fatalf("InsertLine failed: %v", err)
+	}
+	if line := lyric.LyricLines[0]; line.StartTime != 3000 || line.EndTime != 4000 || line.ID == "" {
+		t.Fatalf("inserted line envelope/ID not set: %#v", line)
+	}
+	if err := lyric.InsertLine(2, LyricLine{}); err == nil {
+		t.Fatalf("expected error for bad line index")
+	}
+	if err := lyric.RemoveLine(0); err != nil || len(lyric.LyricLines) != 0 {
+		t.Fatalf("RemoveLine failed: %v", err)
+	}
+	if err := lyric.RemoveLine(0); err == nil {
+		t.Fatalf("expected error removing from empty lyric")
+	}
+}
+
+func TestSplitTranslationDelimiter(t *testing.T) {
+	lyric := TTMLLyric{LyricLines: []LyricLine{
+		{Words: []LyricWord{{Word: "你好 / hello / there"}}},
+		{Words: []LyricWord{{Word: "再见\tbye"}}},
+		{Words: []LyricWord{{Word: "a / b"}}, TranslatedLyric: "kept"},
+		{Words: []LyricWord{{Word: "a"}, {Word: " / b"}}},
+	}}
+
+	lyric.SplitTranslationDelimiter(" / ")
+	if line := lyric.LyricLines[0]; line.Words[0].Word != "你好" || line.TranslatedLyric != "hello / there" {
+		t.Fatalf("unexpected split: %#v", line)
+	}
+	if line := lyric.LyricLines[1]; line.TranslatedLyric != "" {
+		t.Fatalf("tab line should not split on \" / \": %#v", line)
+	}
+	if line := lyric.LyricLines[2]; line.Words[0].Word != "a / b" || line.TranslatedLyric != "kept" {
+		t.Fatalf("existing translation must not be overwritten: %#v", line)
+	}
+	if line := lyric.LyricLines[3]; line.TranslatedLyric != "" {
+		t.Fatalf("multi-word line must not split: %#v", line)
+	}
+
+	lyric.SplitTranslationDelimiter("\t")
+	if line := lyric.LyricLines[1]; line.Words[0].Word != "再见" || line.TranslatedLyric != "bye" {
+		t.Fatalf("unexpected tab split: %#v", line)
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	lyric := TTMLLyric{LyricLines: []LyricLine{
+		{StartTime: 990, EndTime: 2024, Words: []LyricWord{
+			{StartTime: 1004, EndTime: 1496, Word: "a"},
+			{StartTime: 1496, EndTime: 2024, Word: "b"},
+		}},
+		{StartTime: 3003, EndTime: 3997},
+	}}
+
+	lyric.Quantize(0)
+	if lyric.LyricLines[0].Words[0].StartTime != 1004 {
+		t.Fatalf("zero step must be a no-op")
+	}
+
+	lyric.Quantize(10)
+	line := lyric.LyricLines[0]
+	if line.Words[0].StartTime != 1000 || line.Words[0].EndTime != 1500 || line.Words[1].EndTime != 2020 {
+		t.Fatalf("unexpected word timing: %v", line.Words)
+	}
+	if line.StartTime != 1000 || line.EndTime != 2020 {
+		t.Fatalf("line envelope not re-derived: %v-%v", line.StartTime, line.EndTime)
+	}
+	if sep := lyric.LyricLines[1]; sep.StartTime != 3000 || sep.EndTime != 4000 {
+		t.Fatalf("wordless line not snapped: %v-%v", sep.StartTime, sep.EndTime)
+	}
+
+	// Parsed lines carry 0-timed whitespace tokens, which must not drag the
+	// envelope to 0.
+	parsed, err := ParseLyric(`<tt xmlns="http://www.w3.org/ns/ttml"><body>` +
+		`<div><p begin="00:01.004" end="00:02.024"><span begin="00:01.004" end="00:01.496">a</span> <span begin="00:01.496" end="00:02.024">b</span></p></div>` +
+		`<div><p begin="00:03.003" end="00:04.000"><span begin="00:03.003" end="00:03.500">c</span> <span begin="00:03.500" end="00:04.000">d</span></p></div>` +
+		`</body></tt>`)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	parsed.Quantize(10)
+	var got [][2]float64
+	for _, line := range parsed.LyricLines {
+		got = append(got, [2]float64{line.StartTime, line.EndTime})
+	}
+	// The second div starts after a separator line, as the parser mirrors
+	// the writer's division split.
+	want := [][2]float64{{1000, 2020}, {0, 0}, {3000, 4000}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("quantized parsed lines = %v, want %v", got, want)
+	}
+}
+
+func TestDedupAdjacentWords(t *testing.T) {
+	lyric := TTMLLyric{LyricLines: []LyricLine{{
+		StartTime: 0,
+		EndTime:   2000,
+		Words: []LyricWord{
+			{StartTime: 0, EndTime: 300, Word: "go"},
+			{StartTime: 310, EndTime: 600, Word: "go"},
+			{StartTime: 600, EndTime: 900, Word: "go"},
+			{Word: " "},
+			{Word: " "},
+			{StartTime: 1000, EndTime: 1200, Word: "now"},
+			{StartTime: 1500, EndTime: 2000, Word: "now"},
+		},
+	}}}
+
+	if merges := lyric.DedupAdjacentWords(50); merges != 2 {
+		t.Fatalf("expected 2 merges, got %d", merges)
+	}
+	words := lyric.LyricLines[0].Words
+	if len(words) != 5 {
+		t.Fatalf("expected 5 words, got %d: %v", len(words), words)
+	}
+	if words[0].Word != "go" || words[0].StartTime != 0 || words[0].EndTime != 900 {
+		t.Fatalf("unexpected merged word: %v", words[0])
+	}
+	// The 300ms gap between the two "now" words exceeds maxGapMS.
+	if words[3].Word != "now" || words[4].Word != "now" {
+		t.Fatalf("distant duplicates must stay separate: %v", words)
+	}
+	if merges := lyric.DedupAdjacentWords(-1); merges != 0 {
+		t.Fatalf("negative maxGapMS should be a no-op, got %d merges", merges)
+	}
+}
+
+func TestDedupAdjacentWordsSkipsWhitespace(t *testing.T) {
+	lyric, err := ParseLyric(`<tt xmlns="http://www.w3.org/ns/ttml"><body><div>` +
+		`<p begin="00:01.000" end="00:03.000"><span begin="00:01.000" end="00:01.500">go</span> <span begin="00:01.520" end="00:02.000">go</span> <span begin="00:02.000" end="00:03.000">now</span></p>` +
+		`</div></body></tt>`)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if words := lyric.LyricLines[0].Words; len(words) != 5 {
+		t.Fatalf("expected whitespace tokens between the words: %#v", words)
+	}
+
+	if merges := lyric.DedupAdjacentWords(50); merges != 1 {
+		t.Fatalf("expected 1 merge, got %d", merges)
+	}
+	words := lyric.LyricLines[0].Words
+	if len(words) != 3 {
+		t.Fatalf("expected go, space, now; got %#v", words)
+	}
+	if words[0].Word != "go" || words[0].StartTime != 1000 || words[0].EndTime != 2000 {
+		t.Fatalf("unexpected merged word: %v", words[0])
+	}
+	if !words[1].IsBlank() || words[2].Word != "now" {
+		t.Fatalf("whitespace before the next word must survive: %#v", words)
+	}
+}
+
+func TestSliceAndShift(t *testing.T) {
+	lyric := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: MetaKeyMusicName, Value: []string{"Song"}}},
+		LyricLines: []LyricLine{
+			{StartTime: 0, EndTime: 1000, Words: []LyricWord{{StartTime: 0, EndTime: 1000, Word: "intro"}}},
+			{},
+			{
+				StartTime: 1000,
+				EndTime:   4000,
+				Words: []LyricWord{
+					{StartTime: 1000, EndTime: 2000, Word: "a"},
+					{Word: " "},
+					{StartTime: 2000, EndTime: 3000, Word: "b"},
+					{Word: " "},
+					{StartTime: 3000, EndTime: 4000, Word: "c"},
+				},
+			},
+			{StartTime: 4000, EndTime: 5000, Words: []LyricWord{{StartTime: 4000, EndTime: 5000, Word: "d"}}},
+			{},
+			{StartTime: 6000, EndTime: 7000, Words: []LyricWord{{StartTime: 6000, EndTime: 7000, Word: "outro"}}},
+		},
+	}
+
+	snippet := lyric.Slice(2500, 4500)
+	if len(snippet.LyricLines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %v", len(snippet.LyricLines), snippet.LyricLines)
+	}
+	partial := snippet.LyricLines[0]
+	if len(partial.Words) != 3 || partial.Words[0].Word != "b" || partial.StartTime != 2000 || partial.EndTime != 4000 {
+		t.Fatalf("unexpected partial line: %v", partial)
+	}
+	if snippet.LyricLines[1].Words[0].Word != "d" || len(snippet.Metadata) != 1 {
+		t.Fatalf("unexpected snippet: %v", snippet)
+	}
+	if len(lyric.LyricLines[2].Words) != 5 {
+		t.Fatalf("Slice must not modify the receiver")
+	}
+
+	// Separators survive only between retained lines.
+	if got := lyric.Slice(0, 1500).LyricLines; len(got) != 3 || len(got[1].Words) != 0 {
+		t.Fatalf("expected intro, separator, partial line: %v", got)
+	}
+
+	snippet.Shift(-2500)
+	if got := snippet.LyricLines[0]; got.StartTime != -500 || got.Words[0].StartTime != -500 || got.Words[2].EndTime != 1500 {
+		t.Fatalf("unexpected shifted line: %v", got)
+	}
+}
+
+func TestSortWords(t *testing.T) {
+	lyric := TTMLLyric{LyricLines: []LyricLine{{
+		StartTime: 1000,
+		EndTime:   4000,
+		Words: []LyricWord{
+			{StartTime: 3000, EndTime: 4000, Word: "three"},
+			{Word: " "},
+			{StartTime: 1000, EndTime: 2000, Word: "one"},
+			{Word: " "},
+			{StartTime: 2000, EndTime: 3000, Word: "two"},
+		},
+	}}}
+
+	issues := lyric.Validate()
+	if len(issues) != 1 || issues[0].Path != "line[0].word[2]" {
+		t.Fatalf("expected the out-of-order word flagged, got %v", issues)
+	}
+
+	lyric.LyricLines[0].SortWords()
+	if got := lyric.LyricLines[0].Words; got[0].Word != "one" || got[1].Word != " " || got[2].Word != "two" || got[3].Word != " " || got[4].Word != "three" {
+		t.Fatalf("unexpected order: %v", lyric.LyricLines[0])
+	}
+	if issues := lyric.Validate(); len(issues) != 0 {
+		t.Fatalf("sorted line still flagged: %v", issues)
+	}
+}
+
+func TestReflowByGaps(t *testing.T) {
+	word := func(text string, start, end float64) LyricWord {
+		return LyricWord{Word: text, StartTime: start, EndTime: end}
+	}
+	space := LyricWord{Word: " "}
+	lyric := TTMLLyric{LyricLines: []LyricLine{
+		{StartTime: 0, EndTime: 3000, Words: []LyricWord{
+			word("one", 0, 500), space, word("two", 600, 1000), space,
+			word("three", 2000, 2500), space,
+		}},
+		{StartTime: 2600, EndTime: 3000, IsDuet: true, Words: []LyricWord{word("four", 2600, 3000)}},
+		{},
+		{StartTime: 3100, EndTime: 3500, Words: []LyricWord{word("five", 3100, 3500)}},
+	}}
+
+	lyric.ReflowByGaps(800)
+	var got [][]string
+	for _, line := range lyric.LyricLines {
+		var texts []string
+		for _, w := range line.Words {
+			texts = append(texts, w.Word)
+		}
+		got = append(got, texts)
+	}
+	want := [][]string{{"one", " ", "two"}, {"three", " ", "four"}, nil, {"five"}}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected lines: %q", got)
+	}
+	for i := range want {
+		if len(got[i]) != len(want[i]) {
+			t.Fatalf("line %d: expected %q, got %q", i, want[i], got[i])
+		}
+		for j := range want[i] {
+			if got[i][j] != want[i][j] {
+				t.Fatalf("line %d: expected %q, got %q", i, want[i], got[i])
+			}
+		}
+	}
+	if lyric.LyricLines[0].StartTime != 0 || lyric.LyricLines[0].EndTime != 1000 ||
+		lyric.LyricLines[1].StartTime != 2000 || lyric.LyricLines[1].EndTime != 3000 {
+		t.Fatalf("envelopes not re-derived: %v", lyric.LyricLines)
+	}
+	if lyric.LyricLines[1].IsDuet {
+		t.Fatalf("a reflowed line should take the flags of its first word's line")
+	}
+
+	translated := TTMLLyric{LyricLines: []LyricLine{
+		{StartTime: 0, EndTime: 5000, TranslatedLyric: "t", Words: []LyricWord{word("a", 0, 500), word("b", 4000, 5000)}},
+	}}
+	translated.ReflowByGaps(800)
+	if len(translated.LyricLines) != 1 {
+		t.Fatalf("lyrics with translations must not be reflowed")
+	}
+}
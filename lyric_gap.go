@@ -0,0 +1,114 @@
+package ttml
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Gap is the time between two consecutive non-blank words. A negative
+// DurationMS means the words overlap.
+type Gap struct {
+	// PrevLine and PrevWord index the word before the gap.
+	PrevLine, PrevWord int
+	// Line and Word index the word after the gap.
+	Line, Word int
+	// StartMS is the end time of the word before the gap.
+	StartMS float64
+	// DurationMS is the next word's start minus StartMS.
+	DurationMS float64
+	// CrossLine reports whether the gap spans a line boundary.
+	CrossLine bool
+}
+
+// Gaps returns every gap between consecutive non-blank words, in document
+// order: within each line, and from the last word of a line to the first
+// word of the next one. Background lines overlap their main line by design,
+// so they contribute only their inner gaps. Whitespace tokens and wordless
+// lines are skipped.
+func (l TTMLLyric) Gaps() []Gap {
+	var gaps []Gap
+	prevLine, prevWord := -1, -1
+	for lineIndex, line := range l.LyricLines {
+		linePrev := -1
+		for wordIndex, word := range line.Words {
+			if word.IsBlank() {
+				continue
+			}
+			if linePrev >= 0 {
+				gaps = append(gaps, newGap(l.LyricLines, lineIndex, linePrev, lineIndex, wordIndex))
+			} else if !line.IsBG && prevLine >= 0 {
+				gaps = append(gaps, newGap(l.LyricLines, prevLine, prevWord, lineIndex, wordIndex))
+			}
+			linePrev = wordIndex
+		}
+		if linePrev >= 0 && !line.IsBG {
+			prevLine, prevWord = lineIndex, linePrev
+		}
+	}
+	return gaps
+}
+
+func newGap(lines []LyricLine, prevLine, prevWord, line, word int) Gap {
+	start := lines[prevLine].Words[prevWord].EndTime
+	return Gap{
+		PrevLine:   prevLine,
+		PrevWord:   prevWord,
+		Line:       line,
+		Word:       word,
+		StartMS:    start,
+		DurationMS: lines[line].Words[word].StartTime - start,
+		CrossLine:  prevLine != line,
+	}
+}
+
+// OverlappingLines returns the index pairs, in ascending order, of lines
+// whose [StartTime, EndTime] ranges intersect; ranges that only touch do not.
+// A main line and the background lines following it overlap by design and are
+// never reported against each other, while overlaps with any other line are.
+// Wordless lines and zero-length ranges never overlap anything.
+func (l TTMLLyric) OverlappingLines() [][2]int {
+	// group assigns each line the index of the main line it belongs to,
+	// grouping like the writer nests background lines.
+	group := make([]int, len(l.LyricLines))
+	order := make([]int, 0, len(l.LyricLines))
+	current := -1
+	for i, line := range l.LyricLines {
+		if len(line.Words) == 0 {
+			current = -1
+			continue
+		}
+		if !line.IsBG || current < 0 {
+			current = i
+		}
+		group[i] = current
+		order = append(order, i)
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(l.LyricLines[a].StartTime, l.LyricLines[b].StartTime)
+	})
+
+	// Sweep by start time, keeping the lines that have not ended yet.
+	var pairs [][2]int
+	var active []int
+	for _, i := range order {
+		line := l.LyricLines[i]
+		kept := active[:0]
+		for _, j := range active {
+			if l.LyricLines[j].EndTime <= line.StartTime {
+				continue
+			}
+			kept = append(kept, j)
+			if group[j] != group[i] && line.StartTime < line.EndTime {
+				pairs = append(pairs, [2]int{min(i, j), max(i, j)})
+			}
+		}
+		active = append(kept, i)
+	}
+	slices.SortFunc(pairs, func(a, b [2]int) int {
+		if c := cmp.Compare(a[0], b[0]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a[1], b[1])
+	})
+	return pairs
+}
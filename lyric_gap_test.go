@@ -0,0 +1,55 @@
+package ttml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGaps(t *testing.T) {
+	lyric := TTMLLyric{LyricLines: []LyricLine{
+		{Words: []LyricWord{
+			{StartTime: 0, EndTime: 500, Word: "a"},
+			{Word: " "},
+			{StartTime: 700, EndTime: 1000, Word: "b"},
+		}},
+		{IsBG: true, Words: []LyricWord{
+			{StartTime: 600, EndTime: 800, Word: "x"},
+			{StartTime: 900, EndTime: 950, Word: "y"},
+		}},
+		{},
+		{Words: []LyricWord{{StartTime: 900, EndTime: 1500, Word: "c"}}},
+	}}
+
+	want := []Gap{
+		{PrevLine: 0, PrevWord: 0, Line: 0, Word: 2, StartMS: 500, DurationMS: 200},
+		{PrevLine: 1, PrevWord: 0, Line: 1, Word: 1, StartMS: 800, DurationMS: 100},
+		{PrevLine: 0, PrevWord: 2, Line: 3, Word: 0, StartMS: 1000, DurationMS: -100, CrossLine: true},
+	}
+	if got := lyric.Gaps(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected gaps:\n got: %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestOverlappingLines(t *testing.T) {
+	line := func(start, end float64, isBG bool) LyricLine {
+		return LyricLine{StartTime: start, EndTime: end, IsBG: isBG, Words: []LyricWord{{StartTime: start, EndTime: end, Word: "x"}}}
+	}
+	lyric := TTMLLyric{LyricLines: []LyricLine{
+		line(0, 1000, false),
+		line(500, 1200, true), // background of line 0, runs past its end
+		line(600, 1100, true), // second background of line 0
+		line(1000, 2000, false),
+		line(1800, 2500, false),
+		{StartTime: 1900, EndTime: 2100},
+		line(2400, 2600, true), // follows a section break, so it is not line 4's background
+		line(2550, 2550, false),
+	}}
+	want := [][2]int{{1, 3}, {2, 3}, {3, 4}, {4, 6}}
+	if got := lyric.OverlappingLines(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected overlaps:\n got: %v\nwant: %v", got, want)
+	}
+
+	if got := (TTMLLyric{}).OverlappingLines(); got != nil {
+		t.Fatalf("expected no overlaps, got %v", got)
+	}
+}
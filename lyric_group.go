@@ -0,0 +1,73 @@
+package ttml
+
+// LineGroup is a main lyric line together with its background line.
+type LineGroup struct {
+	// Index is the position of Main in TTMLLyric.LyricLines.
+	Index int
+	Main  LyricLine
+	// Background is the line's background vocal, or nil if it has none.
+	Background *LyricLine
+}
+
+// MainLinesWithBackground pairs every main line with the background line that
+// follows it in LyricLines, hiding the adjacency convention the parser and
+// writer use. Like the writer, a background line with no main line before it
+// is treated as a main line of its own. The returned lines are copies.
+func (l TTMLLyric) MainLinesWithBackground() []LineGroup {
+	lines := l.clone().LyricLines
+	groups := make([]LineGroup, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		group := LineGroup{Index: i, Main: lines[i]}
+		if i+1 < len(lines) && lines[i+1].IsBG {
+			bg := lines[i+1]
+			group.Background = &bg
+			i++
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// SplitByAgent partitions the lines by singer, keyed by the agent id the
+// writer uses: "v1" for lead lines and "v2" for duet lines. Background lines
+// go with the main line they follow, and wordless section breaks are kept in
+// every part that has lines on both sides of them. Each part carries a copy of
+// the metadata; lines keep their timing and flags. Parts with no lines are
+// omitted.
+func (l TTMLLyric) SplitByAgent() map[string]TTMLLyric {
+	src := l.clone()
+	parts := map[string]TTMLLyric{}
+	agent := "v1"
+	// pendingBreak remembers the parts that need a section break before
+	// their next line.
+	pendingBreak := map[string]bool{}
+	for _, line := range src.LyricLines {
+		if len(line.Words) == 0 {
+			for key := range parts {
+				pendingBreak[key] = true
+			}
+			continue
+		}
+		if !line.IsBG {
+			agent = "v1"
+			if line.IsDuet {
+				agent = "v2"
+			}
+		}
+		part, ok := parts[agent]
+		if !ok {
+			part = TTMLLyric{
+				Metadata:        TTMLLyric{Metadata: src.Metadata}.clone().Metadata,
+				DocumentProfile: src.DocumentProfile,
+				WasPretty:       src.WasPretty,
+			}
+		}
+		if pendingBreak[agent] {
+			part.LyricLines = append(part.LyricLines, NewLyricLine())
+			delete(pendingBreak, agent)
+		}
+		part.LyricLines = append(part.LyricLines, line)
+		parts[agent] = part
+	}
+	return parts
+}
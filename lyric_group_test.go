@@ -0,0 +1,85 @@
+package ttml
+
+import "testing"
+
+func TestMainLinesWithBackground(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata"><body><div>` +
+		`<p begin="00:01.000" end="00:02.000"><span begin="00:01.000" end="00:02.000">one</span>` +
+		`<span ttm:role="x-bg"><span begin="00:01.500" end="00:02.000">(echo)</span></span></p>` +
+		`<p begin="00:02.000" end="00:03.000"><span begin="00:02.000" end="00:03.000">two</span></p>` +
+		`</div></body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	groups := lyric.MainLinesWithBackground()
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+	if groups[0].Index != 0 || groups[0].Main.Words[0].Word != "one" {
+		t.Fatalf("unexpected first group: %+v", groups[0])
+	}
+	if groups[0].Background == nil || groups[0].Background.Words[0].Word != "echo" {
+		t.Fatalf("first group should carry the bg line: %+v", groups[0].Background)
+	}
+	if groups[1].Index != 2 || groups[1].Main.Words[0].Word != "two" || groups[1].Background != nil {
+		t.Fatalf("unexpected second group: %+v", groups[1])
+	}
+
+	groups[0].Background.Words[0].Word = "changed"
+	if lyric.LyricLines[1].Words[0].Word != "echo" {
+		t.Fatalf("groups must not alias the lyric lines")
+	}
+
+	orphan := TTMLLyric{LyricLines: []LyricLine{{IsBG: true}, {IsBG: true}}}
+	groups = orphan.MainLinesWithBackground()
+	if len(groups) != 1 || !groups[0].Main.IsBG || groups[0].Background == nil {
+		t.Fatalf("leading bg line should become a main line: %+v", groups)
+	}
+}
+
+func TestSplitByAgent(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata" xmlns:amll="http://www.example.com/ns/amll">` +
+		`<head><metadata><ttm:agent type="person" xml:id="v1"/><ttm:agent type="other" xml:id="v2"/><amll:meta key="musicName" value="Song"/></metadata></head><body>` +
+		`<div><p begin="00:01.000" end="00:02.000" ttm:agent="v1"><span begin="00:01.000" end="00:02.000">lead</span></p>` +
+		`<p begin="00:02.000" end="00:03.000" ttm:agent="v2"><span begin="00:02.000" end="00:03.000">duet</span>` +
+		`<span ttm:role="x-bg"><span begin="00:02.500" end="00:03.000">(echo)</span></span></p></div>` +
+		`<div><p begin="00:04.000" end="00:05.000" ttm:agent="v1"><span begin="00:04.000" end="00:05.000">again</span></p></div>` +
+		`</body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	parts := lyric.SplitByAgent()
+	if len(parts) != 2 {
+		t.Fatalf("expected 2 parts, got %d", len(parts))
+	}
+
+	lead := parts["v1"]
+	if len(lead.LyricLines) != 3 || lead.LyricLines[0].Words[0].Word != "lead" ||
+		len(lead.LyricLines[1].Words) != 0 || lead.LyricLines[2].Words[0].Word != "again" {
+		t.Fatalf("unexpected lead part: %v", lead.LyricLines)
+	}
+	if lead.LyricLines[2].StartTime != 4000 || lead.LyricLines[2].EndTime != 5000 {
+		t.Fatalf("timing not preserved: %v", lead.LyricLines[2])
+	}
+
+	duet := parts["v2"]
+	if len(duet.LyricLines) != 2 || duet.LyricLines[0].Words[0].Word != "duet" || !duet.LyricLines[1].IsBG {
+		t.Fatalf("background line should follow its duet line: %v", duet.LyricLines)
+	}
+	if len(duet.Metadata) != 1 || duet.Metadata[0].Value[0] != "Song" {
+		t.Fatalf("metadata not copied: %v", duet.Metadata)
+	}
+
+	duet.Metadata[0].Value[0] = "changed"
+	if lead.Metadata[0].Value[0] != "Song" || lyric.Metadata[0].Value[0] != "Song" {
+		t.Fatalf("parts must not share metadata")
+	}
+
+	if parts := (TTMLLyric{}).SplitByAgent(); len(parts) != 0 {
+		t.Fatalf("expected no parts for an empty lyric, got %v", parts)
+	}
+}
@@ -0,0 +1,127 @@
+package ttml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// The canonical JSON types declare their fields in alphabetical order of
+// their JSON keys, which encoding/json preserves, so the output is sorted
+// without a map.
+type canonicalLyric struct {
+	LyricLines []canonicalLine     `json:"lyricLines"`
+	Metadata   []canonicalMetadata `json:"metadata"`
+}
+
+type canonicalMetadata struct {
+	Error bool     `json:"error,omitempty"`
+	Key   string   `json:"key"`
+	Value []string `json:"value"`
+}
+
+type canonicalLine struct {
+	Background      bool            `json:"background,omitempty"`
+	Duet            bool            `json:"duet,omitempty"`
+	EndMs           uint64          `json:"endMs"`
+	IgnoreSync      bool            `json:"ignoreSync,omitempty"`
+	Role            string          `json:"role,omitempty"`
+	RomanLyric      string          `json:"romanLyric,omitempty"`
+	StartMs         uint64          `json:"startMs"`
+	TranslatedLyric string          `json:"translatedLyric,omitempty"`
+	Words           []canonicalWord `json:"words"`
+}
+
+type canonicalWord struct {
+	Background   bool   `json:"background,omitempty"`
+	EmptyBeatMs  uint64 `json:"emptyBeatMs,omitempty"`
+	EndMs        uint64 `json:"endMs"`
+	Instrumental bool   `json:"instrumental,omitempty"`
+	Obscene      bool   `json:"obscene,omitempty"`
+	Phoneme      string `json:"phoneme,omitempty"`
+	RomanWarning bool   `json:"romanWarning,omitempty"`
+	RomanWord    string `json:"romanWord,omitempty"`
+	StartMs      uint64 `json:"startMs"`
+	Word         string `json:"word"`
+}
+
+// CanonicalJSON returns a diff-stable JSON form of the lyric, suitable for
+// storing in version control. It covers the fields Equal compares: keys are
+// sorted, runtime IDs and TTML-only fields are left out, false and empty
+// optional fields are omitted, and every time is an integer number of
+// milliseconds rounded to nearest as in EncodeBinary. Lyrics that are Equal
+// and whose times round alike therefore produce identical bytes.
+//
+// The output is indented with two spaces and ends with a newline. Times and
+// line roles the binary codec cannot store (negative, NaN or infinite times,
+// or a role on a background line) are an error.
+func (l TTMLLyric) CanonicalJSON() ([]byte, error) {
+	out := canonicalLyric{
+		LyricLines: make([]canonicalLine, 0, len(l.LyricLines)),
+		Metadata:   make([]canonicalMetadata, 0, len(l.Metadata)),
+	}
+	for _, meta := range l.Metadata {
+		values := meta.Value
+		if values == nil {
+			values = []string{}
+		}
+		out.Metadata = append(out.Metadata, canonicalMetadata{Error: meta.Error, Key: meta.Key, Value: values})
+	}
+
+	var err error
+	ms := func(value float64, field string) uint64 {
+		if err != nil {
+			return 0
+		}
+		var rounded uint64
+		rounded, err = toMilliseconds(value, TimeRoundingNearest, field)
+		return rounded
+	}
+	for lineIdx, line := range l.LyricLines {
+		if problem := line.roleProblem(); problem != "" && err == nil {
+			err = fmt.Errorf("lyricLines[%d]: %s", lineIdx, problem)
+		}
+		cl := canonicalLine{
+			Background:      line.IsBG,
+			Duet:            line.IsDuet,
+			EndMs:           ms(line.EndTime, fmt.Sprintf("lyricLines[%d].endTime", lineIdx)),
+			IgnoreSync:      line.IgnoreSync,
+			RomanLyric:      line.RomanLyric,
+			StartMs:         ms(line.StartTime, fmt.Sprintf("lyricLines[%d].startTime", lineIdx)),
+			TranslatedLyric: line.TranslatedLyric,
+			Words:           make([]canonicalWord, 0, len(line.Words)),
+		}
+		// Lead and background are implied by the background flag.
+		if line.Role != LineRoleLead {
+			cl.Role = line.Role.String()
+		}
+		for wordIdx, word := range line.Words {
+			field := fmt.Sprintf("lyricLines[%d].words[%d]", lineIdx, wordIdx)
+			cl.Words = append(cl.Words, canonicalWord{
+				Background:   word.Background,
+				EmptyBeatMs:  ms(normalizeEmptyBeat(word.EmptyBeat), field+".emptyBeat"),
+				EndMs:        ms(word.EndTime, field+".endTime"),
+				Instrumental: word.InstrumentalMarker,
+				Obscene:      word.Obscene,
+				Phoneme:      word.Phoneme,
+				RomanWarning: word.RomanWarning,
+				RomanWord:    word.RomanWord,
+				StartMs:      ms(word.StartTime, field+".startTime"),
+				Word:         word.Word,
+			})
+		}
+		out.LyricLines = append(out.LyricLines, cl)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
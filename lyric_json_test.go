@@ -0,0 +1,106 @@
+package ttml
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	lyric := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: MetaKeyMusicName, Value: []string{"Song <1>"}}, {Key: "empty", Error: true}},
+		LyricLines: []LyricLine{
+			{ID: "a", StartTime: 100.4, EndTime: 1000, TranslatedLyric: "翻译", Role: LineRoleHarmony, Words: []LyricWord{
+				{ID: "a-w", StartTime: 100.4, EndTime: 499.6, Word: "hi", RomanWord: "hai", EmptyBeat: math.NaN()},
+				{StartTime: 500, EndTime: 1000, Word: "there", Obscene: true, EmptyBeat: 2},
+			}},
+			{StartTime: 600, EndTime: 900, IsBG: true, Words: []LyricWord{{StartTime: 600, EndTime: 900, Word: "ooh"}}},
+		},
+	}
+	want := `{
+  "lyricLines": [
+    {
+      "endMs": 1000,
+      "role": "harmony",
+      "startMs": 100,
+      "translatedLyric": "翻译",
+      "words": [
+        {
+          "endMs": 500,
+          "romanWord": "hai",
+          "startMs": 100,
+          "word": "hi"
+        },
+        {
+          "emptyBeatMs": 2,
+          "endMs": 1000,
+          "obscene": true,
+          "startMs": 500,
+          "word": "there"
+        }
+      ]
+    },
+    {
+      "background": true,
+      "endMs": 900,
+      "startMs": 600,
+      "words": [
+        {
+          "endMs": 900,
+          "startMs": 600,
+          "word": "ooh"
+        }
+      ]
+    }
+  ],
+  "metadata": [
+    {
+      "key": "musicName",
+      "value": [
+        "Song <1>"
+      ]
+    },
+    {
+      "error": true,
+      "key": "empty",
+      "value": []
+    }
+  ]
+}
+`
+	got, err := lyric.CanonicalJSON()
+	if err != nil {
+		t.Fatalf("CanonicalJSON failed: %v", err)
+	}
+	if string(got) != want {
+		t.Fatalf("unexpected canonical JSON:\n%s", got)
+	}
+
+	// A binary round trip regenerates IDs and drops the NaN empty beat; the
+	// canonical form must not change.
+	data, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	decoded, err := DecodeBinary(data)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	again, err := decoded.CanonicalJSON()
+	if err != nil {
+		t.Fatalf("CanonicalJSON failed: %v", err)
+	}
+	if string(again) != want {
+		t.Fatalf("canonical JSON changed after binary round trip:\n%s", again)
+	}
+
+	lyric.LyricLines[1].Role = LineRoleAdLib
+	if _, err := lyric.CanonicalJSON(); err == nil {
+		t.Fatalf("expected role on a background line to be rejected")
+	}
+	lyric.LyricLines[1].Role = LineRoleLead
+
+	lyric.LyricLines[1].Words[0].StartTime = -1
+	if _, err := lyric.CanonicalJSON(); err == nil {
+		t.Fatalf("expected negative time to be rejected")
+	}
+}
@@ -0,0 +1,97 @@
+package ttml
+
+import (
+	"fmt"
+	"math"
+)
+
+// RepairNote records one fix applied by Repair.
+type RepairNote struct {
+	// Path locates the repaired item in the input, e.g. "line[3].word[1]".
+	Path    string
+	Message string
+}
+
+func (n RepairNote) String() string {
+	return n.Path + ": " + n.Message
+}
+
+// Repair returns a best-effort fixed copy of lyric together with a note for
+// every change. It clamps negative or NaN times to 0, swaps reversed
+// start/end pairs, fills or widens line envelopes so they cover their words,
+// and removes lines whose words are all blank and that carry no translation
+// or romanization. Wordless lines are kept because they mark div boundaries.
+func Repair(lyric TTMLLyric) (TTMLLyric, []RepairNote) {
+	out := lyric.clone()
+	var notes []RepairNote
+	note := func(path, format string, args ...any) {
+		notes = append(notes, RepairNote{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	fixRange := func(path string, start, end *float64) {
+		for _, field := range []struct {
+			name  string
+			value *float64
+		}{{"start", start}, {"end", end}} {
+			if *field.value < 0 || math.IsNaN(*field.value) {
+				note(path, "clamped %s time %v to 0", field.name, *field.value)
+				*field.value = 0
+			}
+		}
+		if *end < *start {
+			note(path, "swapped reversed times %s-%s", MsToTimestamp(*start), MsToTimestamp(*end))
+			*start, *end = *end, *start
+		}
+	}
+
+	lines := out.LyricLines[:0]
+	for lineIndex, line := range out.LyricLines {
+		linePath := fmt.Sprintf("line[%d]", lineIndex)
+		if len(line.Words) > 0 && line.TranslatedLyric == "" && line.RomanLyric == "" && isBlankLine(line) {
+			note(linePath, "removed empty line")
+			continue
+		}
+
+		fixRange(linePath, &line.StartTime, &line.EndTime)
+		for wordIndex := range line.Words {
+			word := &line.Words[wordIndex]
+			fixRange(fmt.Sprintf("%s.word[%d]", linePath, wordIndex), &word.StartTime, &word.EndTime)
+		}
+
+		if start, end, ok := wordEnvelope(line.Words); ok {
+			if line.StartTime == 0 && line.EndTime == 0 {
+				note(linePath, "filled missing line timing from words: %s-%s", MsToTimestamp(start), MsToTimestamp(end))
+				line.StartTime, line.EndTime = start, end
+			} else if start < line.StartTime || end > line.EndTime {
+				start, end = math.Min(start, line.StartTime), math.Max(end, line.EndTime)
+				note(linePath, "widened line timing %s-%s to %s-%s to cover words",
+					MsToTimestamp(line.StartTime), MsToTimestamp(line.EndTime), MsToTimestamp(start), MsToTimestamp(end))
+				line.StartTime, line.EndTime = start, end
+			}
+		}
+		lines = append(lines, line)
+	}
+	out.LyricLines = lines
+	return out, notes
+}
+
+func isBlankLine(line LyricLine) bool {
+	for _, word := range line.Words {
+		if !word.IsBlank() {
+			return false
+		}
+	}
+	return true
+}
+
+// wordEnvelope returns the time span covered by the non-blank words.
+func wordEnvelope(words []LyricWord) (float64, float64, bool) {
+	start, end := math.Inf(1), math.Inf(-1)
+	for _, word := range words {
+		if word.IsBlank() {
+			continue
+		}
+		start = math.Min(start, word.StartTime)
+		end = math.Max(end, word.EndTime)
+	}
+	return start, end, !math.IsInf(start, 1)
+}
@@ -0,0 +1,80 @@
+package ttml
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	lyric := TTMLLyric{LyricLines: []LyricLine{
+		{StartTime: 0, EndTime: 0, Words: []LyricWord{
+			{StartTime: 2000, EndTime: 1000, Word: "rev"},
+			{StartTime: -5, EndTime: 500, Word: "neg"},
+		}},
+		{Words: []LyricWord{{Word: " "}}},
+		{},
+		{StartTime: 3000, EndTime: 3500, Words: []LyricWord{{StartTime: 3000, EndTime: math.NaN(), Word: "nan"}, {StartTime: 3500, EndTime: 4000, Word: "late"}}},
+		{StartTime: 5000, EndTime: 6000, Words: []LyricWord{{StartTime: 5000, EndTime: 6000, Word: "fine"}}},
+	}}
+
+	repaired, notes := Repair(lyric)
+	if lyric.LyricLines[0].Words[0].StartTime != 2000 {
+		t.Fatalf("Repair must not modify its input")
+	}
+
+	wantPaths := []string{
+		"line[0].word[0]", // swapped
+		"line[0].word[1]", // clamped
+		"line[0]",         // filled
+		"line[1]",         // removed
+		"line[3].word[0]", // NaN clamped
+		"line[3].word[0]", // swapped
+		"line[3]",         // widened
+	}
+	if len(notes) != len(wantPaths) {
+		t.Fatalf("unexpected notes: %v", notes)
+	}
+	for i, path := range wantPaths {
+		if notes[i].Path != path {
+			t.Fatalf("notes[%d] = %v, want path %s", i, notes[i], path)
+		}
+	}
+
+	if len(repaired.LyricLines) != 4 {
+		t.Fatalf("expected blank line removed and separator kept, got %d lines", len(repaired.LyricLines))
+	}
+	first := repaired.LyricLines[0]
+	if first.Words[0].StartTime != 1000 || first.Words[0].EndTime != 2000 || first.Words[1].StartTime != 0 {
+		t.Fatalf("unexpected repaired words: %v", first.Words)
+	}
+	if first.StartTime != 0 || first.EndTime != 2000 {
+		t.Fatalf("line envelope not filled: %v", first)
+	}
+	if third := repaired.LyricLines[2]; third.StartTime != 0 || third.EndTime != 4000 {
+		t.Fatalf("line envelope not widened: %v", third)
+	}
+	if _, notes := Repair(repaired); len(notes) != 0 {
+		t.Fatalf("repair should be idempotent, got %v", notes)
+	}
+}
+
+func TestRepairKeepsInstrumentalMarkerLines(t *testing.T) {
+	lyric := TTMLLyric{LyricLines: []LyricLine{
+		{StartTime: 0, EndTime: 1000, Words: []LyricWord{{StartTime: 0, EndTime: 1000, Word: "intro"}}},
+		{Words: []LyricWord{{StartTime: 1000, EndTime: 8000, InstrumentalMarker: true}}},
+	}}
+
+	repaired, notes := Repair(lyric)
+	if len(repaired.LyricLines) != 2 {
+		t.Fatalf("marker-only line removed: %v", notes)
+	}
+	if marker := repaired.LyricLines[1]; marker.StartTime != 1000 || marker.EndTime != 8000 {
+		t.Fatalf("marker line envelope not filled: %v", marker)
+	}
+	if gaps := repaired.Gaps(); len(gaps) != 1 || gaps[0].Line != 1 {
+		t.Fatalf("expected a gap ending at the marker, got %v", gaps)
+	}
+	if stats := repaired.Coverage(); stats.Main.Words != 2 {
+		t.Fatalf("expected the marker counted as a word, got %d", stats.Main.Words)
+	}
+}
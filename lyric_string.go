@@ -0,0 +1,51 @@
+package ttml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// String renders the word as `[mm:ss.mmm-mm:ss.mmm] "text"`.
+func (w LyricWord) String() string {
+	return fmt.Sprintf("[%s-%s] %q", MsToTimestamp(w.StartTime), MsToTimestamp(w.EndTime), w.Word)
+}
+
+// String renders the line timing, its bg/role/duet markers and joined text, e.g.
+// `[00:01.000-00:02.500] bg "Hello world" (3 words)`.
+func (l LyricLine) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[%s-%s]", MsToTimestamp(l.StartTime), MsToTimestamp(l.EndTime)))
+	if l.IsBG {
+		sb.WriteString(" bg")
+	}
+	if l.Role == LineRoleHarmony || l.Role == LineRoleAdLib {
+		sb.WriteString(" " + l.Role.String())
+	}
+	if l.IsDuet {
+		sb.WriteString(" duet")
+	}
+	var text strings.Builder
+	for _, word := range l.Words {
+		text.WriteString(word.Word)
+	}
+	sb.WriteString(fmt.Sprintf(" %q (%d words)", text.String(), len(l.Words)))
+	return sb.String()
+}
+
+// String summarizes the lyric as its metadata and line counts plus the time
+// span covered by the lines.
+func (l TTMLLyric) String() string {
+	if len(l.LyricLines) == 0 {
+		return fmt.Sprintf("TTMLLyric{%d metadata, 0 lines}", len(l.Metadata))
+	}
+	start, end := l.LyricLines[0].StartTime, l.LyricLines[0].EndTime
+	for _, line := range l.LyricLines[1:] {
+		if len(line.Words) == 0 {
+			continue
+		}
+		start = min(start, line.StartTime)
+		end = max(end, line.EndTime)
+	}
+	return fmt.Sprintf("TTMLLyric{%d metadata, %d lines, %s-%s}",
+		len(l.Metadata), len(l.LyricLines), MsToTimestamp(start), MsToTimestamp(end))
+}
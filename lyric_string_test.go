@@ -0,0 +1,28 @@
+package ttml
+
+import "testing"
+
+func TestStringers(t *testing.T) {
+	word := LyricWord{StartTime: 1000, EndTime: 1500, Word: "Hel"}
+	if got, want := word.String(), `[00:01.000-00:01.500] "Hel"`; got != want {
+		t.Fatalf("word String() = %s, want %s", got, want)
+	}
+
+	line := LyricLine{
+		StartTime: 1000,
+		EndTime:   2000,
+		IsBG:      true,
+		Words:     []LyricWord{word, {StartTime: 1500, EndTime: 2000, Word: "lo"}},
+	}
+	if got, want := line.String(), `[00:01.000-00:02.000] bg "Hello" (2 words)`; got != want {
+		t.Fatalf("line String() = %s, want %s", got, want)
+	}
+
+	lyric := TTMLLyric{
+		Metadata:   []TTMLMetadata{{Key: MetaKeyMusicName, Value: []string{"Song"}}},
+		LyricLines: []LyricLine{line, {}, {StartTime: 3000, EndTime: 4000, Words: []LyricWord{{Word: "x"}}}},
+	}
+	if got, want := lyric.String(), "TTMLLyric{1 metadata, 3 lines, 00:01.000-00:04.000}"; got != want {
+		t.Fatalf("lyric String() = %s, want %s", got, want)
+	}
+}
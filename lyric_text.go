@@ -0,0 +1,100 @@
+package ttml
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const zeroWidthJoiner = '\u200d'
+
+// IsBlank reports whether the word is a whitespace token, i.e. empty after
+// trimming spaces. Instrumental markers are timed words and never blank.
+func (w LyricWord) IsBlank() bool {
+	return !w.InstrumentalMarker && strings.TrimSpace(w.Word) == ""
+}
+
+// IsPunctuation reports whether the word is non-blank and consists only of
+// punctuation and spaces, such as "," or " ...".
+func (w LyricWord) IsPunctuation() bool {
+	if w.IsBlank() {
+		return false
+	}
+	for _, r := range w.Word {
+		if !unicode.IsPunct(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// WordRuneCount returns the number of user-perceived characters in the word.
+// Combining marks, variation selectors and ZWJ sequences are folded into the
+// preceding character, so "é" written as e + U+0301 counts as one.
+func WordRuneCount(word LyricWord) int {
+	return clusterCount(word.Word)
+}
+
+// LongestLineRunes returns the largest per-line character count, measured the
+// same way as WordRuneCount.
+func (l TTMLLyric) LongestLineRunes() int {
+	longest := 0
+	for _, line := range l.LyricLines {
+		count := 0
+		for _, word := range line.Words {
+			count += WordRuneCount(word)
+		}
+		if count > longest {
+			longest = count
+		}
+	}
+	return longest
+}
+
+// TruncateRunes cuts text to at most limit user-perceived characters without
+// splitting a character from its combining marks.
+func TruncateRunes(text string, limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	offset := 0
+	for count := 0; count < limit && offset < len(text); count++ {
+		offset += clusterLen(text[offset:])
+	}
+	return text[:offset]
+}
+
+func clusterCount(text string) int {
+	count := 0
+	for offset := 0; offset < len(text); offset += clusterLen(text[offset:]) {
+		count++
+	}
+	return count
+}
+
+// clusterLen returns the byte length of the leading character cluster of text.
+func clusterLen(text string) int {
+	_, size := utf8.DecodeRuneInString(text)
+	offset := size
+	for offset < len(text) {
+		r, n := utf8.DecodeRuneInString(text[offset:])
+		if r == zeroWidthJoiner {
+			offset += n
+			if offset < len(text) {
+				_, joined := utf8.DecodeRuneInString(text[offset:])
+				offset += joined
+			}
+			continue
+		}
+		if !isClusterExtender(r) {
+			break
+		}
+		offset += n
+	}
+	return offset
+}
+
+func isClusterExtender(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc, unicode.Variation_Selector) ||
+		(r >= 0x1F3FB && r <= 0x1F3FF) // emoji skin tone modifiers
+}
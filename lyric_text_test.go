@@ -0,0 +1,61 @@
+package ttml
+
+import "testing"
+
+func TestWordRuneCountAndTruncate(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"hello", 5},
+		{"你好世界", 4},
+		{"cafe\u0301", 4},
+		{"\U0001F469\u200d\U0001F469\u200d\U0001F467", 1},
+		{"", 0},
+	}
+	for _, tc := range tests {
+		if got := WordRuneCount(LyricWord{Word: tc.text}); got != tc.want {
+			t.Fatalf("WordRuneCount(%q) = %d, want %d", tc.text, got, tc.want)
+		}
+	}
+
+	if got := TruncateRunes("cafe\u0301s", 4); got != "cafe\u0301" {
+		t.Fatalf("TruncateRunes split a combining mark: %q", got)
+	}
+
+	lyric := TTMLLyric{LyricLines: []LyricLine{
+		{Words: []LyricWord{{Word: "ab"}, {Word: " "}, {Word: "c"}}},
+		{Words: []LyricWord{{Word: "你好"}}},
+	}}
+	if got := lyric.LongestLineRunes(); got != 4 {
+		t.Fatalf("LongestLineRunes = %d, want 4", got)
+	}
+}
+
+func TestWordClassification(t *testing.T) {
+	tests := []struct {
+		text        string
+		blank       bool
+		punctuation bool
+	}{
+		{"", true, false},
+		{"  ", true, false},
+		{"word ", false, false},
+		{",", false, true},
+		{" ...", false, true},
+		{"。", false, true},
+		{"a,", false, false},
+	}
+	for _, tc := range tests {
+		word := LyricWord{Word: tc.text}
+		if got := word.IsBlank(); got != tc.blank {
+			t.Fatalf("IsBlank(%q) = %v, want %v", tc.text, got, tc.blank)
+		}
+		if got := word.IsPunctuation(); got != tc.punctuation {
+			t.Fatalf("IsPunctuation(%q) = %v, want %v", tc.text, got, tc.punctuation)
+		}
+	}
+	if marker := (LyricWord{InstrumentalMarker: true}); marker.IsBlank() {
+		t.Fatalf("instrumental markers must not be blank")
+	}
+}
@@ -0,0 +1,179 @@
+package ttml
+
+import "fmt"
+
+// ValidationSeverity classifies a ValidationIssue.
+type ValidationSeverity int
+
+const (
+	// ValidationWarning marks data that is usable but likely unintended.
+	ValidationWarning ValidationSeverity = iota
+	// ValidationError marks data that breaks an invariant.
+	ValidationError
+)
+
+func (s ValidationSeverity) String() string {
+	switch s {
+	case ValidationWarning:
+		return "warning"
+	case ValidationError:
+		return "error"
+	}
+	return fmt.Sprintf("ValidationSeverity(%d)", int(s))
+}
+
+// ValidationIssue describes a single problem reported by Validate.
+type ValidationIssue struct {
+	Severity ValidationSeverity
+	// Path locates the offending item, e.g. "metadata[2].value[0]".
+	Path    string
+	Message string
+}
+
+func (i ValidationIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Path, i.Message)
+}
+
+// DefaultMaxDurationMS is the timestamp bound Validate applies when
+// ValidateOptions.MaxDurationMS is zero: one day.
+const DefaultMaxDurationMS = 24 * 60 * 60 * 1000
+
+// ValidateOptions configures ValidateWithOptions.
+type ValidateOptions struct {
+	// MaxDurationMS flags timestamps later than it. Zero selects
+	// DefaultMaxDurationMS; a negative value disables the check.
+	MaxDurationMS float64
+	// MaxLineRunes flags lines with more user-perceived characters than it,
+	// counted as by WordRuneCount. Zero disables the check.
+	MaxLineRunes int
+}
+
+// Validate checks the lyric for data hygiene problems and returns every issue
+// found, in document order. A nil result means nothing was reported.
+func (l TTMLLyric) Validate() []ValidationIssue {
+	return l.ValidateWithOptions(ValidateOptions{})
+}
+
+// ValidateWithOptions is Validate with configurable thresholds.
+func (l TTMLLyric) ValidateWithOptions(opts ValidateOptions) []ValidationIssue {
+	var issues []ValidationIssue
+	issues = append(issues, validateDuplicateMetadata(l.Metadata)...)
+	maxDuration := opts.MaxDurationMS
+	if maxDuration == 0 {
+		maxDuration = DefaultMaxDurationMS
+	}
+	if maxDuration > 0 {
+		issues = append(issues, validateMaxDuration(l.LyricLines, maxDuration)...)
+	}
+	issues = append(issues, validateWordOrder(l.LyricLines)...)
+	issues = append(issues, validateLineRoles(l.LyricLines)...)
+	if opts.MaxLineRunes > 0 {
+		issues = append(issues, l.CheckLineLengths(opts.MaxLineRunes)...)
+	}
+	return issues
+}
+
+// CheckLineLengths reports every line longer than maxRunes user-perceived
+// characters, e.g. before delivering to fixed-width subtitle boxes. A
+// non-positive maxRunes reports nothing.
+func (l TTMLLyric) CheckLineLengths(maxRunes int) []ValidationIssue {
+	if maxRunes <= 0 {
+		return nil
+	}
+	var issues []ValidationIssue
+	for lineIndex, line := range l.LyricLines {
+		count := 0
+		for _, word := range line.Words {
+			count += WordRuneCount(word)
+		}
+		if count > maxRunes {
+			issues = append(issues, ValidationIssue{
+				Severity: ValidationWarning,
+				Path:     fmt.Sprintf("line[%d]", lineIndex),
+				Message:  fmt.Sprintf("line has %d characters, exceeds maximum %d", count, maxRunes),
+			})
+		}
+	}
+	return issues
+}
+
+func validateDuplicateMetadata(metadata []TTMLMetadata) []ValidationIssue {
+	type pair struct{ key, value string }
+	first := map[pair]string{}
+	var issues []ValidationIssue
+	for metaIndex, meta := range metadata {
+		for valueIndex, value := range meta.Value {
+			path := fmt.Sprintf("metadata[%d].value[%d]", metaIndex, valueIndex)
+			p := pair{meta.Key, value}
+			if prev, ok := first[p]; ok {
+				issues = append(issues, ValidationIssue{
+					Severity: ValidationWarning,
+					Path:     path,
+					Message:  fmt.Sprintf("duplicate metadata %q=%q (first at %s)", meta.Key, value, prev),
+				})
+				continue
+			}
+			first[p] = path
+		}
+	}
+	return issues
+}
+
+// validateLineRoles flags lines whose Role cannot be stored, such as a
+// background line that is also a harmony part.
+func validateLineRoles(lines []LyricLine) []ValidationIssue {
+	var issues []ValidationIssue
+	for lineIndex, line := range lines {
+		if problem := line.roleProblem(); problem != "" {
+			issues = append(issues, ValidationIssue{
+				Severity: ValidationError,
+				Path:     fmt.Sprintf("line[%d]", lineIndex),
+				Message:  problem,
+			})
+		}
+	}
+	return issues
+}
+
+// validateWordOrder flags non-blank words that start before the preceding
+// non-blank word of their line; LyricLine.SortWords fixes them.
+func validateWordOrder(lines []LyricLine) []ValidationIssue {
+	var issues []ValidationIssue
+	for lineIndex, line := range lines {
+		prev := -1
+		for wordIndex, word := range line.Words {
+			if word.IsBlank() {
+				continue
+			}
+			if prev >= 0 && word.StartTime < line.Words[prev].StartTime {
+				issues = append(issues, ValidationIssue{
+					Severity: ValidationWarning,
+					Path:     fmt.Sprintf("line[%d].word[%d]", lineIndex, wordIndex),
+					Message:  fmt.Sprintf("word starts at %s, before word[%d] at %s", MsToTimestamp(word.StartTime), prev, MsToTimestamp(line.Words[prev].StartTime)),
+				})
+			}
+			prev = wordIndex
+		}
+	}
+	return issues
+}
+
+func validateMaxDuration(lines []LyricLine, maxDuration float64) []ValidationIssue {
+	var issues []ValidationIssue
+	check := func(path string, start, end float64) {
+		if start > maxDuration || end > maxDuration {
+			issues = append(issues, ValidationIssue{
+				Severity: ValidationWarning,
+				Path:     path,
+				Message:  fmt.Sprintf("time %s-%s exceeds maximum %s", MsToTimestamp(start), MsToTimestamp(end), MsToTimestamp(maxDuration)),
+			})
+		}
+	}
+	for lineIndex, line := range lines {
+		check(fmt.Sprintf("line[%d]", lineIndex), line.StartTime, line.EndTime)
+		for wordIndex, word := range line.Words {
+			check(fmt.Sprintf("line[%d].word[%d]", lineIndex, wordIndex), word.StartTime, word.EndTime)
+		}
+	}
+	return issues
+}
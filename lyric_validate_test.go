@@ -0,0 +1,94 @@
+package ttml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDedupMetadataAndValidate(t *testing.T) {
+	lyric := TTMLLyric{
+		Metadata: []TTMLMetadata{
+			{Key: MetaKeyArtists, Value: []string{"A", "B"}},
+			{Key: MetaKeyAlbum, Value: []string{"X"}},
+			{Key: MetaKeyArtists, Value: []string{"A", "C"}},
+			{Key: MetaKeyAlbum, Value: []string{"X"}},
+		},
+	}
+
+	issues := lyric.Validate()
+	if len(issues) != 2 {
+		t.Fatalf("expected 2 duplicate warnings, got %v", issues)
+	}
+	if issues[0].Severity != ValidationWarning || issues[0].Path != "metadata[2].value[0]" {
+		t.Fatalf("unexpected issue: %v", issues[0])
+	}
+
+	lyric.DedupMetadata()
+	want := []TTMLMetadata{
+		{Key: MetaKeyArtists, Value: []string{"A", "B"}},
+		{Key: MetaKeyAlbum, Value: []string{"X"}},
+		{Key: MetaKeyArtists, Value: []string{"C"}},
+	}
+	if !reflect.DeepEqual(lyric.Metadata, want) {
+		t.Fatalf("unexpected dedup result: %#v", lyric.Metadata)
+	}
+	if issues := lyric.Validate(); len(issues) != 0 {
+		t.Fatalf("expected no issues after dedup, got %v", issues)
+	}
+}
+
+func TestValidateMaxDuration(t *testing.T) {
+	lyric := TTMLLyric{LyricLines: []LyricLine{
+		{StartTime: 0, EndTime: 1000, Words: []LyricWord{{StartTime: 0, EndTime: 1000, Word: "ok"}}},
+		{StartTime: 1000, EndTime: 9999*60*1000 + 59999, Words: []LyricWord{{StartTime: 1000, EndTime: 9999*60*1000 + 59999, Word: "bad"}}},
+	}}
+	issues := lyric.Validate()
+	if len(issues) != 2 || issues[0].Path != "line[1]" || issues[1].Path != "line[1].word[0]" {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+	if issues := lyric.ValidateWithOptions(ValidateOptions{MaxDurationMS: -1}); len(issues) != 0 {
+		t.Fatalf("negative bound should disable the check: %v", issues)
+	}
+	if issues := lyric.ValidateWithOptions(ValidateOptions{MaxDurationMS: 500}); len(issues) != 4 {
+		t.Fatalf("expected every line and word flagged, got %v", issues)
+	}
+}
+
+func TestParseMaxDurationMS(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml"><body><div>` +
+		`<p begin="00:01.000" end="9999:59.999"><span begin="00:01.000" end="9999:59.999">x</span></p>` +
+		`</div></body></tt>`
+	if _, err := ParseLyric(input); err != nil {
+		t.Fatalf("default parse should accept large times: %v", err)
+	}
+	if _, err := ParseLyricWithOptions(input, ParseOptions{MaxDurationMS: DefaultMaxDurationMS}); err == nil {
+		t.Fatalf("expected bounded parse to reject 9999 minutes")
+	}
+}
+
+func TestCheckLineLengths(t *testing.T) {
+	lyric := TTMLLyric{LyricLines: []LyricLine{
+		{Words: []LyricWord{{Word: "short"}}},
+		// "e" + U+0301 is one character, so this line has 6.
+		{Words: []LyricWord{{Word: "café"}, {Word: " "}, {Word: "ok"}}},
+		{Words: []LyricWord{{Word: "你好世界"}, {Word: "再见"}}},
+	}}
+
+	issues := lyric.CheckLineLengths(5)
+	if len(issues) != 2 || issues[0].Path != "line[1]" || issues[1].Path != "line[2]" {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+	if issues[0].Message != "line has 7 characters, exceeds maximum 5" {
+		t.Fatalf("unexpected message: %q", issues[0].Message)
+	}
+	if issues := lyric.CheckLineLengths(6); len(issues) != 1 || issues[0].Path != "line[1]" {
+		t.Fatalf("unexpected issues at 6: %v", issues)
+	}
+
+	if issues := lyric.Validate(); len(issues) != 0 {
+		t.Fatalf("line length check must be opt-in: %v", issues)
+	}
+	if issues := lyric.ValidateWithOptions(ValidateOptions{MaxLineRunes: 6}); len(issues) != 1 {
+		t.Fatalf("expected ValidateWithOptions to report the long line: %v", issues)
+	}
+}
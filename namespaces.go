@@ -6,4 +6,5 @@ const (
 	nsAMLL   = "http://www.example.com/ns/amll"
 	nsItunes = "http://music.apple.com/lyric-ttml-internal"
 	nsXML    = "http://www.w3.org/XML/1998/namespace"
+	nsTTP    = "http://www.w3.org/ns/ttml#parameter"
 )
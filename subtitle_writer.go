@@ -0,0 +1,309 @@
+package ttml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// JoinMode controls how the subtitle exporters join the words of a line.
+type JoinMode int
+
+const (
+	// JoinAsIs concatenates words verbatim, relying on whitespace tokens.
+	JoinAsIs JoinMode = iota
+	// JoinSpace ignores whitespace tokens and joins the trimmed words with a
+	// single space, as for Latin scripts.
+	JoinSpace
+	// JoinNone ignores whitespace tokens and joins the trimmed words directly,
+	// as for CJK.
+	JoinNone
+)
+
+// DefaultMinCueDurationMS is a suggested SubtitleOptions.MinCueDurationMS:
+// long enough for a short cue to be read.
+const DefaultMinCueDurationMS = 800
+
+// SubtitleOptions controls the LRC, SRT and VTT exporters.
+type SubtitleOptions struct {
+	// JoinMode selects how words are joined into line text.
+	JoinMode JoinMode
+	// MinCueDurationMS, when positive, extends the end of shorter SRT/VTT
+	// cues, such as zero-duration lines that players would drop. Cues may then
+	// overlap the next one. LRC output has no end times and ignores it.
+	MinCueDurationMS float64
+}
+
+// cueEnd returns the end time to write for line, applying the minimum cue
+// duration of opts.
+func (opts SubtitleOptions) cueEnd(line LyricLine) float64 {
+	if opts.MinCueDurationMS > 0 && line.Duration() < opts.MinCueDurationMS {
+		return line.StartTime + opts.MinCueDurationMS
+	}
+	return line.EndTime
+}
+
+// ExportLRCText converts a TTMLLyric into line-level LRC text.
+// Background lines are written as their own entries wrapped in parentheses;
+// wordless separator lines are skipped.
+func ExportLRCText(ttmlLyric TTMLLyric) string {
+	return ExportLRCTextWithOptions(ttmlLyric, SubtitleOptions{})
+}
+
+// ExportLRCTextWithOptions is ExportLRCText with configurable word joining.
+func ExportLRCTextWithOptions(ttmlLyric TTMLLyric, opts SubtitleOptions) string {
+	var sb strings.Builder
+	for _, meta := range ttmlLyric.Metadata {
+		tag := lrcMetadataTag(meta.Key)
+		if tag == "" || len(meta.Value) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("[%s:%s]\n", tag, strings.Join(meta.Value, "/")))
+	}
+	for _, line := range ttmlLyric.LyricLines {
+		if len(line.Words) == 0 {
+			continue
+		}
+		sb.WriteString("[")
+		sb.WriteString(formatLRCTimestamp(line.StartTime))
+		sb.WriteString("]")
+		sb.WriteString(subtitleLineText(line, opts.JoinMode))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// ExportSRTText converts a TTMLLyric into SubRip subtitles, one cue per line.
+// A line's translation, when present, is written as a second cue row.
+func ExportSRTText(ttmlLyric TTMLLyric) string {
+	return ExportSRTTextWithOptions(ttmlLyric, SubtitleOptions{})
+}
+
+// ExportSRTTextWithOptions is ExportSRTText with configurable word joining
+// and minimum cue duration.
+func ExportSRTTextWithOptions(ttmlLyric TTMLLyric, opts SubtitleOptions) string {
+	var sb strings.Builder
+	index := 0
+	for _, line := range ttmlLyric.LyricLines {
+		if len(line.Words) == 0 {
+			continue
+		}
+		index++
+		if index > 1 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(strconv.Itoa(index))
+		sb.WriteString("\n")
+		sb.WriteString(formatCueTimestamp(line.StartTime, ','))
+		sb.WriteString(" --> ")
+		sb.WriteString(formatCueTimestamp(opts.cueEnd(line), ','))
+		sb.WriteString("\n")
+		writeCueText(&sb, line, opts.JoinMode)
+	}
+	return sb.String()
+}
+
+// ExportVTTText converts a TTMLLyric into WebVTT subtitles, one cue per line.
+// A line's translation, when present, is written as a second cue row.
+func ExportVTTText(ttmlLyric TTMLLyric) string {
+	return ExportVTTTextWithOptions(ttmlLyric, SubtitleOptions{})
+}
+
+// ExportVTTTextWithOptions is ExportVTTText with configurable word joining
+// and minimum cue duration.
+func ExportVTTTextWithOptions(ttmlLyric TTMLLyric, opts SubtitleOptions) string {
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n")
+	for _, line := range ttmlLyric.LyricLines {
+		if len(line.Words) == 0 {
+			continue
+		}
+		sb.WriteString("\n")
+		sb.WriteString(formatCueTimestamp(line.StartTime, '.'))
+		sb.WriteString(" --> ")
+		sb.WriteString(formatCueTimestamp(opts.cueEnd(line), '.'))
+		sb.WriteString("\n")
+		writeCueText(&sb, line, opts.JoinMode)
+	}
+	return sb.String()
+}
+
+// assHeader is the fixed style block of ExportASSText: "Default" for main
+// lines and a smaller, dimmer "Background" for background lines.
+const assHeader = `[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,48,&H00FFFFFF,&H00808080,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,0,2,10,10,40,1
+Style: Background,Arial,36,&H00C0C0C0,&H00606060,&H00000000,&H00000000,0,1,0,0,100,100,0,0,1,2,0,2,10,10,100,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`
+
+// ExportASSText converts a TTMLLyric into an Advanced SubStation Alpha
+// karaoke script. Each line becomes a Dialogue event spanning the line, with
+// a {\k<centiseconds>} tag before every timed word (and before gaps between
+// words); empty beats are kept as empty \k segments. Background lines use
+// the "Background" style; wordless separator lines are skipped.
+func ExportASSText(ttmlLyric TTMLLyric) string {
+	var sb strings.Builder
+	sb.WriteString("[Script Info]\n")
+	for _, meta := range ttmlLyric.Metadata {
+		if meta.Key == MetaKeyMusicName && len(meta.Value) > 0 {
+			sb.WriteString("Title: " + assText(meta.Value[0]) + "\n")
+			break
+		}
+	}
+	sb.WriteString("ScriptType: v4.00+\n\n")
+	sb.WriteString(assHeader)
+	for _, line := range ttmlLyric.LyricLines {
+		if len(line.Words) == 0 {
+			continue
+		}
+		style := "Default"
+		if line.IsBG {
+			style = "Background"
+		}
+		text, startCS := assKaraokeText(line)
+		sb.WriteString(fmt.Sprintf("Dialogue: 0,%s,%s,%s,,0,0,0,,", formatASSCentiseconds(startCS), formatASSTimestamp(line.EndTime), style))
+		sb.WriteString(text)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// assKaraokeText renders the words of a line with \k tags and returns the
+// event start in centiseconds. Durations are taken from rounded absolute
+// centiseconds so they never drift from the word timestamps; whitespace
+// tokens join the preceding syllable.
+//
+// A word's EmptyBeat becomes its own empty \k segment right before the word,
+// carved from the preceding gap. The event starts early enough for the first
+// word's empty beat to be shown in full.
+func assKaraokeText(line LyricLine) (string, int64) {
+	lineStart := int64(math.Round(clampSubtitleTime(line.StartTime) / 10))
+	for _, word := range line.Words {
+		if word.IsBlank() {
+			continue
+		}
+		if countdown := word.StartTime - normalizeEmptyBeat(word.EmptyBeat); countdown < line.StartTime {
+			lineStart = int64(math.Round(clampSubtitleTime(countdown) / 10))
+		}
+		break
+	}
+
+	var sb strings.Builder
+	cursor := lineStart
+	for _, word := range line.Words {
+		if word.IsBlank() {
+			sb.WriteString(assText(word.Word))
+			continue
+		}
+		start := int64(math.Round(clampSubtitleTime(word.StartTime) / 10))
+		end := int64(math.Round(clampSubtitleTime(word.EndTime) / 10))
+		emptyStart := start
+		if emptyBeat := normalizeEmptyBeat(word.EmptyBeat); emptyBeat > 0 {
+			emptyStart = int64(math.Round(clampSubtitleTime(word.StartTime-emptyBeat) / 10))
+			if emptyStart < cursor {
+				emptyStart = cursor
+			}
+		}
+		if emptyStart > cursor {
+			sb.WriteString(fmt.Sprintf("{\\k%d}", emptyStart-cursor))
+			cursor = emptyStart
+		}
+		if start > cursor {
+			sb.WriteString(fmt.Sprintf("{\\k%d}", start-cursor))
+			cursor = start
+		}
+		k := end - cursor
+		if k < 0 {
+			k = 0
+		}
+		sb.WriteString(fmt.Sprintf("{\\k%d}", k))
+		sb.WriteString(assText(word.Word))
+		cursor += k
+	}
+	return sb.String(), lineStart
+}
+
+// assText keeps text on one event line; ASS marks hard breaks as \N.
+func assText(text string) string {
+	return strings.NewReplacer("\r\n", "\\N", "\n", "\\N", "\r", "").Replace(text)
+}
+
+// formatASSTimestamp renders H:MM:SS.cc as used by ASS events.
+func formatASSTimestamp(timeMS float64) string {
+	return formatASSCentiseconds(int64(math.Round(clampSubtitleTime(timeMS) / 10)))
+}
+
+func formatASSCentiseconds(cs int64) string {
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
+
+func writeCueText(sb *strings.Builder, line LyricLine, mode JoinMode) {
+	sb.WriteString(subtitleLineText(line, mode))
+	sb.WriteString("\n")
+	if translated := strings.TrimSpace(line.TranslatedLyric); translated != "" {
+		sb.WriteString(translated)
+		sb.WriteString("\n")
+	}
+}
+
+// subtitleLineText joins the words of a line into a single row of text.
+func subtitleLineText(line LyricLine, mode JoinMode) string {
+	var sb strings.Builder
+	for _, word := range line.Words {
+		if mode == JoinAsIs {
+			sb.WriteString(word.Word)
+			continue
+		}
+		trimmed := strings.TrimSpace(word.Word)
+		if trimmed == "" {
+			continue
+		}
+		if mode == JoinSpace && sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(trimmed)
+	}
+	text := strings.TrimSpace(sb.String())
+	if line.IsBG {
+		text = "(" + text + ")"
+	}
+	return text
+}
+
+// lrcMetadataTag maps well-known metadata keys to standard LRC ID tags.
+func lrcMetadataTag(key string) string {
+	switch key {
+	case MetaKeyMusicName:
+		return "ti"
+	case MetaKeyArtists:
+		return "ar"
+	case MetaKeyAlbum:
+		return "al"
+	case MetaKeySongwriter:
+		return "au"
+	}
+	return ""
+}
+
+// formatLRCTimestamp renders mm:ss.xx with centisecond precision.
+func formatLRCTimestamp(timeMS float64) string {
+	cs := int64(math.Round(clampSubtitleTime(timeMS) / 10))
+	return fmt.Sprintf("%02d:%02d.%02d", cs/6000, cs/100%60, cs%100)
+}
+
+// formatCueTimestamp renders HH:MM:SS<sep>mmm as used by SRT (',') and VTT ('.').
+func formatCueTimestamp(timeMS float64, sep byte) string {
+	ms := int64(math.Round(clampSubtitleTime(timeMS)))
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+func clampSubtitleTime(timeMS float64) float64 {
+	if timeMS < 0 || math.IsNaN(timeMS) || math.IsInf(timeMS, 0) {
+		return 0
+	}
+	return timeMS
+}
@@ -0,0 +1,170 @@
+package ttml
+
+import (
+	"strings"
+	"testing"
+)
+
+func subtitleTestLyric() TTMLLyric {
+	return TTMLLyric{
+		Metadata: []TTMLMetadata{
+			{Key: MetaKeyMusicName, Value: []string{"Song"}},
+			{Key: MetaKeyArtists, Value: []string{"A", "B"}},
+		},
+		LyricLines: []LyricLine{
+			{
+				StartTime:       1234,
+				EndTime:         3000,
+				TranslatedLyric: "hello-cn",
+				Words: []LyricWord{
+					{StartTime: 1234, EndTime: 2000, Word: "Hel"},
+					{StartTime: 2000, EndTime: 3000, Word: "lo"},
+				},
+			},
+			{
+				IsBG:      true,
+				StartTime: 2000,
+				EndTime:   2500,
+				Words:     []LyricWord{{StartTime: 2000, EndTime: 2500, Word: "yeah"}},
+			},
+			{},
+			{
+				StartTime: 3723456,
+				EndTime:   3724000,
+				Words:     []LyricWord{{StartTime: 3723456, EndTime: 3724000, Word: "end"}},
+			},
+		},
+	}
+}
+
+func TestExportLRCText(t *testing.T) {
+	want := "[ti:Song]\n[ar:A/B]\n[00:01.23]Hello\n[00:02.00](yeah)\n[62:03.46]end\n"
+	if got := ExportLRCText(subtitleTestLyric()); got != want {
+		t.Fatalf("unexpected LRC:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestExportSRTAndVTTText(t *testing.T) {
+	wantSRT := "1\n00:00:01,234 --> 00:00:03,000\nHello\nhello-cn\n" +
+		"\n2\n00:00:02,000 --> 00:00:02,500\n(yeah)\n" +
+		"\n3\n01:02:03,456 --> 01:02:04,000\nend\n"
+	if got := ExportSRTText(subtitleTestLyric()); got != wantSRT {
+		t.Fatalf("unexpected SRT:\n%q\nwant:\n%q", got, wantSRT)
+	}
+
+	wantVTT := "WEBVTT\n" +
+		"\n00:00:01.234 --> 00:00:03.000\nHello\nhello-cn\n" +
+		"\n00:00:02.000 --> 00:00:02.500\n(yeah)\n" +
+		"\n01:02:03.456 --> 01:02:04.000\nend\n"
+	if got := ExportVTTText(subtitleTestLyric()); got != wantVTT {
+		t.Fatalf("unexpected VTT:\n%q\nwant:\n%q", got, wantVTT)
+	}
+}
+
+func TestExportASSText(t *testing.T) {
+	got := ExportASSText(subtitleTestLyric())
+	if !strings.HasPrefix(got, "[Script Info]\nTitle: Song\nScriptType: v4.00+\n\n[V4+ Styles]\n") {
+		t.Fatalf("unexpected ASS header:\n%s", got)
+	}
+	wantEvents := "Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n" +
+		"Dialogue: 0,0:00:01.23,0:00:03.00,Default,,0,0,0,,{\\k77}Hel{\\k100}lo\n" +
+		"Dialogue: 0,0:00:02.00,0:00:02.50,Background,,0,0,0,,{\\k50}yeah\n" +
+		"Dialogue: 0,1:02:03.46,1:02:04.00,Default,,0,0,0,,{\\k54}end\n"
+	if !strings.HasSuffix(got, wantEvents) {
+		t.Fatalf("unexpected ASS events:\n%q\nwant suffix:\n%q", got, wantEvents)
+	}
+
+	gap := TTMLLyric{LyricLines: []LyricLine{{
+		StartTime: 0,
+		EndTime:   1000,
+		Words: []LyricWord{
+			{StartTime: 100, EndTime: 400, Word: "a"},
+			{Word: " "},
+			{StartTime: 600, EndTime: 1000, Word: "b"},
+		},
+	}}}
+	if got, _ := assKaraokeText(gap.LyricLines[0]); got != "{\\k10}{\\k30}a {\\k20}{\\k40}b" {
+		t.Fatalf("unexpected karaoke text: %q", got)
+	}
+}
+
+func TestExportASSTextEmptyBeat(t *testing.T) {
+	line := LyricLine{
+		StartTime: 1000,
+		EndTime:   2000,
+		Words: []LyricWord{
+			{StartTime: 1000, EndTime: 1400, Word: "a", EmptyBeat: 300},
+			{StartTime: 1600, EndTime: 2000, Word: "b", EmptyBeat: 100},
+		},
+	}
+	text, start := assKaraokeText(line)
+	// The first countdown starts the event 300ms early; the second one is
+	// carved out of the 200ms gap before "b".
+	if start != 70 || text != "{\\k30}{\\k40}a{\\k10}{\\k10}{\\k40}b" {
+		t.Fatalf("unexpected karaoke text: %q (start %d)", text, start)
+	}
+
+	out := ExportASSText(TTMLLyric{LyricLines: []LyricLine{line}})
+	if !strings.Contains(out, "Dialogue: 0,0:00:00.70,0:00:02.00,Default,") {
+		t.Fatalf("event should start at the countdown:\n%s", out)
+	}
+}
+
+func TestExportLRCTextJoinMode(t *testing.T) {
+	lyric := TTMLLyric{LyricLines: []LyricLine{{
+		StartTime: 1000,
+		EndTime:   2000,
+		Words: []LyricWord{
+			{StartTime: 1000, EndTime: 1500, Word: "Hello"},
+			{StartTime: 1500, EndTime: 2000, Word: "world "},
+		},
+	}}}
+
+	tests := []struct {
+		mode JoinMode
+		want string
+	}{
+		{JoinAsIs, "[00:01.00]Helloworld\n"},
+		{JoinSpace, "[00:01.00]Hello world\n"},
+		{JoinNone, "[00:01.00]Helloworld\n"},
+	}
+	for _, tt := range tests {
+		if got := ExportLRCTextWithOptions(lyric, SubtitleOptions{JoinMode: tt.mode}); got != tt.want {
+			t.Fatalf("JoinMode %d: got %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+
+	spaced := lyric
+	spaced.LyricLines = []LyricLine{{
+		StartTime: 1000,
+		EndTime:   2000,
+		Words:     []LyricWord{{Word: "你"}, {Word: " "}, {Word: "好"}},
+	}}
+	if got := ExportSRTTextWithOptions(spaced, SubtitleOptions{JoinMode: JoinNone}); !strings.Contains(got, "\n你好\n") {
+		t.Fatalf("JoinNone should drop whitespace tokens: %q", got)
+	}
+}
+
+func TestExportSubtitleMinCueDuration(t *testing.T) {
+	lyric := TTMLLyric{LyricLines: []LyricLine{
+		{StartTime: 1000, EndTime: 1000, Words: []LyricWord{{StartTime: 1000, EndTime: 1000, Word: "blip"}}},
+		{StartTime: 2000, EndTime: 4000, Words: []LyricWord{{StartTime: 2000, EndTime: 4000, Word: "long"}}},
+	}}
+
+	if got := ExportSRTText(lyric); !strings.Contains(got, "00:00:01,000 --> 00:00:01,000\n") {
+		t.Fatalf("default should keep the zero-duration cue: %q", got)
+	}
+
+	opts := SubtitleOptions{MinCueDurationMS: DefaultMinCueDurationMS}
+	srt := ExportSRTTextWithOptions(lyric, opts)
+	if !strings.Contains(srt, "00:00:01,000 --> 00:00:01,800\nblip\n") {
+		t.Fatalf("zero-duration SRT cue not extended: %q", srt)
+	}
+	if !strings.Contains(srt, "00:00:02,000 --> 00:00:04,000\nlong\n") {
+		t.Fatalf("long SRT cue changed: %q", srt)
+	}
+	vtt := ExportVTTTextWithOptions(lyric, SubtitleOptions{MinCueDurationMS: 1500})
+	if !strings.Contains(vtt, "00:00:01.000 --> 00:00:02.500\nblip\n") {
+		t.Fatalf("zero-duration VTT cue not extended: %q", vtt)
+	}
+}
@@ -3,47 +3,97 @@ package ttml
 import (
 	"fmt"
 	"math"
-	"regexp"
 	"strconv"
-	"strings"
 )
 
-var timeRegexp = regexp.MustCompile(`^(((\d+):)?(\d+):)?((\d+)([.:](\d{1,3}))?)$`)
-
 // ParseTimespan parses a TTML time string into milliseconds.
 // It mirrors the TS parseTimespan behavior.
 func ParseTimespan(timeSpan string) (float64, error) {
-	matches := timeRegexp.FindStringSubmatch(timeSpan)
-	if matches == nil {
+	ms, ok := scanTimespan(timeSpan)
+	if !ok {
 		return 0, fmt.Errorf("时间戳字符串解析失败：%s", timeSpan)
 	}
+	return ms, nil
+}
 
-	getInt := func(idx int) int64 {
-		if idx >= len(matches) || matches[idx] == "" {
-			return 0
+// ParseTimespanBatch parses every value with ParseTimespan into one
+// preallocated slice. The first invalid value aborts the batch.
+func ParseTimespanBatch(values []string) ([]float64, error) {
+	out := make([]float64, len(values))
+	for i, value := range values {
+		ms, ok := scanTimespan(value)
+		if !ok {
+			return nil, fmt.Errorf("values[%d]: 时间戳字符串解析失败：%s", i, value)
 		}
-		v, _ := strconv.ParseInt(matches[idx], 10, 64)
-		return v
+		out[i] = ms
 	}
+	return out, nil
+}
 
-	hour := getInt(3)
-	min := getInt(4)
-	sec := getInt(6)
+// scanTimespan is an allocation-free equivalent of matching
+// ^(((\d+):)?(\d+):)?((\d+)([.:](\d{1,3}))?)$ and reading hours, minutes,
+// seconds and a fraction padded to milliseconds. Ambiguous inputs resolve the
+// way the regexp did: "a:b" is minutes:seconds, "a:b:c" is h:m:s, and a
+// trailing ":" or "." group is only a fraction when nothing else fits.
+func scanTimespan(text string) (float64, bool) {
+	var groups [4]string
+	var seps [3]byte
+	n := 0
+	start := 0
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && text[i] >= '0' && text[i] <= '9' {
+			continue
+		}
+		if i == start || n == len(groups) {
+			return 0, false
+		}
+		groups[n] = text[start:i]
+		n++
+		if i < len(text) {
+			if text[i] != ':' && text[i] != '.' || n == len(groups) {
+				return 0, false
+			}
+			seps[n-1] = text[i]
+		}
+		start = i + 1
+	}
 
-	fracStr := ""
-	if len(matches) > 8 {
-		fracStr = matches[8]
+	isFrac := func(idx int) bool { return len(groups[idx]) <= 3 }
+	var hour, min, sec, frac string
+	switch {
+	case n >= 3 && seps[0] == ':' && seps[1] == ':' && (n == 3 || isFrac(3)):
+		hour, min, sec = groups[0], groups[1], groups[2]
+		if n == 4 {
+			frac = groups[3]
+		}
+	case n >= 2 && n <= 3 && seps[0] == ':' && (n == 2 || isFrac(2)):
+		min, sec = groups[0], groups[1]
+		if n == 3 {
+			frac = groups[2]
+		}
+	case n <= 2 && (n == 1 || isFrac(1)):
+		sec = groups[0]
+		if n == 2 {
+			frac = groups[1]
+		}
+	default:
+		return 0, false
 	}
-	if fracStr == "" {
-		fracStr = "0"
+
+	getInt := func(digits string) int64 {
+		if digits == "" {
+			return 0
+		}
+		v, _ := strconv.ParseInt(digits, 10, 64)
+		return v
 	}
-	if len(fracStr) < 3 {
-		fracStr = fracStr + strings.Repeat("0", 3-len(fracStr))
+	fracMS := getInt(frac)
+	for i := len(frac); i > 0 && i < 3; i++ {
+		fracMS *= 10
 	}
-	frac, _ := strconv.ParseInt(fracStr, 10, 64)
 
-	total := (hour*3600 + min*60 + sec) * 1000
-	return float64(total + frac), nil
+	total := (getInt(hour)*3600 + getInt(min)*60 + getInt(sec)) * 1000
+	return float64(total + fracMS), true
 }
 
 // MsToTimestamp converts milliseconds to a TTML time string.
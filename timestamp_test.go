@@ -0,0 +1,86 @@
+package ttml
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var referenceTimeRegexp = regexp.MustCompile(`^(((\d+):)?(\d+):)?((\d+)([.:](\d{1,3}))?)$`)
+
+// referenceParseTimespan is the original regexp-based implementation that
+// scanTimespan must agree with.
+func referenceParseTimespan(timeSpan string) (float64, bool) {
+	matches := referenceTimeRegexp.FindStringSubmatch(timeSpan)
+	if matches == nil {
+		return 0, false
+	}
+	getInt := func(idx int) int64 {
+		v, _ := strconv.ParseInt(matches[idx], 10, 64)
+		return v
+	}
+	fracStr := matches[8]
+	if fracStr == "" {
+		fracStr = "0"
+	}
+	if len(fracStr) < 3 {
+		fracStr += strings.Repeat("0", 3-len(fracStr))
+	}
+	frac, _ := strconv.ParseInt(fracStr, 10, 64)
+	return float64((getInt(3)*3600+getInt(4)*60+getInt(6))*1000 + frac), true
+}
+
+func TestParseTimespanMatchesRegexp(t *testing.T) {
+	inputs := []string{
+		"", "0", "5", "5.1", "5.12", "5.123", "5.1234", "5:1", "01:02", "01:02.5",
+		"01:02:03", "01:02:03.456", "1:2:3:45", "1:2:3:4567", "1:2.3", "1.2:3",
+		"1:2:3:4:5", ":1", "1:", "1..2", "a", "00:28.156", "123:45.6", "1:234",
+		"١:٢", " 1", "1 ",
+	}
+	for _, input := range inputs {
+		want, wantOK := referenceParseTimespan(input)
+		got, err := ParseTimespan(input)
+		if (err == nil) != wantOK || got != want {
+			t.Fatalf("ParseTimespan(%q) = %v, %v; want %v, ok=%v", input, got, err, want, wantOK)
+		}
+	}
+
+	values, err := ParseTimespanBatch([]string{"00:01.000", "1:00:00"})
+	if err != nil || values[0] != 1000 || values[1] != 3600000 {
+		t.Fatalf("unexpected batch result: %v, %v", values, err)
+	}
+	if _, err := ParseTimespanBatch([]string{"00:01", "bad"}); err == nil || !strings.Contains(err.Error(), "values[1]") {
+		t.Fatalf("expected indexed batch error, got %v", err)
+	}
+}
+
+func benchmarkTimespans() []string {
+	values := make([]string, 10000)
+	for i := range values {
+		values[i] = MsToTimestamp(float64(i * 137))
+	}
+	return values
+}
+
+func BenchmarkParseTimespanBatch(b *testing.B) {
+	values := benchmarkTimespans()
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := ParseTimespanBatch(values); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseTimespanRegexp(b *testing.B) {
+	values := benchmarkTimespans()
+	b.ReportAllocs()
+	for b.Loop() {
+		for _, value := range values {
+			if referenceTimeRegexp.FindStringSubmatch(value) == nil {
+				b.Fatal(value)
+			}
+		}
+	}
+}
@@ -23,6 +23,21 @@ const (
 	amlxMagic = "AMLX"
 )
 
+const (
+	// 全局标记位（global_flags）。
+	globalFlagTimeScale uint8 = 1 << iota
+	globalFlagIndexFooter
+	// CompactWords：duration 最低位表示其后是否有 word_flags 字节。
+	globalFlagCompactWords
+	// Untimed：行记录与词记录均省去时间字段，词记录固定带 word_flags 字节。
+	globalFlagUntimed
+	// 已定义的合法全局标记掩码。
+	globalFlagMask = globalFlagTimeScale | globalFlagIndexFooter | globalFlagCompactWords | globalFlagUntimed
+)
+
+// amlxIndexFooterSize 为索引尾部长度：三个小端 uint64。
+const amlxIndexFooterSize = 24
+
 const (
 	// 行级标记位（bit flags）。
 	lineFlagIsBG uint8 = 1 << iota
@@ -30,8 +45,11 @@ const (
 	lineFlagIgnoreSync
 	lineFlagHasTranslatedLyric
 	lineFlagHasRomanLyric
+	lineFlagLineText
+	lineFlagRoleHarmony
+	lineFlagRoleAdLib
 	// 已定义的合法行标记掩码。
-	lineFlagMask = lineFlagIsBG | lineFlagIsDuet | lineFlagIgnoreSync | lineFlagHasTranslatedLyric | lineFlagHasRomanLyric
+	lineFlagMask = lineFlagIsBG | lineFlagIsDuet | lineFlagIgnoreSync | lineFlagHasTranslatedLyric | lineFlagHasRomanLyric | lineFlagLineText | lineFlagRoleHarmony | lineFlagRoleAdLib
 )
 
 const (
@@ -40,8 +58,11 @@ const (
 	wordFlagHasEmptyBeat
 	wordFlagHasRomanWord
 	wordFlagRomanWarning
+	wordFlagHasPhoneme
+	wordFlagBackground
+	wordFlagInstrumentalMarker
 	// 已定义的合法词标记掩码。
-	wordFlagMask = wordFlagObscene | wordFlagHasEmptyBeat | wordFlagHasRomanWord | wordFlagRomanWarning
+	wordFlagMask = wordFlagObscene | wordFlagHasEmptyBeat | wordFlagHasRomanWord | wordFlagRomanWarning | wordFlagHasPhoneme | wordFlagBackground | wordFlagInstrumentalMarker
 )
 
 var fileData []byte
@@ -49,6 +70,8 @@ var filetype string
 var isDetail bool // 详情
 var fp string
 var outputType string
+var isPretty bool     // 输出带缩进的 ttml
+var outputPath string // 输出文件路径，为空时沿用输入文件名
 
 func main() {
 	var rootCmd = &cobra.Command{
@@ -89,13 +112,13 @@ func main() {
 					fmt.Println("读取文件失败")
 					return
 				}
-				tm, err := ttml.DecodeBinary(fileData)
-				if err != nil {
-					fmt.Println("解析二进制文件失败")
-					return
-				}
 				if isDetail {
-					detailTTMLBinary(tm)
+					// 直接检查输入字节，解码失败时也能看到损坏的位置。
+					detailTTMLBinary(fileData)
+				}
+				if _, err := ttml.DecodeBinary(fileData); err != nil {
+					fmt.Printf("解析二进制文件失败：%v\n", err)
+					return
 				}
 			} else {
 				fmt.Println("请输入ttml文件或者二进制文件路径")
@@ -117,8 +140,8 @@ func main() {
 							fmt.Println("解析amlx文件失败")
 							return
 						}
-						exported := ttml.ExportTTMLText(tm, false)
-						err = os.WriteFile(fileName+".ttml", []byte(exported), 0644)
+						exported := ttml.ExportTTMLText(tm, isPretty)
+						err = os.WriteFile(outputFile(fileName, "ttml"), []byte(exported), 0644)
 						if err != nil {
 							fmt.Println("写入文件失败")
 							return
@@ -142,7 +165,7 @@ func main() {
 							fmt.Println("编码失败")
 							return
 						}
-						err = os.WriteFile(fileName+".amlx", encoded, 0644)
+						err = os.WriteFile(outputFile(fileName, "amlx"), encoded, 0644)
 						if err != nil {
 							fmt.Println("写入文件失败")
 							return
@@ -173,20 +196,122 @@ func main() {
 						fmt.Println("转换json失败")
 						return
 					}
-					err = os.WriteFile(fileName+".json", j, 0644)
+					err = os.WriteFile(outputFile(fileName, "json"), j, 0644)
+					if err != nil {
+						fmt.Println("写入文件失败")
+						return
+					}
+					fmt.Println("输出成功")
 
+				} else if outputType == "lrc" || outputType == "srt" || outputType == "vtt" {
+					fmt.Println("输出" + outputType + "文件")
+					var err error
+					var tm ttml.TTMLLyric
+					if filetype == "ttml" {
+						tm, err = ttml.ParseLyric(string(fileData))
+						if err != nil {
+							fmt.Println("解析ttml文件失败")
+							return
+						}
+					} else {
+						tm, err = ttml.DecodeBinary(fileData)
+						if err != nil {
+							fmt.Println("解析amlx文件失败")
+							return
+						}
+					}
+					var exported string
+					switch outputType {
+					case "lrc":
+						exported = ttml.ExportLRCText(tm)
+					case "srt":
+						exported = ttml.ExportSRTText(tm)
+					case "vtt":
+						exported = ttml.ExportVTTText(tm)
+					}
+					err = os.WriteFile(outputFile(fileName, outputType), []byte(exported), 0644)
+					if err != nil {
+						fmt.Println("写入文件失败")
+						return
+					}
+					fmt.Println("输出成功")
 				}
 			}
 		},
 	}
 
 	rootCmd.Flags().StringVarP(&fp, "input", "i", "", "输入文件")
-	rootCmd.Flags().StringVarP(&outputType, "to", "t", "", "输出类型")
+	rootCmd.Flags().StringVarP(&outputType, "to", "t", "", "输出类型（ttml/amlx/json/lrc/srt/vtt）")
+	rootCmd.Flags().StringVarP(&outputPath, "output", "o", "", "输出文件路径（默认与输入同名，扩展名取输出类型）")
 	rootCmd.Flags().BoolVarP(&isDetail, "detail", "d", false, "输出详细信息")
+	rootCmd.Flags().BoolVarP(&isPretty, "pretty", "p", false, "输出 ttml 时缩进排版")
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "sizes a.amlx b.amlx",
+		Short: "对比两个 amlx 文件各段的字节数",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := compareSizes(args[0], args[1]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		},
+	})
 
 	rootCmd.Execute()
 }
 
+// compareSizes 打印两个 amlx 文件各段大小及其差值（b - a）。
+func compareSizes(pathA, pathB string) error {
+	read := func(path string) (ttml.BinaryInfo, int, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return ttml.BinaryInfo{}, 0, fmt.Errorf("读取文件失败：%w", err)
+		}
+		_, info, err := ttml.DecodeBinaryInfo(data)
+		if err != nil {
+			return info, len(data), fmt.Errorf("解析二进制文件 %s 失败：%w", path, err)
+		}
+		return info, len(data), nil
+	}
+	infoA, totalA, err := read(pathA)
+	if err != nil {
+		return err
+	}
+	infoB, totalB, err := read(pathB)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-12s %12s %12s %12s\n", "section", "a", "b", "delta")
+	row := func(name string, a, b uint64) {
+		delta := int64(b) - int64(a)
+		text := fmt.Sprintf("%+d", delta)
+		if a > 0 {
+			text += fmt.Sprintf(" (%+.1f%%)", float64(delta)*100/float64(a))
+		}
+		c := color.FgWhite
+		if delta < 0 {
+			c = color.FgGreen
+		} else if delta > 0 {
+			c = color.FgRed
+		}
+		fmt.Printf("%-12s %12d %12d %s\n", name, a, b, colorText(text, c))
+	}
+	row("header", infoA.HeaderSize, infoB.HeaderSize)
+	row("string_pool", infoA.StringPoolSize, infoB.StringPoolSize)
+	row("lyric_data", infoA.LyricDataSize, infoB.LyricDataSize)
+	row("total", uint64(totalA), uint64(totalB))
+	return nil
+}
+
+// outputFile 返回输出路径：指定了 --output 时使用之，否则为输入文件名加上 ext 扩展名。
+func outputFile(fileName, ext string) string {
+	if outputPath != "" {
+		return outputPath
+	}
+	return fileName + "." + ext
+}
+
 func detailTTML(tm ttml.TTMLLyric) {
 	// 输出metadata
 	fmt.Println("---------- METADATA ----------")
@@ -207,6 +332,9 @@ func detailTTML(tm ttml.TTMLLyric) {
 		for _, word := range line.Words {
 			fmt.Printf("|  |%s: %s\n", "Word", colorText(word.Word, color.FgHiGreen))
 			fmt.Printf("|  |%s: %s\n", "RomanWord", word.RomanWord)
+			if word.Phoneme != "" {
+				fmt.Printf("|  |%s: %s\n", "Phoneme", word.Phoneme)
+			}
 			fmt.Printf("|  |%s: %s\n", "StartTime", colorText(ttml.MsToTimestamp(word.StartTime), color.FgYellow))
 			fmt.Printf("|  |%s: %s\n", "EndTime", colorText(ttml.MsToTimestamp(word.EndTime), color.FgYellow))
 
@@ -215,41 +343,60 @@ func detailTTML(tm ttml.TTMLLyric) {
 		fmt.Println("|---------- END ----------")
 	}
 }
-func detailTTMLBinary(tm ttml.TTMLLyric) {
-	encoded, err := ttml.EncodeBinary(tm)
-	if err != nil {
-		fmt.Printf("encode failed: %v\n", err)
+
+// detailTTMLBinary 逐字段走读原始 AMLX 字节并打印各段结构与大小，
+// 遇到第一个读取错误即停止，便于定位无法解码的文件的损坏位置。
+func detailTTMLBinary(data []byte) {
+	if err := ttml.VerifyBinaryIntegrity(data); err != nil {
+		fmt.Printf("integrity: %s\n", colorText(err.Error(), color.FgRed))
+	} else {
+		fmt.Printf("integrity: %s\n", colorText("ok", color.FgGreen))
 	}
 
-	reader := bytes.NewReader(encoded)
+	reader := bytes.NewReader(data)
 	magic, err := readBytes(reader, uint64(len(amlxMagic)), "magic")
 	if err != nil {
 		fmt.Printf("read magic failed: %v\n", err)
+		return
 	}
 	version, _, err := readTestByteWithSize(reader, "version")
 	if err != nil {
 		fmt.Printf("read version failed: %v\n", err)
+		return
 	}
 	globalFlags, _, err := readTestByteWithSize(reader, "global_flags")
 	if err != nil {
 		fmt.Printf("read global_flags failed: %v\n", err)
+		return
+	}
+	timeScale := uint64(1)
+	timeScaleVarintBytes := 0
+	if globalFlags&globalFlagTimeScale != 0 {
+		timeScale, timeScaleVarintBytes, err = readTestUvarintWithSize(reader, "time_scale")
+		if err != nil {
+			fmt.Printf("read time_scale failed: %v\n", err)
+			return
+		}
 	}
 
 	headerSize, headerSizeVarintBytes, err := readTestUvarintWithSize(reader, "header_size")
 	if err != nil {
 		fmt.Printf("read header_size failed: %v\n", err)
+		return
 	}
 	headerBytes, err := readBytes(reader, headerSize, "header_section")
 	if err != nil {
 		fmt.Printf("read header_section failed: %v\n", err)
+		return
 	}
 
-	fmt.Printf("container: total=%dB magic=%q version=0x%02x global_flags=0x%02x\n", len(encoded), string(magic), version, globalFlags)
+	fmt.Printf("container: total=%dB magic=%q version=0x%02x global_flags=0x%02x[%s] time_scale=%d\n", len(data), string(magic), version, globalFlags, formatGlobalFlagsForTest(globalFlags), timeScale)
 
 	headerReader := bytes.NewReader(headerBytes)
 	metadataCount, metadataCountVarintBytes, err := readTestUvarintWithSize(headerReader, "metadata_count")
 	if err != nil {
 		fmt.Printf("read metadata_count failed: %v\n", err)
+		return
 	}
 	fmt.Printf("header section: size=%dB metadata_count=%d(%dB)\n", len(headerBytes), metadataCount, metadataCountVarintBytes)
 
@@ -259,10 +406,12 @@ func detailTTMLBinary(tm ttml.TTMLLyric) {
 		keyID, keyIDBytes, err := readTestUvarintWithSize(headerReader, fmt.Sprintf("metadata[%d].key_id", metaIndex))
 		if err != nil {
 			fmt.Printf("read metadata[%d].key_id failed: %v\n", metaIndex, err)
+			return
 		}
 		valueCount, valueCountBytes, err := readTestUvarintWithSize(headerReader, fmt.Sprintf("metadata[%d].value_count", metaIndex))
 		if err != nil {
 			fmt.Printf("read metadata[%d].value_count failed: %v\n", metaIndex, err)
+			return
 		}
 
 		valueIDs := make([]uint64, 0, valueCount)
@@ -271,6 +420,7 @@ func detailTTMLBinary(tm ttml.TTMLLyric) {
 			valueID, valueBytes, err := readTestUvarintWithSize(headerReader, fmt.Sprintf("metadata[%d].value[%d]", metaIndex, valueIndex))
 			if err != nil {
 				fmt.Printf("read metadata[%d].value[%d] failed: %v\n", metaIndex, valueIndex, err)
+				return
 			}
 			valueIDs = append(valueIDs, valueID)
 			valueIDVarintBytes = append(valueIDVarintBytes, valueBytes)
@@ -279,6 +429,7 @@ func detailTTMLBinary(tm ttml.TTMLLyric) {
 		errorFlag, errorFlagBytes, err := readTestByteWithSize(headerReader, fmt.Sprintf("metadata[%d].error_flag", metaIndex))
 		if err != nil {
 			fmt.Printf("read metadata[%d].error_flag failed: %v\n", metaIndex, err)
+			return
 		}
 
 		entryBytes := entryStart - headerReader.Len()
@@ -304,6 +455,7 @@ func detailTTMLBinary(tm ttml.TTMLLyric) {
 	stringCount, stringCountVarintBytes, err := readTestUvarintWithSize(reader, "string_count")
 	if err != nil {
 		fmt.Printf("read string_count failed: %v\n", err)
+		return
 	}
 	fmt.Printf("string_pool: string_count=%d(%dB)\n", stringCount, stringCountVarintBytes)
 
@@ -312,10 +464,12 @@ func detailTTMLBinary(tm ttml.TTMLLyric) {
 		stringLen, stringLenVarintBytes, err := readTestUvarintWithSize(reader, fmt.Sprintf("string[%d].length", stringIndex))
 		if err != nil {
 			fmt.Printf("read string[%d].length failed: %v\n", stringIndex, err)
+			return
 		}
 		raw, err := readBytes(reader, stringLen, fmt.Sprintf("string[%d].bytes", stringIndex))
 		if err != nil {
 			fmt.Printf("read string[%d].bytes failed: %v\n", stringIndex, err)
+			return
 		}
 		entryBytes := entryStart - reader.Len()
 		fmt.Printf(
@@ -334,33 +488,50 @@ func detailTTMLBinary(tm ttml.TTMLLyric) {
 	lineCount, lineCountVarintBytes, err := readTestUvarintWithSize(reader, "line_count")
 	if err != nil {
 		fmt.Printf("read line_count failed: %v\n", err)
+		return
 	}
 	fmt.Printf("lyric_data: line_count=%d(%dB)\n", lineCount, lineCountVarintBytes)
 
+	// Untimed 文件的行与词记录没有时间字段，下面按 0(0B) 显示。
+	untimed := globalFlags&globalFlagUntimed != 0
 	for lineIndex := uint64(0); lineIndex < lineCount; lineIndex++ {
 		lineStart := reader.Len()
-		lineStartMS, lineStartVarintBytes, err := readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].start_time", lineIndex))
-		if err != nil {
-			fmt.Printf("read line[%d].start_time failed: %v\n", lineIndex, err)
-		}
-		lineEndMS, lineEndVarintBytes, err := readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].end_time", lineIndex))
-		if err != nil {
-			fmt.Printf("read line[%d].end_time failed: %v\n", lineIndex, err)
+		var lineStartMS, lineEndMS uint64
+		var lineStartVarintBytes, lineEndVarintBytes int
+		if !untimed {
+			lineStartMS, lineStartVarintBytes, err = readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].start_time", lineIndex))
+			if err != nil {
+				fmt.Printf("read line[%d].start_time failed: %v\n", lineIndex, err)
+				return
+			}
+			lineEndMS, lineEndVarintBytes, err = readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].end_time", lineIndex))
+			if err != nil {
+				fmt.Printf("read line[%d].end_time failed: %v\n", lineIndex, err)
+				return
+			}
 		}
 		lineFlags, lineFlagsBytes, err := readTestByteWithSize(reader, fmt.Sprintf("line[%d].flags", lineIndex))
 		if err != nil {
 			fmt.Printf("read line[%d].flags failed: %v\n", lineIndex, err)
+			return
 		}
 		wordCount, wordCountVarintBytes, err := readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].word_count", lineIndex))
 		if err != nil {
 			fmt.Printf("read line[%d].word_count failed: %v\n", lineIndex, err)
+			return
 		}
 
 		optionalLineFields := []string{}
+		if lineFlags&lineFlagLineText != 0 {
+			// 紧凑行：该 varint 实为行文本 ID，行内没有词记录。
+			optionalLineFields = append(optionalLineFields, fmt.Sprintf("line_text_id=%d(%dB)", wordCount, wordCountVarintBytes))
+			wordCount = 0
+		}
 		if lineFlags&lineFlagHasTranslatedLyric != 0 {
 			translatedID, translatedBytes, err := readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].translated_id", lineIndex))
 			if err != nil {
 				fmt.Printf("read line[%d].translated_id failed: %v\n", lineIndex, err)
+				return
 			}
 			optionalLineFields = append(optionalLineFields, fmt.Sprintf("translated_id=%d(%dB)", translatedID, translatedBytes))
 		}
@@ -368,6 +539,7 @@ func detailTTMLBinary(tm ttml.TTMLLyric) {
 			romanID, romanBytes, err := readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].roman_id", lineIndex))
 			if err != nil {
 				fmt.Printf("read line[%d].roman_id failed: %v\n", lineIndex, err)
+				return
 			}
 			optionalLineFields = append(optionalLineFields, fmt.Sprintf("roman_id=%d(%dB)", romanID, romanBytes))
 		}
@@ -392,21 +564,38 @@ func detailTTMLBinary(tm ttml.TTMLLyric) {
 
 		for wordIndex := uint64(0); wordIndex < wordCount; wordIndex++ {
 			wordStart := reader.Len()
-			deltaStart, deltaStartBytes, err := readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].word[%d].delta_start", lineIndex, wordIndex))
-			if err != nil {
-				fmt.Printf("read line[%d].word[%d].delta_start failed: %v\n", lineIndex, wordIndex, err)
+			var deltaStart, duration uint64
+			var deltaStartBytes, durationBytes int
+			if !untimed {
+				deltaStart, deltaStartBytes, err = readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].word[%d].delta_start", lineIndex, wordIndex))
+				if err != nil {
+					fmt.Printf("read line[%d].word[%d].delta_start failed: %v\n", lineIndex, wordIndex, err)
+					return
+				}
+				duration, durationBytes, err = readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].word[%d].duration", lineIndex, wordIndex))
+				if err != nil {
+					fmt.Printf("read line[%d].word[%d].duration failed: %v\n", lineIndex, wordIndex, err)
+					return
+				}
 			}
-			duration, durationBytes, err := readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].word[%d].duration", lineIndex, wordIndex))
-			if err != nil {
-				fmt.Printf("read line[%d].word[%d].duration failed: %v\n", lineIndex, wordIndex, err)
+			hasWordFlags := true
+			if globalFlags&globalFlagCompactWords != 0 {
+				hasWordFlags = duration&1 != 0
+				duration >>= 1
 			}
 			textID, textIDBytes, err := readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].word[%d].text_id", lineIndex, wordIndex))
 			if err != nil {
 				fmt.Printf("read line[%d].word[%d].text_id failed: %v\n", lineIndex, wordIndex, err)
+				return
 			}
-			wordFlags, wordFlagsBytes, err := readTestByteWithSize(reader, fmt.Sprintf("line[%d].word[%d].flags", lineIndex, wordIndex))
-			if err != nil {
-				fmt.Printf("read line[%d].word[%d].flags failed: %v\n", lineIndex, wordIndex, err)
+			var wordFlags uint8
+			wordFlagsBytes := 0
+			if hasWordFlags {
+				wordFlags, wordFlagsBytes, err = readTestByteWithSize(reader, fmt.Sprintf("line[%d].word[%d].flags", lineIndex, wordIndex))
+				if err != nil {
+					fmt.Printf("read line[%d].word[%d].flags failed: %v\n", lineIndex, wordIndex, err)
+					return
+				}
 			}
 
 			optionalWordFields := []string{}
@@ -414,6 +603,7 @@ func detailTTMLBinary(tm ttml.TTMLLyric) {
 				romanID, romanBytes, err := readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].word[%d].roman_id", lineIndex, wordIndex))
 				if err != nil {
 					fmt.Printf("read line[%d].word[%d].roman_id failed: %v\n", lineIndex, wordIndex, err)
+					return
 				}
 				optionalWordFields = append(optionalWordFields, fmt.Sprintf("roman_id=%d(%dB)", romanID, romanBytes))
 			}
@@ -421,9 +611,18 @@ func detailTTMLBinary(tm ttml.TTMLLyric) {
 				emptyBeatMS, emptyBeatBytes, err := readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].word[%d].empty_beat", lineIndex, wordIndex))
 				if err != nil {
 					fmt.Printf("read line[%d].word[%d].empty_beat failed: %v\n", lineIndex, wordIndex, err)
+					return
 				}
 				optionalWordFields = append(optionalWordFields, fmt.Sprintf("empty_beat_ms=%d(%dB)", emptyBeatMS, emptyBeatBytes))
 			}
+			if wordFlags&wordFlagHasPhoneme != 0 {
+				phonemeID, phonemeBytes, err := readTestUvarintWithSize(reader, fmt.Sprintf("line[%d].word[%d].phoneme_id", lineIndex, wordIndex))
+				if err != nil {
+					fmt.Printf("read line[%d].word[%d].phoneme_id failed: %v\n", lineIndex, wordIndex, err)
+					return
+				}
+				optionalWordFields = append(optionalWordFields, fmt.Sprintf("phoneme_id=%d(%dB)", phonemeID, phonemeBytes))
+			}
 			if len(optionalWordFields) == 0 {
 				optionalWordFields = append(optionalWordFields, "none")
 			}
@@ -451,16 +650,33 @@ func detailTTMLBinary(tm ttml.TTMLLyric) {
 	}
 
 	lyricDataSectionBytes := lyricDataSectionStart - reader.Len()
+
+	footerBytes := 0
+	if globalFlags&globalFlagIndexFooter != 0 {
+		footer, err := readBytes(reader, amlxIndexFooterSize, "index_footer")
+		if err != nil {
+			fmt.Printf("read index_footer failed: %v\n", err)
+			return
+		}
+		footerBytes = len(footer)
+		fmt.Printf(
+			"index_footer: header_offset=%d string_pool_offset=%d lyric_data_offset=%d (%dB)\n",
+			binary.LittleEndian.Uint64(footer[0:8]),
+			binary.LittleEndian.Uint64(footer[8:16]),
+			binary.LittleEndian.Uint64(footer[16:24]),
+			footerBytes,
+		)
+	}
 	if reader.Len() != 0 {
 		fmt.Printf("payload has unexpected trailing bytes: %d\n", reader.Len())
 	}
 
-	fixedHeaderBytes := len(amlxMagic) + 1 + 1
-	totalFromSections := fixedHeaderBytes + headerSizeVarintBytes + len(headerBytes) + stringPoolSectionBytes + lyricDataSectionBytes
-	if totalFromSections != len(encoded) {
+	fixedHeaderBytes := len(amlxMagic) + 1 + 1 + timeScaleVarintBytes
+	totalFromSections := fixedHeaderBytes + headerSizeVarintBytes + len(headerBytes) + stringPoolSectionBytes + lyricDataSectionBytes + footerBytes
+	if totalFromSections != len(data) {
 		fmt.Printf(
 			"section size mismatch: total=%d computed=%d (fixed=%d header_size_varint=%d header=%d string_pool=%d lyric=%d)\n",
-			len(encoded),
+			len(data),
 			totalFromSections,
 			fixedHeaderBytes,
 			headerSizeVarintBytes,
@@ -470,10 +686,10 @@ func detailTTMLBinary(tm ttml.TTMLLyric) {
 		)
 	}
 
-	totalFloat := float64(len(encoded))
+	totalFloat := float64(len(data))
 	fmt.Printf(
 		"size summary: total=%dB fixed=%dB header_size_varint=%dB header=%dB string_pool=%dB lyric_data=%dB\n",
-		len(encoded),
+		len(data),
 		fixedHeaderBytes,
 		headerSizeVarintBytes,
 		len(headerBytes),
@@ -520,8 +736,32 @@ func readTestByteWithSize(reader *bytes.Reader, field string) (byte, int, error)
 	return value, 1, nil
 }
 
+func formatGlobalFlagsForTest(flags uint8) string {
+	names := make([]string, 0, 4)
+	if flags&globalFlagTimeScale != 0 {
+		names = append(names, "time_scale")
+	}
+	if flags&globalFlagIndexFooter != 0 {
+		names = append(names, "index_footer")
+	}
+	if flags&globalFlagCompactWords != 0 {
+		names = append(names, "compact_words")
+	}
+	if flags&globalFlagUntimed != 0 {
+		names = append(names, "untimed")
+	}
+	if reserved := flags &^ globalFlagMask; reserved != 0 {
+		// 新版本编码器可能设置当前未知的标记位，原样显示便于排查。
+		names = append(names, fmt.Sprintf("reserved(0x%02x)", reserved))
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
 func formatLineFlagsForTest(flags uint8) string {
-	names := make([]string, 0, 5)
+	names := make([]string, 0, 8)
 	if flags&lineFlagIsBG != 0 {
 		names = append(names, "is_bg")
 	}
@@ -537,6 +777,15 @@ func formatLineFlagsForTest(flags uint8) string {
 	if flags&lineFlagHasRomanLyric != 0 {
 		names = append(names, "has_roman")
 	}
+	if flags&lineFlagLineText != 0 {
+		names = append(names, "line_text")
+	}
+	if flags&lineFlagRoleHarmony != 0 {
+		names = append(names, "harmony")
+	}
+	if flags&lineFlagRoleAdLib != 0 {
+		names = append(names, "adlib")
+	}
 	if len(names) == 0 {
 		return "none"
 	}
@@ -544,7 +793,7 @@ func formatLineFlagsForTest(flags uint8) string {
 }
 
 func formatWordFlagsForTest(flags uint8) string {
-	names := make([]string, 0, 4)
+	names := make([]string, 0, 5)
 	if flags&wordFlagObscene != 0 {
 		names = append(names, "obscene")
 	}
@@ -557,6 +806,18 @@ func formatWordFlagsForTest(flags uint8) string {
 	if flags&wordFlagRomanWarning != 0 {
 		names = append(names, "roman_warning")
 	}
+	if flags&wordFlagHasPhoneme != 0 {
+		names = append(names, "has_phoneme")
+	}
+	if flags&wordFlagBackground != 0 {
+		names = append(names, "background")
+	}
+	if flags&wordFlagInstrumentalMarker != 0 {
+		names = append(names, "instrumental_marker")
+	}
+	if reserved := flags &^ wordFlagMask; reserved != 0 {
+		names = append(names, fmt.Sprintf("reserved(0x%02x)", reserved))
+	}
 	if len(names) == 0 {
 		return "none"
 	}
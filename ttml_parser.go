@@ -1,9 +1,15 @@
 package ttml
 
 import (
+	"fmt"
 	"math"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/unicode/norm"
 )
 
 type romanWord struct {
@@ -12,14 +18,29 @@ type romanWord struct {
 	Text      string
 }
 
+// lineMetadata is the text a line-keyed block holds for a main line and, in
+// order, for each of its background lines (one x-bg span each).
 type lineMetadata struct {
 	Main string
-	Bg   string
+	Bg   []string
+}
+
+// bgAt returns the text for the background line at ordinal, or "".
+func (m lineMetadata) bgAt(ordinal int) string {
+	if ordinal < len(m.Bg) {
+		return m.Bg[ordinal]
+	}
+	return ""
+}
+
+// empty reports whether m holds no text at all.
+func (m lineMetadata) empty() bool {
+	return m.Main == "" && !slices.ContainsFunc(m.Bg, func(bg string) bool { return bg != "" })
 }
 
 type wordRomanMetadata struct {
 	Main []romanWord
-	Bg   []romanWord
+	Bg   [][]romanWord
 }
 
 const (
@@ -27,12 +48,173 @@ const (
 	fullwidthRightParen = "\uFF09"
 )
 
+// ParseOptions controls optional ParseLyricWithOptions behavior.
+type ParseOptions struct {
+	// PreserveXMLID copies xml:id from <p> and <span> elements into the
+	// XMLID fields so the writer can re-emit them.
+	PreserveXMLID bool
+	// PreserveITunesKey copies itunes:key from main-line <p> elements into
+	// LyricLine.ITunesKey so the writer can reuse it.
+	PreserveITunesKey bool
+	// MaxDurationMS, when positive, rejects any timestamp later than it so
+	// corrupt files with absurd hour values fail at parse time.
+	MaxDurationMS float64
+	// InterpolateUntimedWords spreads bare text tokens that sit between timed
+	// spans evenly across the gap between their timed neighbours, instead of
+	// giving them the whole line's timing.
+	InterpolateUntimedWords bool
+	// NormalizeNFC converts the document to Unicode NFC before parsing, so
+	// text and metadata that mix composed and decomposed forms compare equal
+	// and share AMLX string pool entries.
+	NormalizeNFC bool
+	// StrictNumbers rejects malformed numeric attributes such as
+	// amll:empty-beat="abc". By default they parse as NaN, like the TS
+	// parser, and the binary encoder later drops them.
+	StrictNumbers bool
+	// Encoding, when set, transcodes the input from that character set
+	// (e.g. simplifiedchinese.GBK) to UTF-8 before parsing, overriding the XML
+	// declaration. When nil, a non-UTF-8 encoding named by the declaration,
+	// such as encoding="GBK", is honored.
+	Encoding encoding.Encoding
+	// TranslationLanguage selects which xml:lang to read when a document
+	// carries several amll:translation elements. When empty, or when no
+	// element has that language, the first one in document order is used.
+	TranslationLanguage string
+	// PreserveUnknownRoles keeps spans with a ttm:role other than x-bg,
+	// x-translation and x-roman in LyricLine.RawSpans instead of dropping
+	// them.
+	PreserveUnknownRoles bool
+	// InstrumentalMarkers parses timed spans with no text at all as
+	// LyricWord.InstrumentalMarker words instead of ordinary blank tokens.
+	// Whitespace-only spans stay blank either way.
+	InstrumentalMarkers bool
+}
+
 // ParseLyric parses TTML text into a TTMLLyric structure.
 // It mirrors the TS parser behavior, including edge cases.
 func ParseLyric(ttmlText string) (TTMLLyric, error) {
+	return ParseLyricWithOptions(ttmlText, ParseOptions{})
+}
+
+// ParseLyricWithOptions parses TTML text into a TTMLLyric structure using opts.
+func ParseLyricWithOptions(ttmlText string, opts ParseOptions) (TTMLLyric, error) {
+	lyric, _, err := ParseLyricVerbose(ttmlText, opts)
+	return lyric, err
+}
+
+// ParseWarning describes input that the parser accepted but could not fully
+// represent, such as a dropped paragraph.
+type ParseWarning struct {
+	// Path locates the source element, e.g. "tt/body/div[2]/p[3]/span[4]".
+	Path    string
+	Message string
+}
+
+func (w ParseWarning) String() string {
+	return w.Path + ": " + w.Message
+}
+
+// RawMeta is a metadata element that ParseLyricRaw found but did not map
+// into TTMLLyric.
+type RawMeta struct {
+	// Key is the key attribute of an <amll:meta> or <meta> element, or else
+	// the element's qualified name, e.g. "ttm:title".
+	Key string
+	// Value is the value attribute when present, else the trimmed text.
+	Value string
+	// RawXML is the whole element as written in the source, without the
+	// namespace declarations it inherits from its ancestors.
+	RawXML string
+}
+
+// ParseLyricVerbose is ParseLyricWithOptions that also reports every lossy
+// event in document order: paragraphs dropped for lacking timing,
+// romanizations that match no word, amll:translation/amll:roman elements in
+// an unselected language, and malformed amll:empty-beat values (without
+// StrictNumbers). Warnings never fail the parse.
+func ParseLyricVerbose(ttmlText string, opts ParseOptions) (TTMLLyric, []ParseWarning, error) {
+	lyric, warnings, _, err := parseLyricDocument(ttmlText, opts)
+	return lyric, warnings, err
+}
+
+// ParseLyricRaw is ParseLyric that also returns the metadata elements the
+// model has no place for, so callers can carry them over themselves.
+func ParseLyricRaw(ttmlText string) (TTMLLyric, []RawMeta, error) {
+	lyric, _, doc, err := parseLyricDocument(ttmlText, ParseOptions{})
+	if err != nil {
+		return TTMLLyric{}, nil, err
+	}
+	return lyric, collectRawMeta(doc), nil
+}
+
+// parseLyricDocument implements ParseLyricVerbose and also returns the
+// parsed XML tree.
+func parseLyricDocument(ttmlText string, opts ParseOptions) (TTMLLyric, []ParseWarning, *xmlNode, error) {
+	var warnings []ParseWarning
+	warn := func(node *xmlNode, format string, args ...any) {
+		warnings = append(warnings, ParseWarning{Path: elementPath(node), Message: fmt.Sprintf(format, args...)})
+	}
+
+	ttmlText, err := decodeCharset(ttmlText, opts.Encoding)
+	if err != nil {
+		return TTMLLyric{}, nil, nil, err
+	}
+	if opts.NormalizeNFC {
+		// Markup is ASCII and never composes, so normalizing the whole input
+		// only affects text content and attribute values.
+		ttmlText = norm.NFC.String(ttmlText)
+	}
 	doc, err := parseXMLDocument(ttmlText)
 	if err != nil {
-		return TTMLLyric{}, err
+		return TTMLLyric{}, nil, nil, err
+	}
+
+	checkTime := func(ms float64, source string) error {
+		if opts.MaxDurationMS > 0 && ms > opts.MaxDurationMS {
+			return fmt.Errorf("时间戳超出上限 %s：%s", MsToTimestamp(opts.MaxDurationMS), source)
+		}
+		return nil
+	}
+	parseTime := func(value string) (float64, error) {
+		ms, err := ParseTimespan(value)
+		if err != nil {
+			return 0, err
+		}
+		return ms, checkTime(ms, value)
+	}
+	// parseTranslationWords reads the timed spans of an x-translation span;
+	// it returns nil when the translation is plain text.
+	parseTranslationWords := func(span *xmlNode) ([]LyricWord, error) {
+		var words []LyricWord
+		timed := false
+		for _, child := range span.Children {
+			word := LyricWord{ID: newUID()}
+			switch {
+			case child.Type == nodeText:
+				word.Word = child.Text
+			case child.Type == nodeElement && nameMatches(child, "span") && child.hasAttrLocal("begin"):
+				begin, _ := child.attrValueLocal("begin")
+				start, err := parseTime(begin)
+				if err != nil {
+					return nil, err
+				}
+				word.StartTime, word.EndTime = start, start
+				if end, ok := child.attrValueLocal("end"); ok {
+					if word.EndTime, err = parseTime(end); err != nil {
+						return nil, err
+					}
+				}
+				word.Word = child.textContent()
+				timed = true
+			default:
+				continue
+			}
+			words = append(words, word)
+		}
+		if !timed {
+			return nil, nil
+		}
+		return words, nil
 	}
 
 	itunesTranslations := map[string]lineMetadata{}
@@ -46,12 +228,25 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 			continue
 		}
 
-		main, bg := extractLineMetadata(textEl)
-		if main != "" || bg != "" {
-			itunesTranslations[key] = lineMetadata{Main: main, Bg: bg}
+		if meta := extractLineMetadata(textEl); !meta.empty() {
+			itunesTranslations[key] = meta
+		}
+	}
+
+	translationLanguage := ""
+	for _, translationEl := range findElementsByPath(doc, []string{"iTunesMetadata", "translations", "translation"}) {
+		if lang, ok := translationEl.attrValueNS(nsXML, "lang", "xml:lang"); ok && strings.TrimSpace(lang) != "" {
+			translationLanguage = strings.TrimSpace(lang)
+			break
 		}
 	}
 
+	amllTranslations, amllTranslationLanguage := collectAMLLLineTexts(doc, "translation", opts.TranslationLanguage, warn)
+	if translationLanguage == "" {
+		translationLanguage = amllTranslationLanguage
+	}
+	amllRomanizations, _ := collectAMLLLineTexts(doc, "roman", "", warn)
+
 	itunesLineRomanizations := map[string]lineMetadata{}
 	itunesWordRomanizations := map[string]wordRomanMetadata{}
 
@@ -66,9 +261,9 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 		}
 
 		var mainWords []romanWord
-		var bgWords []romanWord
+		var bgWords [][]romanWord
 		lineRomanMain := ""
-		lineRomanBg := ""
+		var lineRomanBg []string
 		isWordByWord := false
 
 		for _, node := range textEl.Children {
@@ -84,42 +279,52 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 				nestedSpans := findDescendantElements(node, func(n *xmlNode) bool {
 					return nameMatches(n, "span") && n.hasAttrLocal("begin") && n.hasAttrLocal("end")
 				})
+				// Each x-bg span belongs to the next background line, so both
+				// lists get an entry per span to keep them aligned.
+				var lineWords []romanWord
+				lineText := ""
 				if len(nestedSpans) > 0 {
 					isWordByWord = true
-					for _, span := range nestedSpans {
-						bgWordText := strings.TrimSpace(span.textContent())
-						bgWordText = trimParens(bgWordText)
+					bgTexts := make([]string, len(nestedSpans))
+					for i, span := range nestedSpans {
+						bgTexts[i] = strings.TrimSpace(span.textContent())
+					}
+					trimWrappingParens(bgTexts)
+					for i, span := range nestedSpans {
+						bgWordText := strings.TrimSpace(bgTexts[i])
 
 						beginStr, _ := span.attrValueLocal("begin")
 						endStr, _ := span.attrValueLocal("end")
-						begin, err := ParseTimespan(beginStr)
+						begin, err := parseTime(beginStr)
 						if err != nil {
-							return TTMLLyric{}, err
+							return TTMLLyric{}, nil, nil, err
 						}
-						end, err := ParseTimespan(endStr)
+						end, err := parseTime(endStr)
 						if err != nil {
-							return TTMLLyric{}, err
+							return TTMLLyric{}, nil, nil, err
 						}
-						bgWords = append(bgWords, romanWord{
+						lineWords = append(lineWords, romanWord{
 							StartTime: begin,
 							EndTime:   end,
 							Text:      bgWordText,
 						})
 					}
 				} else {
-					lineRomanBg += node.textContent()
+					lineText = trimParens(strings.TrimSpace(node.textContent()))
 				}
+				bgWords = append(bgWords, lineWords)
+				lineRomanBg = append(lineRomanBg, lineText)
 			} else if node.hasAttrLocal("begin") && node.hasAttrLocal("end") {
 				isWordByWord = true
 				beginStr, _ := node.attrValueLocal("begin")
 				endStr, _ := node.attrValueLocal("end")
-				begin, err := ParseTimespan(beginStr)
+				begin, err := parseTime(beginStr)
 				if err != nil {
-					return TTMLLyric{}, err
+					return TTMLLyric{}, nil, nil, err
 				}
-				end, err := ParseTimespan(endStr)
+				end, err := parseTime(endStr)
 				if err != nil {
-					return TTMLLyric{}, err
+					return TTMLLyric{}, nil, nil, err
 				}
 				mainWords = append(mainWords, romanWord{
 					StartTime: begin,
@@ -136,14 +341,9 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 			}
 		}
 
-		lineRomanMain = strings.TrimSpace(lineRomanMain)
-		lineRomanBg = trimParens(strings.TrimSpace(lineRomanBg))
-
-		if lineRomanMain != "" || lineRomanBg != "" {
-			itunesLineRomanizations[key] = lineMetadata{
-				Main: lineRomanMain,
-				Bg:   lineRomanBg,
-			}
+		lineRoman := lineMetadata{Main: strings.TrimSpace(lineRomanMain), Bg: lineRomanBg}
+		if !lineRoman.empty() {
+			itunesLineRomanizations[key] = lineRoman
 		}
 	}
 
@@ -158,9 +358,8 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 			continue
 		}
 
-		main, bg := extractLineMetadata(textEl)
-		if (main != "" || bg != "") && hasDescendantTag(textEl, "span") {
-			itunesTimedTranslations[key] = lineMetadata{Main: main, Bg: bg}
+		if meta := extractLineMetadata(textEl); !meta.empty() && hasDescendantTag(textEl, "span") {
+			itunesTimedTranslations[key] = meta
 			delete(itunesTranslations, key)
 		}
 	}
@@ -168,6 +367,7 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 	mainAgentID := "v1"
 
 	metadata := []TTMLMetadata{}
+	metadataIndex := map[string]int{}
 	for _, meta := range findAllElements(doc) {
 		if meta.Local != "meta" {
 			continue
@@ -183,15 +383,10 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 		if !ok || value == "" {
 			continue
 		}
-		found := false
-		for i := range metadata {
-			if metadata[i].Key == key {
-				metadata[i].Value = append(metadata[i].Value, value)
-				found = true
-				break
-			}
-		}
-		if !found {
+		if i, ok := metadataIndex[key]; ok {
+			metadata[i].Value = append(metadata[i].Value, value)
+		} else {
+			metadataIndex[key] = len(metadata)
 			metadata = append(metadata, TTMLMetadata{
 				Key:   key,
 				Value: []string{value},
@@ -212,12 +407,26 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 		}
 		if len(songwriterValues) > 0 {
 			metadata = append(metadata, TTMLMetadata{
-				Key:   "songwriter",
+				Key:   MetaKeySongwriter,
 				Value: songwriterValues,
 			})
 		}
 	}
 
+	hasTranslationLanguage := false
+	for _, meta := range metadata {
+		if meta.Key == MetaKeyTranslationLanguage {
+			hasTranslationLanguage = true
+			break
+		}
+	}
+	if translationLanguage != "" && !hasTranslationLanguage {
+		metadata = append(metadata, TTMLMetadata{
+			Key:   MetaKeyTranslationLanguage,
+			Value: []string{translationLanguage},
+		})
+	}
+
 	for _, agent := range findAllElements(doc) {
 		if agent.Local != "agent" {
 			continue
@@ -236,6 +445,9 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 
 	var lyricLines []LyricLine
 
+	// bgOrdinal counts the background lines of the current main line, which
+	// pick their entry from the x-bg spans of the keyed iTunes blocks.
+	bgOrdinal := 0
 	var parseLineElement func(lineEl *xmlNode, isBG bool, isDuet bool, parentItunesKey *string) error
 	parseLineElement = func(lineEl *xmlNode, isBG bool, isDuet bool, parentItunesKey *string) error {
 		startTimeAttr, startOk := lineEl.attrValueLocal("begin")
@@ -246,21 +458,44 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 		if endOk && endTimeAttr == "" {
 			endOk = false
 		}
+		// Untimed paragraphs take the window of the nearest timed <div>.
+		if !isBG && !startOk && !endOk {
+			if div := timedAncestorDiv(lineEl); div != nil {
+				startTimeAttr, _ = div.attrValueLocal("begin")
+				endTimeAttr, _ = div.attrValueLocal("end")
+				startOk, endOk = true, true
+			}
+		}
 
 		parsedStartTime := float64(0)
 		parsedEndTime := float64(0)
 
 		if startOk && endOk {
-			start, err := ParseTimespan(startTimeAttr)
+			start, err := parseTime(startTimeAttr)
 			if err != nil {
 				return err
 			}
-			end, err := ParseTimespan(endTimeAttr)
+			end, err := parseTime(endTimeAttr)
 			if err != nil {
 				return err
 			}
 			parsedStartTime = start
 			parsedEndTime = end
+		} else if durStr, ok := lineEl.attrValueLocal("dur"); ok && durStr != "" && startOk {
+			start, err := parseTime(startTimeAttr)
+			if err != nil {
+				return err
+			}
+			dur, err := ParseTimespan(durStr)
+			if err != nil {
+				return err
+			}
+			if err := checkTime(start+dur, durStr); err != nil {
+				return err
+			}
+			parsedStartTime = start
+			parsedEndTime = start + dur
+			endOk = true
 		}
 
 		line := LyricLine{
@@ -275,6 +510,15 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 			IgnoreSync:      false,
 		}
 
+		if opts.PreserveXMLID {
+			line.XMLID, _ = lineEl.attrValueNS(nsXML, "id", "xml:id")
+		}
+
+		if !isBG {
+			role, _ := lineEl.attrValueNS(nsTTM, "role", "ttm:role")
+			line.Role = parseLineRole(role)
+		}
+
 		if isBG {
 			line.IsDuet = isDuet
 		} else {
@@ -284,21 +528,30 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 		}
 
 		var itunesKey string
+		ordinal := 0
 		if isBG {
 			if parentItunesKey != nil {
 				itunesKey = *parentItunesKey
 			}
+			ordinal = bgOrdinal
+			bgOrdinal++
 		} else {
+			bgOrdinal = 0
 			if key, ok := lineEl.attrValueNS(nsItunes, "key", "itunes:key"); ok && key != "" {
 				itunesKey = key
 			}
+			if opts.PreserveITunesKey {
+				line.ITunesKey = itunesKey
+			}
 		}
 
 		var availableRomanWords []romanWord
 		if itunesKey != "" {
 			if romanData, ok := itunesWordRomanizations[itunesKey]; ok {
 				if isBG {
-					availableRomanWords = append([]romanWord(nil), romanData.Bg...)
+					if ordinal < len(romanData.Bg) {
+						availableRomanWords = append([]romanWord(nil), romanData.Bg[ordinal]...)
+					}
 				} else {
 					availableRomanWords = append([]romanWord(nil), romanData.Main...)
 				}
@@ -308,13 +561,13 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 		if itunesKey != "" {
 			if timed, ok := itunesTimedTranslations[itunesKey]; ok {
 				if isBG {
-					line.TranslatedLyric = timed.Bg
+					line.TranslatedLyric = timed.bgAt(ordinal)
 				} else {
 					line.TranslatedLyric = timed.Main
 				}
 			} else if trans, ok := itunesTranslations[itunesKey]; ok {
 				if isBG {
-					line.TranslatedLyric = trans.Bg
+					line.TranslatedLyric = trans.bgAt(ordinal)
 				} else {
 					line.TranslatedLyric = trans.Main
 				}
@@ -322,7 +575,24 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 
 			if roman, ok := itunesLineRomanizations[itunesKey]; ok {
 				if isBG {
-					line.RomanLyric = roman.Bg
+					line.RomanLyric = roman.bgAt(ordinal)
+				} else {
+					line.RomanLyric = roman.Main
+				}
+			}
+
+			// amll:translation and amll:roman fill in what iTunesMetadata
+			// left empty.
+			if trans, ok := amllTranslations[itunesKey]; ok && line.TranslatedLyric == "" {
+				if isBG {
+					line.TranslatedLyric = trans.bgAt(ordinal)
+				} else {
+					line.TranslatedLyric = trans.Main
+				}
+			}
+			if roman, ok := amllRomanizations[itunesKey]; ok && line.RomanLyric == "" {
+				if isBG {
+					line.RomanLyric = roman.bgAt(ordinal)
 				} else {
 					line.RomanLyric = roman.Main
 				}
@@ -330,99 +600,196 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 		}
 
 		haveBG := false
+		// x-bg spans are parsed recursively while walking the children and
+		// append their lines first; remember where they start.
+		bgStart := len(lyricLines)
+		// Indexes of span words, and of those whose end must be inferred
+		// because the span carried only a begin.
+		var timedWords, openEndWords []int
 
-		for _, wordNode := range lineEl.Children {
-			switch wordNode.Type {
-			case nodeText:
-				wordText := wordNode.Text
-				trimmed := strings.TrimSpace(wordText)
-				start := float64(0)
-				end := float64(0)
-				if trimmed != "" {
-					start = line.StartTime
-					end = line.EndTime
-				}
-				line.Words = append(line.Words, LyricWord{
-					ID:        newUID(),
-					Word:      wordText,
-					StartTime: start,
-					EndTime:   end,
-					Obscene:   false,
-					EmptyBeat: 0,
-					RomanWord: "",
-				})
-			case nodeElement:
-				role, _ := wordNode.attrValueNS(nsTTM, "role", "ttm:role")
-				if nameMatches(wordNode, "span") && role != "" {
-					if role == "x-bg" {
-						if err := parseLineElement(wordNode, true, line.IsDuet, &itunesKey); err != nil {
-							return err
-						}
-						haveBG = true
-					} else if role == "x-translation" {
-						if line.TranslatedLyric == "" {
-							line.TranslatedLyric = wordNode.innerXML()
-						}
-					} else if role == "x-roman" {
-						if line.RomanLyric == "" {
-							line.RomanLyric = wordNode.innerXML()
-						}
-					}
-				} else if wordNode.hasAttrLocal("begin") && wordNode.hasAttrLocal("end") {
-					wordStartStr, _ := wordNode.attrValueLocal("begin")
-					wordEndStr, _ := wordNode.attrValueLocal("end")
-					wordStartTime, err := ParseTimespan(wordStartStr)
-					if err != nil {
-						return err
+		// collectWords appends the words of parent's children. Timed spans that
+		// themselves hold timed spans are walked recursively so sub-syllable
+		// timing survives; bare text takes the enclosing span's time.
+		var collectWords func(parent *xmlNode, textStart, textEnd float64) error
+		collectWords = func(parent *xmlNode, textStart, textEnd float64) error {
+			for _, wordNode := range parent.Children {
+				switch wordNode.Type {
+				case nodeText:
+					wordText := wordNode.Text
+					trimmed := strings.TrimSpace(wordText)
+					start := float64(0)
+					end := float64(0)
+					if trimmed != "" {
+						start = textStart
+						end = textEnd
 					}
-					wordEndTime, err := ParseTimespan(wordEndStr)
-					if err != nil {
-						return err
-					}
-
-					word := LyricWord{
+					line.Words = append(line.Words, LyricWord{
 						ID:        newUID(),
-						Word:      wordNode.textContent(),
-						StartTime: wordStartTime,
-						EndTime:   wordEndTime,
+						Word:      wordText,
+						StartTime: start,
+						EndTime:   end,
 						Obscene:   false,
 						EmptyBeat: 0,
 						RomanWord: "",
-					}
+					})
+				case nodeElement:
+					role, _ := wordNode.attrValueNS(nsTTM, "role", "ttm:role")
+					if nameMatches(wordNode, "span") && role != "" {
+						if role == "x-bg" {
+							if err := parseLineElement(wordNode, true, line.IsDuet, &itunesKey); err != nil {
+								return err
+							}
+							haveBG = true
+						} else if role == "x-translation" {
+							if line.TranslatedLyric == "" {
+								words, err := parseTranslationWords(wordNode)
+								if err != nil {
+									return err
+								}
+								if words != nil {
+									line.TranslatedWords = words
+									line.TranslatedLyric = strings.TrimSpace(wordNode.textContent())
+								} else {
+									line.TranslatedLyric = wordNode.innerXML()
+								}
+							}
+						} else if role == "x-roman" {
+							if line.RomanLyric == "" {
+								line.RomanLyric = wordNode.innerXML()
+							}
+						} else if opts.PreserveUnknownRoles {
+							var sb strings.Builder
+							serializeNode(&sb, wordNode, false, 0)
+							line.RawSpans = append(line.RawSpans, sb.String())
+						}
+					} else if wordNode.hasAttrLocal("begin") {
+						wordStartStr, _ := wordNode.attrValueLocal("begin")
+						wordStartTime, err := parseTime(wordStartStr)
+						if err != nil {
+							return err
+						}
+						wordEndTime := wordStartTime
+						openEnd := false
+						if wordEndStr, ok := wordNode.attrValueLocal("end"); ok {
+							if wordEndTime, err = parseTime(wordEndStr); err != nil {
+								return err
+							}
+						} else if durStr, ok := wordNode.attrValueLocal("dur"); ok {
+							dur, err := ParseTimespan(durStr)
+							if err != nil {
+								return err
+							}
+							wordEndTime = wordStartTime + dur
+							if err := checkTime(wordEndTime, durStr); err != nil {
+								return err
+							}
+						} else {
+							openEnd = true
+						}
+
+						if hasTimedSpanChild(wordNode) {
+							groupEnd := wordEndTime
+							if openEnd {
+								groupEnd = textEnd
+							}
+							if err := collectWords(wordNode, wordStartTime, groupEnd); err != nil {
+								return err
+							}
+							continue
+						}
 
-					if emptyBeat, ok := wordNode.attrValueNS(nsAMLL, "empty-beat", "amll:empty-beat"); ok && emptyBeat != "" {
-						if parsed, err := parseFloatNumber(emptyBeat); err == nil {
+						word := LyricWord{
+							ID:        newUID(),
+							Word:      wordNode.textContent(),
+							StartTime: wordStartTime,
+							EndTime:   wordEndTime,
+							Obscene:   false,
+							EmptyBeat: 0,
+							RomanWord: "",
+						}
+
+						if emptyBeat, ok := wordNode.attrValueNS(nsAMLL, "empty-beat", "amll:empty-beat"); ok && emptyBeat != "" {
+							parsed, err := parseFloatNumber(emptyBeat)
+							if err != nil {
+								if opts.StrictNumbers {
+									return fmt.Errorf("无效的 amll:empty-beat 值 %q：%w", emptyBeat, err)
+								}
+								warn(wordNode, "invalid amll:empty-beat %q parsed as NaN", emptyBeat)
+							}
 							word.EmptyBeat = parsed
 						}
-					}
-					if obscene, ok := wordNode.attrValueNS(nsAMLL, "obscene", "amll:obscene"); ok && obscene == "true" {
-						word.Obscene = true
-					}
+						if obscene, ok := wordNode.attrValueNS(nsAMLL, "obscene", "amll:obscene"); ok && obscene == "true" {
+							word.Obscene = true
+						}
+						if phoneme, ok := wordNode.attrValueNS(nsAMLL, "phoneme", "amll:phoneme"); ok {
+							word.Phoneme = phoneme
+						}
+						if bg, ok := wordNode.attrValueNS(nsAMLL, "bg", "amll:bg"); ok && bg == "true" {
+							word.Background = true
+						}
+						if opts.InstrumentalMarkers {
+							word.InstrumentalMarker = word.Word == ""
+						}
+						if opts.PreserveXMLID {
+							word.XMLID, _ = wordNode.attrValueNS(nsXML, "id", "xml:id")
+						}
 
-					if len(availableRomanWords) > 0 {
-						matchIndex := -1
-						for i, roman := range availableRomanWords {
-							if roman.StartTime == wordStartTime && roman.EndTime == wordEndTime {
-								matchIndex = i
-								break
+						if len(availableRomanWords) > 0 {
+							matchIndex := -1
+							for i, roman := range availableRomanWords {
+								if roman.StartTime == wordStartTime && roman.EndTime == wordEndTime {
+									matchIndex = i
+									break
+								}
+							}
+							if matchIndex != -1 {
+								word.RomanWord = availableRomanWords[matchIndex].Text
+								availableRomanWords = append(availableRomanWords[:matchIndex], availableRomanWords[matchIndex+1:]...)
 							}
 						}
-						if matchIndex != -1 {
-							word.RomanWord = availableRomanWords[matchIndex].Text
-							availableRomanWords = append(availableRomanWords[:matchIndex], availableRomanWords[matchIndex+1:]...)
+
+						if openEnd {
+							openEndWords = append(openEndWords, len(line.Words))
 						}
+						timedWords = append(timedWords, len(line.Words))
+						line.Words = append(line.Words, word)
 					}
+				}
+			}
+			return nil
+		}
+		if err := collectWords(lineEl, line.StartTime, line.EndTime); err != nil {
+			return err
+		}
+		for _, roman := range availableRomanWords {
+			warn(lineEl, "romanization %q at %s-%s matches no word and was dropped", roman.Text, MsToTimestamp(roman.StartTime), MsToTimestamp(roman.EndTime))
+		}
 
-					line.Words = append(line.Words, word)
+		// Begin-only spans end where the next span begins, or at the line end
+		// for the last one.
+		for _, idx := range openEndWords {
+			end := line.Words[idx].StartTime
+			if startOk && endOk {
+				end = math.Max(end, line.EndTime)
+			}
+			for _, next := range timedWords {
+				if next > idx {
+					end = line.Words[next].StartTime
+					break
 				}
 			}
+			line.Words[idx].EndTime = end
+		}
+
+		if opts.InterpolateUntimedWords && len(timedWords) > 0 {
+			interpolateUntimedWords(&line, timedWords)
 		}
 
 		if !startOk || !endOk {
 			minStart := math.Inf(1)
 			maxEnd := float64(0)
 			for _, w := range line.Words {
-				if strings.TrimSpace(w.Word) == "" {
+				if w.IsBlank() {
 					continue
 				}
 				if w.StartTime < minStart {
@@ -432,76 +799,244 @@ func ParseLyric(ttmlText string) (TTMLLyric, error) {
 					maxEnd = w.EndTime
 				}
 			}
+			if math.IsInf(minStart, 1) {
+				minStart = 0
+			}
 			line.StartTime = minStart
 			line.EndTime = maxEnd
 		}
 
 		if line.IsBG {
-			if len(line.Words) > 0 {
-				firstWord := line.Words[0].Word
-				if strings.HasPrefix(firstWord, fullwidthLeftParen) || strings.HasPrefix(firstWord, "(") {
-					if strings.HasPrefix(firstWord, fullwidthLeftParen) {
-						firstWord = strings.TrimPrefix(firstWord, fullwidthLeftParen)
-					} else {
-						firstWord = strings.TrimPrefix(firstWord, "(")
-					}
-					if firstWord == "" {
-						line.Words = line.Words[1:]
-					} else {
-						line.Words[0].Word = firstWord
-					}
-				}
+			// The parens may sit on a standalone text node or on the first/last
+			// non-blank word; only a pair wrapping the whole line is removed.
+			texts := make([]string, len(line.Words))
+			for idx, w := range line.Words {
+				texts[idx] = w.Word
 			}
-			if len(line.Words) > 0 {
-				lastIdx := len(line.Words) - 1
-				lastWord := line.Words[lastIdx].Word
-				if strings.HasSuffix(lastWord, fullwidthRightParen) || strings.HasSuffix(lastWord, ")") {
-					if strings.HasSuffix(lastWord, fullwidthRightParen) {
-						lastWord = strings.TrimSuffix(lastWord, fullwidthRightParen)
-					} else {
-						lastWord = strings.TrimSuffix(lastWord, ")")
-					}
-					if lastWord == "" {
-						line.Words = line.Words[:lastIdx]
-					} else {
-						line.Words[lastIdx].Word = lastWord
+			if trimWrappingParens(texts) {
+				words := line.Words[:0]
+				for idx, w := range line.Words {
+					if texts[idx] != w.Word {
+						if texts[idx] == "" {
+							continue
+						}
+						w.Word = texts[idx]
 					}
+					words = append(words, w)
 				}
+				line.Words = words
 			}
 		}
 
 		if haveBG {
-			var bgLine *LyricLine
-			if len(lyricLines) > 0 {
-				last := lyricLines[len(lyricLines)-1]
-				bgLine = &last
-				lyricLines = lyricLines[:len(lyricLines)-1]
-			}
-			lyricLines = append(lyricLines, line)
-			if bgLine != nil {
-				lyricLines = append(lyricLines, *bgLine)
-			}
+			// Move the main line in front of its background lines so the
+			// result is main, bg1, bg2, ... in document order.
+			lyricLines = append(lyricLines, LyricLine{})
+			copy(lyricLines[bgStart+1:], lyricLines[bgStart:])
+			lyricLines[bgStart] = line
 		} else {
 			lyricLines = append(lyricLines, line)
 		}
 		return nil
 	}
 
+	var prevItunesKey string
+	prevIsDuet := false
+	var prevSection *xmlNode
+	untimed := isUntimedDocument(doc)
 	for _, lineEl := range findBodyParagraphs(doc) {
+		role, _ := lineEl.attrValueNS(nsTTM, "role", "ttm:role")
+		// Role paragraphs (translation/romanization) are often untimed.
+		if !untimed && !(lineEl.hasAttrLocal("begin") && (lineEl.hasAttrLocal("end") || lineEl.hasAttrLocal("dur"))) && timedAncestorDiv(lineEl) == nil && role != "x-translation" && role != "x-roman" {
+			warn(lineEl, "paragraph without begin/end dropped")
+			continue
+		}
+		// Paragraph-level translations and romanizations belong to the main
+		// line just parsed, not to its trailing background lines; they never
+		// start a lyric line of their own.
+		if role == "x-translation" || role == "x-roman" {
+			owner := len(lyricLines) - 1
+			for owner >= 0 && lyricLines[owner].IsBG {
+				owner--
+			}
+			if owner < 0 {
+				continue
+			}
+			prev := &lyricLines[owner]
+			text := strings.TrimSpace(lineEl.textContent())
+			if role == "x-translation" && prev.TranslatedLyric == "" {
+				prev.TranslatedLyric = text
+			} else if role == "x-roman" && prev.RomanLyric == "" {
+				prev.RomanLyric = text
+			}
+			continue
+		}
+		// Some authors write background vocals as a sibling <p ttm:role="x-bg">
+		// instead of a nested span; attach those to the preceding main line.
+		if role == "x-bg" && len(lyricLines) > 0 {
+			bgKey := prevItunesKey
+			if key, ok := lineEl.attrValueNS(nsItunes, "key", "itunes:key"); ok && key != "" {
+				bgKey = key
+			}
+			if err := parseLineElement(lineEl, true, prevIsDuet, &bgKey); err != nil {
+				return TTMLLyric{}, nil, nil, err
+			}
+			continue
+		}
+		// The writer splits divs on wordless lines, so mirror that here to keep
+		// the division structure symmetric across parse and export. Repeated
+		// <body> elements (invalid, but seen in the wild) split the same way.
+		section := enclosingSection(lineEl)
+		if section != prevSection && len(lyricLines) > 0 {
+			lyricLines = append(lyricLines, NewLyricLine())
+		}
+		prevSection = section
 		if err := parseLineElement(lineEl, false, false, nil); err != nil {
-			return TTMLLyric{}, err
+			return TTMLLyric{}, nil, nil, err
+		}
+		prevItunesKey, _ = lineEl.attrValueNS(nsItunes, "key", "itunes:key")
+		for i := len(lyricLines) - 1; i >= 0; i-- {
+			if !lyricLines[i].IsBG {
+				prevIsDuet = lyricLines[i].IsDuet
+				break
+			}
 		}
 	}
 
 	return TTMLLyric{
-		Metadata:   metadata,
-		LyricLines: lyricLines,
-	}, nil
+		Metadata:        metadata,
+		LyricLines:      lyricLines,
+		DocumentProfile: parseDocumentProfile(doc),
+		WasPretty:       isPrettyDocument(doc),
+	}, warnings, doc, nil
 }
 
-func extractLineMetadata(textEl *xmlNode) (string, string) {
+// collectRawMeta returns the children of <head><metadata> and of
+// <iTunesMetadata> that the parser does not read: everything except agents,
+// complete amll:meta entries, AMLL translation/romanization blocks and the
+// iTunes songwriters, translations and transliterations.
+func collectRawMeta(doc *xmlNode) []RawMeta {
+	isAMLL := func(el *xmlNode, local string) bool {
+		return el.Local == local && (el.Name == "amll:"+local || el.Namespace == nsAMLL)
+	}
+	mapped := func(el *xmlNode) bool {
+		switch {
+		case el.Local == "agent" && (el.Name == "ttm:agent" || el.Namespace == nsTTM):
+			return true
+		case isAMLL(el, "meta"):
+			key, _ := el.attrValueLocal("key")
+			value, _ := el.attrValueLocal("value")
+			return key != "" && value != ""
+		case isAMLL(el, "translation"), isAMLL(el, "roman"):
+			return true
+		case el.Local == "iTunesMetadata":
+			return true
+		}
+		return false
+	}
+
+	var raw []RawMeta
+	add := func(el *xmlNode) {
+		meta := RawMeta{Key: el.Name}
+		if el.Local == "meta" {
+			if key, ok := el.attrValueLocal("key"); ok && key != "" {
+				meta.Key = key
+			}
+		}
+		if value, ok := el.attrValueLocal("value"); ok {
+			meta.Value = value
+		} else {
+			meta.Value = strings.TrimSpace(el.textContent())
+		}
+		var sb strings.Builder
+		serializeNode(&sb, el, false, 0)
+		meta.RawXML = sb.String()
+		raw = append(raw, meta)
+	}
+	for _, metadataEl := range findElementsByPath(doc, []string{"head", "metadata"}) {
+		for _, el := range metadataEl.Children {
+			if el.Type == nodeElement && !mapped(el) {
+				add(el)
+			}
+		}
+	}
+	for _, itunesEl := range findElementsByPath(doc, []string{"iTunesMetadata"}) {
+		for _, el := range itunesEl.Children {
+			if el.Type != nodeElement {
+				continue
+			}
+			switch el.Local {
+			case "songwriters", "translations", "transliterations":
+				continue
+			}
+			add(el)
+		}
+	}
+	return raw
+}
+
+// isPrettyDocument reports whether the structural elements above <p> are
+// separated by indentation, i.e. the document was pretty-printed. Bare line
+// breaks (ExportOptions.LineBreaks) do not count, and whitespace inside
+// paragraphs is lyric content and is not considered.
+func isPrettyDocument(doc *xmlNode) bool {
+	var walk func(node *xmlNode) bool
+	walk = func(node *xmlNode) bool {
+		for _, child := range node.Children {
+			switch child.Type {
+			case nodeText:
+				if strings.TrimSpace(child.Text) == "" && (strings.Contains(child.Text, "\n ") || strings.Contains(child.Text, "\n\t")) {
+					return true
+				}
+			case nodeElement:
+				if child.Local != "p" && walk(child) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, root := range doc.Children {
+		if root.Type == nodeElement && root.Local == "tt" {
+			return walk(root)
+		}
+	}
+	return false
+}
+
+// parseLineRole maps a <p> ttm:role to a LineRole; unknown roles are lead.
+func parseLineRole(role string) LineRole {
+	switch role {
+	case "x-harmony":
+		return LineRoleHarmony
+	case "x-adlib":
+		return LineRoleAdLib
+	default:
+		return LineRoleLead
+	}
+}
+
+// parseDocumentProfile reads the presentation attributes of the <tt> root.
+func parseDocumentProfile(doc *xmlNode) DocumentProfile {
+	var profile DocumentProfile
+	for _, root := range doc.Children {
+		if root.Type != nodeElement || root.Local != "tt" {
+			continue
+		}
+		profile.Lang, _ = root.attrValueNS(nsXML, "lang", "xml:lang")
+		profile.Profile, _ = root.attrValueNS(nsTTP, "profile", "ttp:profile")
+		profile.CellResolution, _ = root.attrValueNS(nsTTP, "cellResolution", "ttp:cellResolution")
+		profile.FrameRate, _ = root.attrValueNS(nsTTP, "frameRate", "ttp:frameRate")
+		break
+	}
+	return profile
+}
+
+// extractLineMetadata reads a keyed <text>: its own text is the main line's,
+// and each x-bg span, in order, holds the text of the next background line.
+func extractLineMetadata(textEl *xmlNode) lineMetadata {
 	var mainSB strings.Builder
-	var bgSB strings.Builder
+	var meta lineMetadata
 
 	for _, node := range textEl.Children {
 		if node.Type == nodeText {
@@ -509,35 +1044,162 @@ func extractLineMetadata(textEl *xmlNode) (string, string) {
 		} else if node.Type == nodeElement {
 			role, _ := node.attrValueNS(nsTTM, "role", "ttm:role")
 			if role == "x-bg" {
-				bgSB.WriteString(node.textContent())
+				meta.Bg = append(meta.Bg, trimParens(strings.TrimSpace(node.textContent())))
 			}
 		}
 	}
 
-	main := strings.TrimSpace(mainSB.String())
-	bg := trimParens(strings.TrimSpace(bgSB.String()))
-	return main, bg
+	meta.Main = strings.TrimSpace(mainSB.String())
+	return meta
+}
+
+// collectAMLLLineTexts reads the AMLL counterpart of the iTunes
+// translations/transliterations blocks:
+//
+//	<amll:translation xml:lang="zh-CN">
+//	  <amll:text for="L1">...</amll:text>
+//	</amll:translation>
+//
+// keyed by the itunes:key of the line, with x-bg spans for the background
+// line. local is "translation" or "roman". Only one language is kept: lang
+// when present, otherwise the first in document order; elements in other
+// languages are reported through warn. It returns the texts and the language
+// that was read.
+func collectAMLLLineTexts(doc *xmlNode, local, lang string, warn func(*xmlNode, string, ...any)) (map[string]lineMetadata, string) {
+	var elements []*xmlNode
+	for _, el := range findAllElements(doc) {
+		if el.Local == local && (el.Name == "amll:"+local || el.Namespace == nsAMLL) {
+			elements = append(elements, el)
+		}
+	}
+	if len(elements) == 0 {
+		return nil, ""
+	}
+	elementLang := func(el *xmlNode) string {
+		value, _ := el.attrValueNS(nsXML, "lang", "xml:lang")
+		return strings.TrimSpace(value)
+	}
+	selected := elementLang(elements[0])
+	if lang != "" && slices.ContainsFunc(elements, func(el *xmlNode) bool { return elementLang(el) == lang }) {
+		selected = lang
+	}
+
+	texts := map[string]lineMetadata{}
+	for _, el := range elements {
+		if elementLang(el) != selected {
+			warn(el, "amll:%s in xml:lang %q ignored in favor of %q", local, elementLang(el), selected)
+			continue
+		}
+		for _, textEl := range el.Children {
+			if textEl.Type != nodeElement || textEl.Local != "text" {
+				continue
+			}
+			key, ok := textEl.attrValueLocal("for")
+			if !ok || key == "" {
+				continue
+			}
+			if meta := extractLineMetadata(textEl); !meta.empty() {
+				texts[key] = meta
+			}
+		}
+	}
+	return texts, selected
 }
 
 func trimParens(text string) string {
-	text = strings.TrimSpace(text)
-	if strings.HasPrefix(text, fullwidthLeftParen) || strings.HasPrefix(text, "(") {
-		if strings.HasPrefix(text, fullwidthLeftParen) {
-			text = strings.TrimPrefix(text, fullwidthLeftParen)
-		} else {
-			text = strings.TrimPrefix(text, "(")
+	parts := []string{strings.TrimSpace(text)}
+	trimWrappingParens(parts)
+	return strings.TrimSpace(parts[0])
+}
+
+// isParenWrapped reports whether text opens with a parenthesis whose
+// matching close is its last character: "(oh (yeah) oh)" is wrapped, while
+// "(oh) (yeah)" and "(oh" are not. Half- and fullwidth parens are
+// interchangeable.
+func isParenWrapped(text string) bool {
+	if !strings.HasPrefix(text, "(") && !strings.HasPrefix(text, fullwidthLeftParen) {
+		return false
+	}
+	depth := 0
+	for i, r := range text {
+		switch string(r) {
+		case "(", fullwidthLeftParen:
+			depth++
+		case ")", fullwidthRightParen:
+			depth--
+			if depth == 0 {
+				return i+utf8.RuneLen(r) == len(text)
+			}
 		}
 	}
-	if strings.HasSuffix(text, fullwidthRightParen) || strings.HasSuffix(text, ")") {
-		if strings.HasSuffix(text, fullwidthRightParen) {
-			text = strings.TrimSuffix(text, fullwidthRightParen)
-		} else {
-			text = strings.TrimSuffix(text, ")")
+	return false
+}
+
+// trimWrappingParens removes the parenthesis pair wrapping the concatenation
+// of parts, taking the open paren from the first non-blank part and the
+// close paren from the last, together with the whitespace outside them.
+// It reports whether a pair was removed.
+func trimWrappingParens(parts []string) bool {
+	first, last := -1, -1
+	for i, part := range parts {
+		if strings.TrimSpace(part) != "" {
+			if first == -1 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first == -1 || !isParenWrapped(strings.TrimSpace(strings.Join(parts[first:last+1], ""))) {
+		return false
+	}
+	parts[first] = strings.TrimLeft(parts[first], " \t\r\n")
+	_, size := utf8.DecodeRuneInString(parts[first])
+	parts[first] = parts[first][size:]
+	parts[last] = strings.TrimRight(parts[last], " \t\r\n")
+	_, size = utf8.DecodeLastRuneInString(parts[last])
+	parts[last] = parts[last][:len(parts[last])-size]
+	return true
+}
+
+// isUntimedDocument reports whether the <tt> root declares itunes:timing="None".
+func isUntimedDocument(doc *xmlNode) bool {
+	for _, root := range doc.Children {
+		if root.Type == nodeElement && root.Local == "tt" {
+			timing, _ := root.attrValueNS(nsItunes, "timing", "itunes:timing")
+			return timing == "None"
 		}
 	}
-	return strings.TrimSpace(text)
+	return false
 }
 
+// hasTimedSpanChild reports whether node directly contains a <span> carrying
+// a begin attribute, i.e. it groups nested sub-syllable timings.
+func hasTimedSpanChild(node *xmlNode) bool {
+	for _, child := range node.Children {
+		if child.Type == nodeElement && nameMatches(child, "span") && child.hasAttrLocal("begin") {
+			return true
+		}
+	}
+	return false
+}
+
+// timedAncestorDiv returns the nearest <div> above node that carries both
+// begin and end, or nil.
+func timedAncestorDiv(node *xmlNode) *xmlNode {
+	for parent := node.Parent; parent != nil; parent = parent.Parent {
+		if parent.Type != nodeElement || parent.Local != "div" {
+			continue
+		}
+		begin, _ := parent.attrValueLocal("begin")
+		end, _ := parent.attrValueLocal("end")
+		if begin != "" && end != "" {
+			return parent
+		}
+	}
+	return nil
+}
+
+// findBodyParagraphs returns the <p> elements of the body in document order.
 func findBodyParagraphs(doc *xmlNode) []*xmlNode {
 	var result []*xmlNode
 	var walk func(node *xmlNode, inBody bool)
@@ -555,9 +1217,7 @@ func findBodyParagraphs(doc *xmlNode) []*xmlNode {
 			inBody = true
 		}
 		if inBody && node.Local == "p" {
-			if node.hasAttrLocal("begin") && node.hasAttrLocal("end") {
-				result = append(result, node)
-			}
+			result = append(result, node)
 		}
 		for _, child := range node.Children {
 			walk(child, inBody)
@@ -567,6 +1227,46 @@ func findBodyParagraphs(doc *xmlNode) []*xmlNode {
 	return result
 }
 
+// elementPath returns an XPath-like location of node for diagnostics, such as
+// "tt/body/div[2]/p[3]". Indexes are 1-based among same-name siblings and
+// omitted when the name is unique.
+func elementPath(node *xmlNode) string {
+	var parts []string
+	for ; node != nil && node.Type == nodeElement; node = node.Parent {
+		part := node.Name
+		if parent := node.Parent; parent != nil {
+			index, count := 0, 0
+			for _, sibling := range parent.Children {
+				if sibling.Type == nodeElement && sibling.Name == node.Name {
+					count++
+					if sibling == node {
+						index = count
+					}
+				}
+			}
+			if count > 1 {
+				part += "[" + strconv.Itoa(index) + "]"
+			}
+		}
+		parts = append(parts, part)
+	}
+	slices.Reverse(parts)
+	return strings.Join(parts, "/")
+}
+
+// enclosingSection returns the nearest div or body ancestor of node, or nil
+// if none.
+func enclosingSection(node *xmlNode) *xmlNode {
+	for parent := node.Parent; parent != nil; parent = parent.Parent {
+		if parent.Type == nodeElement && (parent.Local == "div" || parent.Local == "body") {
+			return parent
+		}
+	}
+	return nil
+}
+
+// parseFloatNumber parses value like JS Number(): blank is 0 and malformed
+// input is NaN. The error reports malformed input so callers can be strict.
 func parseFloatNumber(value string) (float64, error) {
 	value = strings.TrimSpace(value)
 	if value == "" {
@@ -574,7 +1274,40 @@ func parseFloatNumber(value string) (float64, error) {
 	}
 	parsed, err := strconv.ParseFloat(value, 64)
 	if err != nil {
-		return math.NaN(), nil
+		return math.NaN(), err
 	}
 	return parsed, nil
 }
+
+// interpolateUntimedWords retimes each run of non-blank bare text tokens to
+// split the gap between the previous timed word's end (or the line start) and
+// the next timed word's start (or the line end) evenly.
+func interpolateUntimedWords(line *LyricLine, timedWords []int) {
+	timed := make([]bool, len(line.Words))
+	for _, idx := range timedWords {
+		timed[idx] = true
+	}
+	prevEnd := line.StartTime
+	var run []int
+	flush := func(nextStart float64) {
+		if len(run) > 0 {
+			step := math.Max(nextStart-prevEnd, 0) / float64(len(run))
+			for k, idx := range run {
+				line.Words[idx].StartTime = prevEnd + step*float64(k)
+				line.Words[idx].EndTime = prevEnd + step*float64(k+1)
+			}
+			run = run[:0]
+		}
+	}
+	for idx, word := range line.Words {
+		if timed[idx] {
+			flush(word.StartTime)
+			prevEnd = word.EndTime
+			continue
+		}
+		if !word.IsBlank() {
+			run = append(run, idx)
+		}
+	}
+	flush(math.Max(line.EndTime, prevEnd))
+}
@@ -1,9 +1,18 @@
 package ttml
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
 )
 
 func TestParser(t *testing.T) {
@@ -19,8 +28,8 @@ func TestParser(t *testing.T) {
 	if e != nil {
 		t.Error(e)
 	}
-	// 文件
-	f, e := os.Create("ttml.json")
+	// 文件：写入临时目录，避免运行测试时改动仓库中的 ttml.json
+	f, e := os.Create(filepath.Join(t.TempDir(), "ttml.json"))
 	if e != nil {
 		t.Error(e)
 	}
@@ -44,10 +53,1684 @@ func TestExportTTMLText(t *testing.T) {
 	}
 	s := ExportTTMLText(tt, false)
 	// 文件
-	f, e = os.Create("ttml.ttml")
+	f, e = os.Create(filepath.Join(t.TempDir(), "ttml.ttml"))
 	if e != nil {
 		t.Error(e)
 	}
 	defer f.Close()
 	f.Write([]byte(s))
 }
+
+func TestExportBackgroundParensRoundTrip(t *testing.T) {
+	lyric := TTMLLyric{
+		LyricLines: []LyricLine{
+			{
+				StartTime: 1000,
+				EndTime:   2000,
+				Words: []LyricWord{
+					{StartTime: 1000, EndTime: 1500, Word: "main"},
+					{Word: " "},
+					{StartTime: 1500, EndTime: 2000, Word: "line"},
+				},
+			},
+			{
+				IsBG:      true,
+				StartTime: 1200,
+				EndTime:   1800,
+				Words: []LyricWord{
+					{Word: " "},
+					{StartTime: 1200, EndTime: 1500, Word: "bg"},
+					{Word: " "},
+					{StartTime: 1500, EndTime: 1800, Word: "vox"},
+				},
+			},
+		},
+	}
+
+	for _, mode := range []BGParenMode{BGParenInWord, BGParenStandalone} {
+		out := ExportTTMLTextWithOptions(lyric, ExportOptions{BGParenMode: mode})
+		parsed, err := ParseLyric(out)
+		if err != nil {
+			t.Fatalf("mode %d: parse failed: %v", mode, err)
+		}
+		if len(parsed.LyricLines) != 2 || !parsed.LyricLines[1].IsBG {
+			t.Fatalf("mode %d: unexpected lines: %#v", mode, parsed.LyricLines)
+		}
+		for _, word := range parsed.LyricLines[1].Words {
+			if strings.ContainsAny(word.Word, "()") {
+				t.Fatalf("mode %d: paren leaked into bg word %q\n%s", mode, word.Word, out)
+			}
+		}
+	}
+}
+
+func TestBackgroundInnerParens(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata"><body><div>` +
+		`<p begin="00:01.000" end="00:02.000"><span begin="00:01.000" end="00:02.000">main</span>` +
+		`<span ttm:role="x-bg"><span begin="00:01.000" end="00:01.300">(oh</span> <span begin="00:01.300" end="00:01.600">(yeah)</span> <span begin="00:01.600" end="00:02.000">oh)</span></span></p>` +
+		`<p begin="00:02.000" end="00:03.000"><span begin="00:02.000" end="00:03.000">main</span>` +
+		`<span ttm:role="x-bg"><span begin="00:02.000" end="00:02.500">(yeah)</span> <span begin="00:02.500" end="00:03.000">oh</span></span></p>` +
+		`</div></body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(lyric.LyricLines) != 4 {
+		t.Fatalf("unexpected line count: %d", len(lyric.LyricLines))
+	}
+	text := func(line LyricLine) string {
+		var sb strings.Builder
+		for _, word := range line.Words {
+			sb.WriteString(word.Word)
+		}
+		return sb.String()
+	}
+	if got := text(lyric.LyricLines[1]); got != "oh (yeah) oh" {
+		t.Fatalf("expected the outer pair stripped, got %q", got)
+	}
+	if got := text(lyric.LyricLines[3]); got != "(yeah) oh" {
+		t.Fatalf("expected a non-wrapping pair kept, got %q", got)
+	}
+
+	reparsed, err := ParseLyric(ExportTTMLText(lyric, false))
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if !reparsed.Equal(lyric) {
+		t.Fatalf("inner parens did not round trip:\n got: %v\nwant: %v", reparsed, lyric)
+	}
+
+	for input, want := range map[string]string{
+		"(oh (yeah) oh)": "oh (yeah) oh",
+		"（背景）":           "背景",
+		"(oh) (yeah)":    "(oh) (yeah)",
+		"(oh":            "(oh",
+	} {
+		if got := trimParens(input); got != want {
+			t.Fatalf("trimParens(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestLineOrderContract(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata"><body><div>` +
+		`<p begin="00:01.000" end="00:02.000"><span ttm:role="x-bg"><span begin="00:01.000" end="00:01.500">(before)</span></span>` +
+		`<span begin="00:01.000" end="00:01.500">main</span> <span begin="00:01.500" end="00:02.000">one</span>` +
+		`<span ttm:role="x-bg"><span begin="00:01.500" end="00:02.000">(after)</span></span></p>` +
+		`<p begin="00:03.000" end="00:04.000"><span begin="00:03.000" end="00:03.500">main</span> <span begin="00:03.500" end="00:04.000">two</span></p>` +
+		`</div></body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	order := func(lyric TTMLLyric) []string {
+		var out []string
+		for _, line := range lyric.LyricLines {
+			var sb strings.Builder
+			if line.IsBG {
+				sb.WriteString("bg:")
+			}
+			for _, word := range line.Words {
+				sb.WriteString(word.Word)
+			}
+			out = append(out, sb.String())
+		}
+		return out
+	}
+	want := []string{"main one", "bg:before", "bg:after", "main two"}
+	if got := order(lyric); !slices.Equal(got, want) {
+		t.Fatalf("parse order: expected %q, got %q", want, got)
+	}
+
+	encoded, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	decoded, err := DecodeBinary(encoded)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if got := order(decoded); !slices.Equal(got, want) {
+		t.Fatalf("decode order: expected %q, got %q", want, got)
+	}
+
+	for _, opts := range []ExportOptions{{}, {BackgroundAsSiblingParagraph: true}} {
+		reparsed, err := ParseLyric(ExportTTMLTextWithOptions(lyric, opts))
+		if err != nil {
+			t.Fatalf("reparse failed: %v", err)
+		}
+		if got := order(reparsed); !slices.Equal(got, want) {
+			t.Fatalf("%+v: reparse order: expected %q, got %q", opts, want, got)
+		}
+	}
+}
+
+func TestParseSiblingBackgroundParagraph(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata" xmlns:itunes="http://music.apple.com/lyric-ttml-internal">` +
+		`<head><metadata><ttm:agent type="person" xml:id="v1"/><ttm:agent type="other" xml:id="v2"/></metadata></head>` +
+		`<body><div>` +
+		`<p begin="00:01.000" end="00:02.000" ttm:agent="v2" itunes:key="L1"><span begin="00:01.000" end="00:01.500">main</span> <span begin="00:01.500" end="00:02.000">line</span></p>` +
+		`<p begin="00:01.200" end="00:01.800" ttm:role="x-bg"><span begin="00:01.200" end="00:01.500">(bg</span> <span begin="00:01.500" end="00:01.800">vox)</span></p>` +
+		`<p begin="00:03.000" end="00:04.000" ttm:agent="v1" itunes:key="L2"><span begin="00:03.000" end="00:04.000">next</span></p>` +
+		`</div></body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(lyric.LyricLines) != 3 {
+		t.Fatalf("unexpected line count: %d", len(lyric.LyricLines))
+	}
+	bg := lyric.LyricLines[1]
+	if !bg.IsBG || !bg.IsDuet {
+		t.Fatalf("sibling bg paragraph not attached to preceding duet line: %#v", bg)
+	}
+	if bg.Words[0].Word != "bg" || bg.Words[len(bg.Words)-1].Word != "vox" {
+		t.Fatalf("bg parens not stripped: %#v", bg.Words)
+	}
+	if lyric.LyricLines[2].IsBG {
+		t.Fatalf("following main line must not be bg")
+	}
+}
+
+func TestParseParagraphLevelRoles(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata">` +
+		`<body><div>` +
+		`<p begin="00:01.000" end="00:02.000"><span begin="00:01.000" end="00:02.000">你好</span></p>` +
+		`<p ttm:role="x-roman">ni hao</p>` +
+		`<p begin="00:01.000" end="00:02.000" ttm:role="x-translation">hello</p>` +
+		`<p begin="00:03.000" end="00:04.000"><span begin="00:03.000" end="00:04.000">再见</span></p>` +
+		`</div></body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(lyric.LyricLines) != 2 {
+		t.Fatalf("role paragraphs must not become lines, got %d lines", len(lyric.LyricLines))
+	}
+	first := lyric.LyricLines[0]
+	if first.RomanLyric != "ni hao" || first.TranslatedLyric != "hello" {
+		t.Fatalf("paragraph roles not attached to previous line: %#v", first)
+	}
+	if first.StartTime != 1000 || first.EndTime != 2000 {
+		t.Fatalf("line timing changed: %v-%v", first.StartTime, first.EndTime)
+	}
+	if second := lyric.LyricLines[1]; second.RomanLyric != "" || second.TranslatedLyric != "" {
+		t.Fatalf("roles leaked onto following line: %#v", second)
+	}
+
+	// A bg span puts background lines after the main line; the role
+	// paragraphs still belong to the main line.
+	withBG := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata">` +
+		`<body><div>` +
+		`<p begin="00:01.000" end="00:02.000"><span begin="00:01.000" end="00:02.000">main</span>` +
+		`<span ttm:role="x-bg"><span begin="00:01.200" end="00:01.800">(bg)</span></span></p>` +
+		`<p ttm:role="x-translation">TRANSLATION</p>` +
+		`<p ttm:role="x-roman">ROMAN</p>` +
+		`</div></body></tt>`
+	lyric, err = ParseLyric(withBG)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(lyric.LyricLines) != 2 || !lyric.LyricLines[1].IsBG {
+		t.Fatalf("expected a main line and a bg line, got %#v", lyric.LyricLines)
+	}
+	if main := lyric.LyricLines[0]; main.TranslatedLyric != "TRANSLATION" || main.RomanLyric != "ROMAN" {
+		t.Fatalf("paragraph roles not attached to main line: %#v", main)
+	}
+	if bg := lyric.LyricLines[1]; bg.TranslatedLyric != "" || bg.RomanLyric != "" {
+		t.Fatalf("paragraph roles attached to bg line: %#v", bg)
+	}
+}
+
+func TestITunesBlocksMultipleBackgroundLines(t *testing.T) {
+	word := func(start, end float64, text, roman string) LyricWord {
+		return LyricWord{StartTime: start, EndTime: end, Word: text, RomanWord: roman}
+	}
+	lyric := TTMLLyric{LyricLines: []LyricLine{
+		{StartTime: 1000, EndTime: 3000, TranslatedLyric: "main", Words: []LyricWord{word(1000, 2000, "主", "zhu"), word(2000, 3000, "唱", "")}},
+		{StartTime: 1000, EndTime: 1500, IsBG: true, TranslatedLyric: "first", Words: []LyricWord{word(1000, 1500, "一", "")}},
+		{StartTime: 1500, EndTime: 2000, IsBG: true, Words: []LyricWord{word(1500, 2000, "二", "er")}},
+		{StartTime: 2000, EndTime: 2500, IsBG: true, TranslatedLyric: "third", Words: []LyricWord{word(2000, 2500, "三", "")}},
+		{StartTime: 2500, EndTime: 3000, IsBG: true, Words: []LyricWord{word(2500, 3000, "四", "")}},
+	}}
+
+	for _, opts := range []ExportOptions{{ITunesTranslations: true}, {ITunesTranslations: true, BackgroundAsSiblingParagraph: true}} {
+		out := ExportTTMLTextWithOptions(lyric, opts)
+		// One x-bg span per background line, up to the last one with data.
+		if !strings.Contains(out, `<text for="L1">main<span ttm:role="x-bg">(first)</span><span ttm:role="x-bg"/><span ttm:role="x-bg">(third)</span></text>`) {
+			t.Fatalf("translation block does not carry every background line:\n%s", out)
+		}
+		if !strings.Contains(out, `<span ttm:role="x-bg"/><span ttm:role="x-bg"><span begin="00:01.500" end="00:02.000">(er)</span></span></text>`) {
+			t.Fatalf("transliteration block does not carry every background line:\n%s", out)
+		}
+		reparsed, err := ParseLyric(out)
+		if err != nil {
+			t.Fatalf("reparse failed: %v", err)
+		}
+		// The parsed translation language lands in metadata; compare lines only.
+		if !(TTMLLyric{LyricLines: reparsed.LyricLines}).Equal(lyric) {
+			t.Fatalf("background lines lost iTunes data on round trip:\n got: %v\nwant: %v\n%s", reparsed, lyric, out)
+		}
+	}
+}
+
+func TestITunesTranslationsRoundTrip(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata" xmlns:itunes="http://music.apple.com/lyric-ttml-internal">` +
+		`<head><metadata><iTunesMetadata xmlns="http://music.apple.com/lyric-ttml-internal"><translations><translation type="subtitle" xml:lang="en">` +
+		`<text for="L1">hello<span ttm:role="x-bg">(oh)</span></text>` +
+		`</translation></translations></iTunesMetadata></metadata></head>` +
+		`<body><div>` +
+		`<p begin="00:01.000" end="00:02.000" itunes:key="L1"><span begin="00:01.000" end="00:01.500">你</span><span begin="00:01.500" end="00:02.000">好</span>` +
+		`<span ttm:role="x-bg"><span begin="00:01.200" end="00:01.800">(哦)</span></span></p>` +
+		`</div></body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if lyric.LyricLines[0].TranslatedLyric != "hello" || lyric.LyricLines[1].TranslatedLyric != "oh" {
+		t.Fatalf("unexpected translations: %#v", lyric.LyricLines)
+	}
+	foundLang := false
+	for _, meta := range lyric.Metadata {
+		if meta.Key == MetaKeyTranslationLanguage && len(meta.Value) == 1 && meta.Value[0] == "en" {
+			foundLang = true
+		}
+	}
+	if !foundLang {
+		t.Fatalf("translation language not captured: %#v", lyric.Metadata)
+	}
+
+	out := ExportTTMLTextWithOptions(lyric, ExportOptions{ITunesTranslations: true})
+	if !strings.Contains(out, `<translation type="subtitle" xml:lang="en"><text for="L1">hello<span ttm:role="x-bg">(oh)</span></text>`) {
+		t.Fatalf("translations block missing:\n%s", out)
+	}
+	if strings.Contains(out, "x-translation") || strings.Contains(out, "translationLanguage") {
+		t.Fatalf("translations should not be written inline or as amll:meta:\n%s", out)
+	}
+	reparsed, err := ParseLyric(out)
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if !lyric.Equal(reparsed) {
+		t.Fatalf("round trip changed lyric")
+	}
+
+	inline := ExportTTMLText(lyric, false)
+	if !strings.Contains(inline, `<span ttm:role="x-translation" xml:lang="en">hello</span>`) {
+		t.Fatalf("inline translation should carry captured language:\n%s", inline)
+	}
+}
+
+func TestExportCompatTS(t *testing.T) {
+	lyric := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: MetaKeyTranslationLanguage, Value: []string{"en"}}},
+		LyricLines: []LyricLine{
+			{StartTime: 0, EndTime: 1000, XMLID: "p1", TranslatedLyric: "hi", Words: []LyricWord{
+				{StartTime: 0, EndTime: 1000, Word: "a", XMLID: "w1", Phoneme: "ə"},
+				{Word: " "},
+				{StartTime: 1000, EndTime: 2000, Word: "b"},
+			}},
+			{StartTime: 2000, EndTime: 3000, Words: []LyricWord{{StartTime: 2000, EndTime: 3000, Word: "c"}}},
+		},
+	}
+	want := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata" xmlns:amll="http://www.example.com/ns/amll" xmlns:itunes="http://music.apple.com/lyric-ttml-internal" itunes:timing="Word">` +
+		`<head><metadata><ttm:agent type="person" xml:id="v1"/><amll:meta key="translationLanguage" value="en"/></metadata></head>` +
+		`<body dur="00:03.000"><div begin="00:00.000" end="00:03.000">` +
+		`<p begin="00:00.000" end="00:01.000" ttm:agent="v1" itunes:key="L1"><span begin="00:00.000" end="00:01.000">a</span> <span begin="00:01.000" end="00:02.000">b</span><span ttm:role="x-translation" xml:lang="zh-CN">hi</span></p>` +
+		`<p begin="00:02.000" end="00:03.000" ttm:agent="v1" itunes:key="L2"><span begin="00:02.000" end="00:03.000">c</span></p>` +
+		`</div></body></tt>`
+	got := ExportTTMLTextWithOptions(lyric, ExportOptions{CompatTS: true, BGParenMode: BGParenStandalone, ITunesTranslations: true})
+	if got != want {
+		t.Fatalf("unexpected CompatTS output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestParseBeginOnlyWordSpans(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata">` +
+		`<body><div><p begin="00:01.000" end="00:03.000">` +
+		`<span begin="00:01.000">a</span> <span begin="00:01.500" dur="00:00.250">b</span> <span begin="00:02.000">c</span>` +
+		`</p></div></body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	var got [][2]float64
+	for _, word := range lyric.LyricLines[0].Words {
+		if strings.TrimSpace(word.Word) != "" {
+			got = append(got, [2]float64{word.StartTime, word.EndTime})
+		}
+	}
+	want := [][2]float64{{1000, 1500}, {1500, 1750}, {2000, 3000}}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected words: %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("word %d timing = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseInterpolateUntimedWords(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml"><body><div>` +
+		`<p begin="00:01.000" end="00:04.000"><span begin="00:01.000" end="00:02.000">a</span> b <span begin="00:03.000" end="00:04.000">c</span></p>` +
+		`</div></body></tt>`
+
+	wordTiming := func(lyric TTMLLyric, text string) [2]float64 {
+		for _, word := range lyric.LyricLines[0].Words {
+			if strings.TrimSpace(word.Word) == text {
+				return [2]float64{word.StartTime, word.EndTime}
+			}
+		}
+		t.Fatalf("word %q not found", text)
+		return [2]float64{}
+	}
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if got := wordTiming(lyric, "b"); got != [2]float64{1000, 4000} {
+		t.Fatalf("default should keep line timing for bare text, got %v", got)
+	}
+
+	lyric, err = ParseLyricWithOptions(input, ParseOptions{InterpolateUntimedWords: true})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if got := wordTiming(lyric, "b"); got != [2]float64{2000, 3000} {
+		t.Fatalf("untimed word not interpolated between neighbours, got %v", got)
+	}
+	if got := wordTiming(lyric, "c"); got != [2]float64{3000, 4000} {
+		t.Fatalf("timed word changed: %v", got)
+	}
+}
+
+func TestParseNormalizeNFC(t *testing.T) {
+	const composed, decomposed = "caf\u00e9", "cafe\u0301"
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:amll="http://www.example.com/ns/amll"><head><metadata>` +
+		`<amll:meta key="musicName" value="` + decomposed + `"/></metadata></head><body><div>` +
+		`<p begin="00:01.000" end="00:03.000"><span begin="00:01.000" end="00:02.000">` + composed + `</span> ` +
+		`<span begin="00:02.000" end="00:03.000">` + decomposed + `</span></p>` +
+		`</div></body></tt>`
+
+	poolCount := func(lyric TTMLLyric, text string) int {
+		pool, _ := BuildStringPool(lyric)
+		count := 0
+		for _, s := range pool {
+			if s == text {
+				count++
+			}
+		}
+		return count
+	}
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if words := lyric.LyricLines[0].Words; words[0].Word == words[2].Word {
+		t.Fatalf("default parse should keep input normalization, got %q", words[2].Word)
+	}
+	if poolCount(lyric, composed)+poolCount(lyric, decomposed) != 2 {
+		t.Fatalf("expected separate pool entries without normalization")
+	}
+
+	lyric, err = ParseLyricWithOptions(input, ParseOptions{NormalizeNFC: true})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if got := lyric.LyricLines[0].Words[2].Word; got != composed {
+		t.Fatalf("word not normalized: %q", got)
+	}
+	if got := lyric.Metadata[0].Value[0]; got != composed {
+		t.Fatalf("metadata value not normalized: %q", got)
+	}
+	if got := poolCount(lyric, composed) + poolCount(lyric, decomposed); got != 1 {
+		t.Fatalf("expected one pool entry after normalization, got %d", got)
+	}
+}
+
+func TestParseStrictNumbers(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:amll="http://www.example.com/ns/amll"><body><div>` +
+		`<p begin="00:01.000" end="00:02.000"><span begin="00:01.000" end="00:02.000" amll:empty-beat="abc">x</span></p>` +
+		`</div></body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("default parse should accept malformed empty-beat: %v", err)
+	}
+	if beat := lyric.LyricLines[0].Words[0].EmptyBeat; !math.IsNaN(beat) {
+		t.Fatalf("expected NaN empty beat, got %v", beat)
+	}
+
+	_, err = ParseLyricWithOptions(input, ParseOptions{StrictNumbers: true})
+	if err == nil || !strings.Contains(err.Error(), "empty-beat") || !strings.Contains(err.Error(), `"abc"`) {
+		t.Fatalf("expected strict empty-beat error, got %v", err)
+	}
+
+	valid := strings.Replace(input, `"abc"`, `"2"`, 1)
+	if _, err := ParseLyricWithOptions(valid, ParseOptions{StrictNumbers: true}); err != nil {
+		t.Fatalf("valid empty-beat rejected: %v", err)
+	}
+}
+
+func TestParseHTMLEntities(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml"><body><div>` +
+		`<p begin="00:01.000" end="00:02.000"><span begin="00:01.000" end="00:02.000">&ldquo;Don&rsquo;t&nbsp;stop&rdquo; &amp; go</span></p>` +
+		`</div></body></tt>`
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if got, want := lyric.LyricLines[0].Words[0].Word, "\u201cDon\u2019t\u00a0stop\u201d & go"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if _, err := ParseLyric(ExportTTMLText(lyric, false)); err != nil {
+		t.Fatalf("re-exported entities do not parse: %v", err)
+	}
+}
+
+func TestParseLegacyEncodings(t *testing.T) {
+	body := `<tt xmlns="http://www.w3.org/ns/ttml"><body><div>` +
+		`<p begin="00:01.000" end="00:02.000"><span begin="00:01.000" end="00:02.000">你好世界</span></p>` +
+		`</div></body></tt>`
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String(body)
+	if err != nil {
+		t.Fatalf("encode GBK: %v", err)
+	}
+	wordOf := func(lyric TTMLLyric) string { return lyric.LyricLines[0].Words[0].Word }
+
+	lyric, err := ParseLyric(`<?xml version="1.0" encoding="GBK"?>` + gbk)
+	if err != nil {
+		t.Fatalf("declared GBK parse failed: %v", err)
+	}
+	if got := wordOf(lyric); got != "你好世界" {
+		t.Fatalf("declared GBK decoded as %q", got)
+	}
+
+	lyric, err = ParseLyricWithOptions(gbk, ParseOptions{Encoding: simplifiedchinese.GBK})
+	if err != nil {
+		t.Fatalf("GBK option parse failed: %v", err)
+	}
+	if got := wordOf(lyric); got != "你好世界" {
+		t.Fatalf("GBK option decoded as %q", got)
+	}
+
+	lyric, err = ParseLyric(`<?xml version="1.0" encoding="utf-8"?>` + body)
+	if err != nil || wordOf(lyric) != "你好世界" {
+		t.Fatalf("declared UTF-8 parse changed: %v", err)
+	}
+
+	if _, err := ParseLyric(`<?xml version="1.0" encoding="x-unknown"?>` + body); err == nil || !strings.Contains(err.Error(), "x-unknown") {
+		t.Fatalf("expected unsupported encoding error, got %v", err)
+	}
+}
+
+func TestParseLyricVerboseWarnings(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:amll="http://www.example.com/ns/amll" xmlns:itunes="http://music.apple.com/lyric-ttml-internal">` +
+		`<head><metadata><iTunesMetadata xmlns="http://music.apple.com/lyric-ttml-internal"><transliterations><transliteration>` +
+		`<text for="L1"><span begin="00:01.000" end="00:02.000">a</span><span begin="00:05.000" end="00:06.000">zzz</span></text>` +
+		`</transliteration></transliterations></iTunesMetadata></metadata></head><body><div>` +
+		`<p begin="00:01.000" end="00:03.000" itunes:key="L1"><span begin="00:01.000" end="00:02.000">A</span>` +
+		`<span begin="00:02.000" end="00:03.000" amll:empty-beat="abc">B</span></p>` +
+		`<p>untimed</p>` +
+		`</div></body></tt>`
+
+	lyric, warnings, err := ParseLyricVerbose(input, ParseOptions{})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(lyric.LyricLines) != 1 || lyric.LyricLines[0].Words[0].RomanWord != "a" {
+		t.Fatalf("unexpected lyric: %v", lyric)
+	}
+	want := []string{
+		`tt/body/div/p[1]/span[2]: invalid amll:empty-beat "abc" parsed as NaN`,
+		`tt/body/div/p[1]: romanization "zzz" at 00:05.000-00:06.000 matches no word and was dropped`,
+		`tt/body/div/p[2]: paragraph without begin/end dropped`,
+	}
+	if len(warnings) != len(want) {
+		t.Fatalf("expected %d warnings, got %v", len(want), warnings)
+	}
+	for i := range want {
+		if got := warnings[i].String(); got != want[i] {
+			t.Fatalf("warning %d:\n got: %s\nwant: %s", i, got, want[i])
+		}
+	}
+
+	plain, err := ParseLyricWithOptions(input, ParseOptions{})
+	if err != nil || !plain.Equal(lyric) {
+		t.Fatalf("ParseLyricWithOptions should match the verbose result: %v", err)
+	}
+}
+
+func TestParseAMLLTranslationElements(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata" xmlns:amll="http://www.example.com/ns/amll" xmlns:itunes="http://music.apple.com/lyric-ttml-internal">` +
+		`<head><metadata>` +
+		`<amll:translation xml:lang="en"><amll:text for="L1">Hello</amll:text></amll:translation>` +
+		`<amll:translation xml:lang="zh-CN"><amll:text for="L1">你好<span ttm:role="x-bg">（背景）</span></amll:text></amll:translation>` +
+		`<amll:roman xml:lang="ja-Latn"><amll:text for="L1">konnichiwa<span ttm:role="x-bg">(haikei)</span></amll:text></amll:roman>` +
+		`</metadata></head><body><div>` +
+		`<p begin="00:01.000" end="00:03.000" itunes:key="L1"><span begin="00:01.000" end="00:02.000">こんにちは</span>` +
+		`<span ttm:role="x-bg"><span begin="00:02.000" end="00:03.000">(背景)</span></span></p>` +
+		`</div></body></tt>`
+
+	lyric, warnings, err := ParseLyricVerbose(input, ParseOptions{})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(lyric.LyricLines) != 2 {
+		t.Fatalf("expected main and background lines, got %d", len(lyric.LyricLines))
+	}
+	main, bg := lyric.LyricLines[0], lyric.LyricLines[1]
+	if main.TranslatedLyric != "Hello" || main.RomanLyric != "konnichiwa" {
+		t.Fatalf("unexpected main line translation/roman: %q / %q", main.TranslatedLyric, main.RomanLyric)
+	}
+	if bg.TranslatedLyric != "" || bg.RomanLyric != "haikei" {
+		t.Fatalf("unexpected background translation/roman: %q / %q", bg.TranslatedLyric, bg.RomanLyric)
+	}
+	translationLanguage := func(lyric TTMLLyric) string {
+		for _, meta := range lyric.Metadata {
+			if meta.Key == MetaKeyTranslationLanguage && len(meta.Value) == 1 {
+				return meta.Value[0]
+			}
+		}
+		return ""
+	}
+	if translationLanguage(lyric) != "en" {
+		t.Fatalf("expected translation language en, got %v", lyric.Metadata)
+	}
+	if len(warnings) != 1 || warnings[0].Path != "tt/head/metadata/amll:translation[2]" {
+		t.Fatalf("expected the zh-CN translation to be reported, got %v", warnings)
+	}
+
+	lyric, err = ParseLyricWithOptions(input, ParseOptions{TranslationLanguage: "zh-CN"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if lyric.LyricLines[0].TranslatedLyric != "你好" || lyric.LyricLines[1].TranslatedLyric != "背景" {
+		t.Fatalf("expected zh-CN translation, got %q / %q", lyric.LyricLines[0].TranslatedLyric, lyric.LyricLines[1].TranslatedLyric)
+	}
+	if translationLanguage(lyric) != "zh-CN" {
+		t.Fatalf("expected translation language zh-CN, got %v", lyric.Metadata)
+	}
+}
+
+func TestParseNestedTimedSpans(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml"><body><div>` +
+		`<p begin="00:01.000" end="00:04.000">` +
+		`<span begin="00:01.000" end="00:03.000"><span begin="00:01.000" end="00:01.500">lo</span>` +
+		`<span begin="00:01.500" end="00:02.000"><span begin="00:01.500" end="00:01.750">o</span><span begin="00:01.750" end="00:02.000">ve</span></span>` +
+		`-</span> <span begin="00:03.000" end="00:04.000">you</span></p>` +
+		`</div></body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(lyric.LyricLines) != 1 {
+		t.Fatalf("expected one line, got %d", len(lyric.LyricLines))
+	}
+	type timedText struct {
+		text       string
+		start, end float64
+	}
+	want := []timedText{
+		{"lo", 1000, 1500},
+		{"o", 1500, 1750},
+		{"ve", 1750, 2000},
+		{"-", 1000, 3000},
+		{" ", 0, 0},
+		{"you", 3000, 4000},
+	}
+	words := lyric.LyricLines[0].Words
+	if len(words) != len(want) {
+		t.Fatalf("expected %d words, got %d: %+v", len(want), len(words), words)
+	}
+	for i, w := range want {
+		got := timedText{words[i].Word, words[i].StartTime, words[i].EndTime}
+		if got != w {
+			t.Fatalf("word %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+}
+
+func TestParseDivTiming(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml"><body>` +
+		`<div begin="00:01.000" end="00:03.000"><p>first</p><p begin="00:01.500" end="00:02.000">own</p></div>` +
+		`<div><p>dropped</p></div>` +
+		`</body></tt>`
+
+	lyric, warnings, err := ParseLyricVerbose(input, ParseOptions{})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(lyric.LyricLines) != 2 {
+		t.Fatalf("expected two lines, got %d", len(lyric.LyricLines))
+	}
+	first, own := lyric.LyricLines[0], lyric.LyricLines[1]
+	if first.StartTime != 1000 || first.EndTime != 3000 {
+		t.Fatalf("expected the div window, got %v-%v", first.StartTime, first.EndTime)
+	}
+	if len(first.Words) != 1 || first.Words[0].Word != "first" || first.Words[0].StartTime != 1000 || first.Words[0].EndTime != 3000 {
+		t.Fatalf("unexpected words %+v", first.Words)
+	}
+	if own.StartTime != 1500 || own.EndTime != 2000 {
+		t.Fatalf("expected the paragraph's own timing, got %v-%v", own.StartTime, own.EndTime)
+	}
+	if len(warnings) != 1 || warnings[0].Path != "tt/body/div[2]/p" {
+		t.Fatalf("expected the untimed paragraph to be reported, got %v", warnings)
+	}
+}
+
+func TestParseLineDur(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml"><body><div>` +
+		`<p begin="00:01.000" dur="00:02.500"><span begin="00:01.000" end="00:02.000">one</span> <span begin="00:02.000" end="00:03.500">two</span></p>` +
+		`</div></body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(lyric.LyricLines) != 1 {
+		t.Fatalf("expected one line, got %d", len(lyric.LyricLines))
+	}
+	if line := lyric.LyricLines[0]; line.StartTime != 1000 || line.EndTime != 3500 {
+		t.Fatalf("expected 1000-3500, got %v-%v", line.StartTime, line.EndTime)
+	}
+
+	out := ExportTTMLTextWithOptions(lyric, ExportOptions{LineDur: true})
+	if !strings.Contains(out, `<p begin="00:01.000" dur="00:02.500"`) || strings.Contains(out, `<p begin="00:01.000" end=`) {
+		t.Fatalf("expected begin+dur on the paragraph:\n%s", out)
+	}
+	reparsed, err := ParseLyric(out)
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if !reparsed.Equal(lyric) {
+		t.Fatalf("dur paragraph did not round trip:\n got: %v\nwant: %v", reparsed, lyric)
+	}
+}
+
+func TestPreserveUnknownRoles(t *testing.T) {
+	future := `<span ttm:role="x-future" amll:level="2">data &amp; more</span>`
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata" xmlns:amll="http://www.example.com/ns/amll"><body><div>` +
+		`<p begin="00:01.000" end="00:02.000"><span begin="00:01.000" end="00:01.500">main</span> <span begin="00:01.500" end="00:02.000">line</span>` + future +
+		`<span ttm:role="x-bg"><span begin="00:01.500" end="00:02.000">(bg)</span><span ttm:role="x-note">note</span></span></p>` +
+		`</div></body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if lyric.LyricLines[0].RawSpans != nil || len(lyric.LyricLines[0].Words) != 3 {
+		t.Fatalf("unknown roles should be dropped by default: %+v", lyric.LyricLines[0])
+	}
+
+	lyric, err = ParseLyricWithOptions(input, ParseOptions{PreserveUnknownRoles: true})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	main, bg := lyric.LyricLines[0], lyric.LyricLines[1]
+	if !slices.Equal(main.RawSpans, []string{future}) || len(main.Words) != 3 {
+		t.Fatalf("unexpected main line raw spans %q", main.RawSpans)
+	}
+	if !slices.Equal(bg.RawSpans, []string{`<span ttm:role="x-note">note</span>`}) {
+		t.Fatalf("unexpected bg line raw spans %q", bg.RawSpans)
+	}
+
+	out := ExportTTMLText(lyric, false)
+	if !strings.Contains(out, future) {
+		t.Fatalf("raw span not re-emitted verbatim:\n%s", out)
+	}
+	reparsed, err := ParseLyricWithOptions(out, ParseOptions{PreserveUnknownRoles: true})
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if !reparsed.Equal(lyric) || !slices.Equal(reparsed.LyricLines[0].RawSpans, main.RawSpans) || !slices.Equal(reparsed.LyricLines[1].RawSpans, bg.RawSpans) {
+		t.Fatalf("raw spans did not round trip:\n%s", out)
+	}
+	if out := ExportTTMLTextWithOptions(lyric, ExportOptions{CompatTS: true}); strings.Contains(out, "x-future") {
+		t.Fatalf("CompatTS output must not carry raw spans:\n%s", out)
+	}
+
+	// NamespacePrefixes does not rename inside raw spans; the default
+	// declarations they rely on stay in place.
+	renamed := ExportTTMLTextWithOptions(lyric, ExportOptions{NamespacePrefixes: map[string]string{"tm": nsTTM}})
+	if !strings.Contains(renamed, future) || !strings.Contains(renamed, `xmlns:tm="`+nsTTM+`"`) || !strings.Contains(renamed, `xmlns:ttm="`+nsTTM+`"`) {
+		t.Fatalf("raw spans must keep their prefixes declared:\n%s", renamed)
+	}
+	reparsed, err = ParseLyricWithOptions(renamed, ParseOptions{PreserveUnknownRoles: true})
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	// The reparsed spans are qualified with the new prefix, which now wins.
+	if !reparsed.Equal(lyric) || !slices.Equal(reparsed.LyricLines[1].RawSpans, []string{`<span tm:role="x-note">note</span>`}) {
+		t.Fatalf("raw spans did not survive NamespacePrefixes:\n%s", renamed)
+	}
+	lyric.LyricLines[0].RawSpans, lyric.LyricLines[1].RawSpans = nil, nil
+	if plain := ExportTTMLTextWithOptions(lyric, ExportOptions{NamespacePrefixes: map[string]string{"tm": nsTTM}}); strings.Contains(plain, "xmlns:ttm=") {
+		t.Fatalf("default declaration kept without raw spans:\n%s", plain)
+	}
+}
+
+func TestParseLyricRaw(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata" xmlns:amll="http://www.example.com/ns/amll" xmlns:itunes="http://music.apple.com/lyric-ttml-internal">` +
+		`<head><metadata>` +
+		`<ttm:agent type="person" xml:id="v1"/>` +
+		`<ttm:title>Song</ttm:title>` +
+		`<amll:meta key="musicName" value="Song"/>` +
+		`<amll:meta key="draft"/>` +
+		`<iTunesMetadata xmlns="http://music.apple.com/lyric-ttml-internal">` +
+		`<songwriters><songwriter>A</songwriter></songwriters>` +
+		`<audio lyricOffset="120" role="spatial"/>` +
+		`</iTunesMetadata>` +
+		`</metadata></head><body><div>` +
+		`<p begin="00:01.000" end="00:02.000"><span begin="00:01.000" end="00:02.000">A</span></p>` +
+		`</div></body></tt>`
+
+	lyric, raw, err := ParseLyricRaw(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	plain, _ := ParseLyric(input)
+	if !lyric.Equal(plain) {
+		t.Fatalf("ParseLyricRaw lyric should match ParseLyric")
+	}
+	want := []RawMeta{
+		{Key: "ttm:title", Value: "Song", RawXML: `<ttm:title>Song</ttm:title>`},
+		{Key: "draft", RawXML: `<amll:meta key="draft"/>`},
+		{Key: "audio", RawXML: `<audio lyricOffset="120" role="spatial"/>`},
+	}
+	if len(raw) != len(want) {
+		t.Fatalf("expected %d raw entries, got %#v", len(want), raw)
+	}
+	for i := range want {
+		if raw[i] != want[i] {
+			t.Fatalf("raw[%d]: expected %#v, got %#v", i, want[i], raw[i])
+		}
+	}
+
+	if _, raw, err := ParseLyricRaw(`<tt xmlns="http://www.w3.org/ns/ttml"><body/></tt>`); err != nil || raw != nil {
+		t.Fatalf("expected no raw metadata, got %v, %v", raw, err)
+	}
+}
+
+func TestDocumentProfileRoundTrip(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" xml:lang="ja" ttp:cellResolution="40 24" ttp:frameRate="30">` +
+		`<body><div><p begin="00:01.000" end="00:02.000"><span begin="00:01.000" end="00:02.000">x</span></p></div></body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	want := DocumentProfile{Lang: "ja", CellResolution: "40 24", FrameRate: "30"}
+	if lyric.DocumentProfile != want {
+		t.Fatalf("unexpected profile: %#v", lyric.DocumentProfile)
+	}
+
+	out := ExportTTMLText(lyric, false)
+	if !strings.Contains(out, `xmlns:ttp="http://www.w3.org/ns/ttml#parameter"`) ||
+		!strings.Contains(out, `xml:lang="ja" ttp:cellResolution="40 24" ttp:frameRate="30"`) {
+		t.Fatalf("profile not emitted:\n%s", out)
+	}
+	reparsed, err := ParseLyric(out)
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if reparsed.DocumentProfile != want {
+		t.Fatalf("profile lost on round trip: %#v", reparsed.DocumentProfile)
+	}
+
+	lyric.DocumentProfile = DocumentProfile{}
+	if out := ExportTTMLText(lyric, false); strings.Contains(out, "xmlns:ttp") || strings.Contains(out, "xml:lang") {
+		t.Fatalf("empty profile should emit nothing:\n%s", out)
+	}
+}
+
+func TestLineRoleRoundTrip(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata"><body><div>` +
+		`<p begin="00:01.000" end="00:02.000"><span begin="00:01.000" end="00:02.000">lead</span>` +
+		`<span ttm:role="x-bg"><span begin="00:01.500" end="00:02.000">(bg)</span></span></p>` +
+		`<p begin="00:02.000" end="00:03.000" ttm:role="x-adlib"><span begin="00:02.000" end="00:03.000">oh</span></p>` +
+		`<p begin="00:03.000" end="00:04.000" ttm:role="x-harmony"><span begin="00:03.000" end="00:04.000">ah</span></p>` +
+		`</div></body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	want := []LineRole{LineRoleLead, LineRoleBackground, LineRoleAdLib, LineRoleHarmony}
+	if len(lyric.LyricLines) != len(want) {
+		t.Fatalf("expected %d lines, got %d", len(want), len(lyric.LyricLines))
+	}
+	for i, role := range want {
+		if got := lyric.LyricLines[i].EffectiveRole(); got != role {
+			t.Fatalf("line %d role = %v, want %v", i, got, role)
+		}
+	}
+
+	out := ExportTTMLText(lyric, false)
+	if !strings.Contains(out, `ttm:role="x-adlib"`) || !strings.Contains(out, `ttm:role="x-harmony"`) {
+		t.Fatalf("roles not emitted:\n%s", out)
+	}
+	reparsed, err := ParseLyric(out)
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if !lyric.Equal(reparsed) {
+		t.Fatalf("roles lost on TTML round trip")
+	}
+
+	encoded, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	decoded, err := DecodeBinary(encoded)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	for i, role := range want {
+		if got := decoded.LyricLines[i].EffectiveRole(); got != role {
+			t.Fatalf("decoded line %d role = %v, want %v", i, got, role)
+		}
+	}
+
+	// A background line cannot also carry a lead-line role.
+	invalid := lyric.clone()
+	invalid.LyricLines[1].Role = LineRoleHarmony
+	if _, err := EncodeBinary(invalid); err == nil {
+		t.Fatalf("expected background harmony line to be rejected by the encoder")
+	}
+	if issues := invalid.Validate(); len(issues) != 1 || issues[0].Severity != ValidationError || issues[0].Path != "line[1]" {
+		t.Fatalf("unexpected Validate issues: %v", issues)
+	}
+	if issues := CheckEncode(invalid); len(issues) != 1 || issues[0].Path != "line[1]" {
+		t.Fatalf("unexpected CheckEncode issues: %v", issues)
+	}
+	invalid.LyricLines[1].Role = LineRoleLead
+	invalid.LyricLines[0].Role = LineRoleBackground
+	if _, err := EncodeBinary(invalid); err == nil {
+		t.Fatalf("expected LineRoleBackground in Role to be rejected by the encoder")
+	}
+
+	single, err := EncodeBinary(TTMLLyric{LyricLines: []LyricLine{{Role: LineRoleHarmony, Words: []LyricWord{{Word: "ah"}}}}})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	_, info, err := DecodeBinaryInfo(single)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	// line_count, start_time and end_time take one byte each, then line_flags.
+	flagsAt := uint64(len(single)) - info.LyricDataSize + 3
+	if single[flagsAt] != lineFlagRoleHarmony {
+		t.Fatalf("unexpected line flags 0x%02x", single[flagsAt])
+	}
+	single[flagsAt] |= lineFlagIsBG
+	if _, err := DecodeBinary(single); err == nil {
+		t.Fatalf("expected background harmony line to be rejected by the decoder")
+	}
+	if err := VerifyBinaryIntegrity(single); err == nil {
+		t.Fatalf("expected background harmony line to fail the integrity check")
+	}
+}
+
+func TestParseBackgroundLineOrdering(t *testing.T) {
+	const head = `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata"><body><div>`
+	const tail = `</div></body></tt>`
+	p := func(begin, end, word string, bgs ...string) string {
+		out := `<p begin="` + begin + `" end="` + end + `"><span begin="` + begin + `" end="` + end + `">` + word + `</span>`
+		for _, bg := range bgs {
+			out += `<span ttm:role="x-bg"><span begin="` + begin + `" end="` + end + `">(` + bg + `)</span></span>`
+		}
+		return out + `</p>`
+	}
+
+	tests := []struct {
+		name  string
+		body  string
+		words []string
+		isBG  []bool
+	}{
+		{
+			name:  "main with bg",
+			body:  p("00:01.000", "00:02.000", "a", "a-bg"),
+			words: []string{"a", "a-bg"},
+			isBG:  []bool{false, true},
+		},
+		{
+			name:  "two mains each with bg",
+			body:  p("00:01.000", "00:02.000", "a", "a-bg") + p("00:02.000", "00:03.000", "b", "b-bg"),
+			words: []string{"a", "a-bg", "b", "b-bg"},
+			isBG:  []bool{false, true, false, true},
+		},
+		{
+			name:  "main with two bg spans",
+			body:  p("00:01.000", "00:02.000", "a") + p("00:02.000", "00:03.000", "b", "b-bg1", "b-bg2"),
+			words: []string{"a", "b", "b-bg1", "b-bg2"},
+			isBG:  []bool{false, false, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lyric, err := ParseLyric(head + tt.body + tail)
+			if err != nil {
+				t.Fatalf("parse failed: %v", err)
+			}
+			if len(lyric.LyricLines) != len(tt.words) {
+				t.Fatalf("expected %d lines, got %d", len(tt.words), len(lyric.LyricLines))
+			}
+			for i, line := range lyric.LyricLines {
+				if line.Words[0].Word != tt.words[i] || line.IsBG != tt.isBG[i] {
+					t.Fatalf("line %d = %q (bg=%t), want %q (bg=%t)", i, line.Words[0].Word, line.IsBG, tt.words[i], tt.isBG[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExportUntimedLyric(t *testing.T) {
+	lyric := TTMLLyric{
+		LyricLines: []LyricLine{
+			{Words: []LyricWord{{Word: "Hello"}, {Word: " "}, {Word: "world"}}, TranslatedLyric: "你好世界"},
+			{Words: []LyricWord{{Word: "echo"}}, IsBG: true},
+			{Words: []LyricWord{{Word: "Second line"}}},
+		},
+	}
+
+	out := ExportTTMLText(lyric, false)
+	if !strings.Contains(out, `itunes:timing="None"`) {
+		t.Fatalf("expected untimed document:\n%s", out)
+	}
+	for _, attr := range []string{"begin=", "end=", "dur="} {
+		if strings.Contains(out, attr) {
+			t.Fatalf("untimed export must not write %s:\n%s", attr, out)
+		}
+	}
+	if !strings.Contains(out, `<p ttm:agent="v1" itunes:key="L1">Hello world<span ttm:role="x-bg">(echo)</span>`) {
+		t.Fatalf("words should be plain text:\n%s", out)
+	}
+
+	reparsed, err := ParseLyric(out)
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if len(reparsed.LyricLines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(reparsed.LyricLines))
+	}
+	if got := reparsed.LyricLines[0]; got.Words[0].Word != "Hello world" || got.TranslatedLyric != "你好世界" {
+		t.Fatalf("unexpected first line: %v", got)
+	}
+	if got := reparsed.LyricLines[1]; !got.IsBG || got.Words[0].Word != "echo" || got.StartTime != 0 {
+		t.Fatalf("unexpected bg line: %v", got)
+	}
+	if !isUntimedLyric(reparsed.LyricLines) {
+		t.Fatalf("reparsed lyric should stay untimed")
+	}
+}
+
+func TestExportEmptyLyric(t *testing.T) {
+	for _, opts := range []ExportOptions{{}, {Pretty: true}, {CompatTS: true}, {LineBreaks: true}} {
+		out := ExportTTMLTextWithOptions(TTMLLyric{}, opts)
+		if !strings.Contains(out, `<body dur="00:00.000"`) || strings.Contains(out, "<div") {
+			t.Fatalf("%+v: expected an empty body:\n%s", opts, out)
+		}
+		var buf bytes.Buffer
+		if err := ExportTTML(&buf, TTMLLyric{}, opts); err != nil || buf.String() != out {
+			t.Fatalf("%+v: streamed export differs (%v):\n%s", opts, err, buf.String())
+		}
+		reparsed, err := ParseLyric(out)
+		if err != nil {
+			t.Fatalf("%+v: reparse failed: %v", opts, err)
+		}
+		if !reparsed.Equal(TTMLLyric{}) {
+			t.Fatalf("%+v: expected an empty lyric, got %v", opts, reparsed)
+		}
+	}
+}
+
+func TestTimedTranslationRoundTrip(t *testing.T) {
+	lyric := TTMLLyric{
+		LyricLines: []LyricLine{
+			{
+				StartTime: 1000,
+				EndTime:   3000,
+				Words: []LyricWord{
+					{StartTime: 1000, EndTime: 2000, Word: "Hello"},
+					{Word: " "},
+					{StartTime: 2000, EndTime: 3000, Word: "world"},
+				},
+				TranslatedLyric: "你好 世界",
+				TranslatedWords: []LyricWord{
+					{StartTime: 1000, EndTime: 2000, Word: "你好"},
+					{Word: " "},
+					{StartTime: 2000, EndTime: 3000, Word: "世界"},
+				},
+			},
+		},
+	}
+
+	out := ExportTTMLText(lyric, false)
+	want := `<span ttm:role="x-translation" xml:lang="zh-CN"><span begin="00:01.000" end="00:02.000">你好</span> <span begin="00:02.000" end="00:03.000">世界</span></span>`
+	if !strings.Contains(out, want) {
+		t.Fatalf("timed translation not emitted:\n%s", out)
+	}
+
+	reparsed, err := ParseLyric(out)
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	line := reparsed.LyricLines[0]
+	if line.TranslatedLyric != "你好 世界" {
+		t.Fatalf("fallback text = %q", line.TranslatedLyric)
+	}
+	if len(line.TranslatedWords) != 3 || line.TranslatedWords[2].Word != "世界" ||
+		line.TranslatedWords[2].StartTime != 2000 || line.TranslatedWords[2].EndTime != 3000 {
+		t.Fatalf("unexpected translated words: %v", line.TranslatedWords)
+	}
+
+	compat := ExportTTMLTextWithOptions(lyric, ExportOptions{CompatTS: true})
+	if !strings.Contains(compat, `<span ttm:role="x-translation" xml:lang="zh-CN">你好 世界</span>`) {
+		t.Fatalf("CompatTS should write the plain-text fallback:\n%s", compat)
+	}
+}
+
+func TestParseMultipleBodies(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml">` +
+		`<body><p begin="00:01.000" end="00:02.000"><span begin="00:01.000" end="00:02.000">a</span></p>` +
+		`<p begin="00:02.000" end="00:03.000"><span begin="00:02.000" end="00:03.000">b</span></p></body>` +
+		`<body><p begin="00:04.000" end="00:05.000"><span begin="00:04.000" end="00:05.000">c</span></p></body>` +
+		`</tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	var got []string
+	for _, line := range lyric.LyricLines {
+		if len(line.Words) == 0 {
+			got = append(got, "|")
+			continue
+		}
+		got = append(got, line.Words[0].Word)
+	}
+	if strings.Join(got, " ") != "a b | c" {
+		t.Fatalf("expected a section break between bodies, got %v", got)
+	}
+}
+
+func TestWordBackgroundRoundTrip(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:amll="http://www.example.com/ns/amll"><body><div>` +
+		`<p begin="00:01.000" end="00:03.000"><span begin="00:01.000" end="00:02.000">lead</span> ` +
+		`<span begin="00:02.000" end="00:03.000" amll:bg="true">whisper</span></p>` +
+		`</div></body></tt>`
+
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	words := lyric.LyricLines[0].Words
+	if len(words) != 3 || words[0].Background || !words[2].Background {
+		t.Fatalf("unexpected word background flags: %v", words)
+	}
+
+	out := ExportTTMLText(lyric, false)
+	if !strings.Contains(out, `amll:bg="true">whisper</span>`) {
+		t.Fatalf("word background not emitted:\n%s", out)
+	}
+	reparsed, err := ParseLyric(out)
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if !reparsed.Equal(lyric) {
+		t.Fatalf("word background lost on TTML round trip")
+	}
+
+	encoded, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	decoded, err := DecodeBinary(encoded)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if got := decoded.LyricLines[0].Words; got[0].Background || !got[2].Background {
+		t.Fatalf("word background lost on AMLX round trip: %v", got)
+	}
+}
+
+func TestInstrumentalMarkerRoundTrip(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml"><body><div>` +
+		`<p begin="00:01.000" end="00:09.000"><span begin="00:01.000" end="00:02.000">intro</span> ` +
+		`<span begin="00:02.000" end="00:08.000"></span><span begin="00:08.000" end="00:09.000">verse</span></p>` +
+		`</div></body></tt>`
+
+	plain, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if words := plain.LyricLines[0].Words; len(words) != 4 || words[2].InstrumentalMarker || !words[2].IsBlank() {
+		t.Fatalf("empty spans must stay blank tokens by default: %v", words)
+	}
+
+	opts := ParseOptions{InstrumentalMarkers: true}
+	lyric, err := ParseLyricWithOptions(input, opts)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	words := lyric.LyricLines[0].Words
+	if len(words) != 4 || words[1].InstrumentalMarker || !words[2].InstrumentalMarker || words[2].StartTime != 2000 {
+		t.Fatalf("unexpected marker flags: %v", words)
+	}
+
+	out := ExportTTMLText(lyric, false)
+	if !strings.Contains(out, `<span begin="00:02.000" end="00:08.000"></span>`) {
+		t.Fatalf("instrumental marker dropped on export:\n%s", out)
+	}
+	reparsed, err := ParseLyricWithOptions(out, opts)
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if !reparsed.Equal(lyric) {
+		t.Fatalf("marker lost on TTML round trip: %v", reparsed.LyricLines[0])
+	}
+
+	encoded, err := EncodeBinary(lyric)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	decoded, err := DecodeBinary(encoded)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if got := decoded.LyricLines[0].Words; !got[2].InstrumentalMarker || got[1].InstrumentalMarker {
+		t.Fatalf("marker lost on AMLX round trip: %v", got)
+	}
+}
+
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write([]byte) (int, error) { return 0, w.err }
+
+func TestExportTTMLWriter(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:amll="http://www.example.com/ns/amll"><head><metadata><amll:meta key="musicName" value="A &amp; B"/></metadata></head><body><div>` +
+		`<p begin="00:01.000" end="00:03.000"><span begin="00:01.000" end="00:02.000">lead</span> <span begin="00:02.000" end="00:03.000">line</span></p>` +
+		`</div></body></tt>`
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	for _, opts := range []ExportOptions{{}, {Pretty: true}} {
+		var buf bytes.Buffer
+		if err := ExportTTML(&buf, lyric, opts); err != nil {
+			t.Fatalf("export failed: %v", err)
+		}
+		if want := ExportTTMLTextWithOptions(lyric, opts); buf.String() != want {
+			t.Fatalf("streamed output differs (pretty=%v):\n got: %s\nwant: %s", opts.Pretty, buf.String(), want)
+		}
+	}
+
+	wantErr := errors.New("disk full")
+	if err := ExportTTML(failingWriter{wantErr}, lyric, ExportOptions{}); !errors.Is(err, wantErr) {
+		t.Fatalf("expected write error, got %v", err)
+	}
+}
+
+func TestWhitespaceWordsRoundTrip(t *testing.T) {
+	line := NewLyricLine()
+	line.StartTime, line.EndTime = 0, 3000
+	line.Words = []LyricWord{
+		{Word: "A", StartTime: 0, EndTime: 1000},
+		{Word: "  "},
+		{Word: "B", StartTime: 1000, EndTime: 2000},
+		{Word: " "},
+		{Word: "C", StartTime: 2000, EndTime: 2500},
+		{Word: "\t"},
+		{Word: "D", StartTime: 2500, EndTime: 3000},
+	}
+	spaced := TTMLLyric{LyricLines: []LyricLine{line}}
+
+	for _, pretty := range []bool{false, true} {
+		out := ExportTTMLText(spaced, pretty)
+		if !strings.Contains(out, `xml:space="preserve"`) {
+			t.Fatalf("pretty=%v: expected xml:space=\"preserve\" on the paragraph:\n%s", pretty, out)
+		}
+		parsed, err := ParseLyric(out)
+		if err != nil {
+			t.Fatalf("pretty=%v: parse failed: %v", pretty, err)
+		}
+		words := parsed.LyricLines[0].Words
+		if len(words) != len(line.Words) {
+			t.Fatalf("pretty=%v: expected %d words, got %v", pretty, len(line.Words), parsed.LyricLines[0])
+		}
+		for i := range words {
+			if words[i].Word != line.Words[i].Word {
+				t.Fatalf("pretty=%v: word %d changed from %q to %q", pretty, i, line.Words[i].Word, words[i].Word)
+			}
+		}
+	}
+
+	line.Words = []LyricWord{{Word: "A", StartTime: 0, EndTime: 1000}, {Word: " "}, {Word: "B", StartTime: 1000, EndTime: 2000}}
+	if out := ExportTTMLText(TTMLLyric{LyricLines: []LyricLine{line}}, false); strings.Contains(out, "xml:space") {
+		t.Fatalf("single spaces should not mark the paragraph:\n%s", out)
+	}
+}
+
+func TestExportLineBreaks(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml"><body>` +
+		`<div><p begin="00:01.000" end="00:03.000"><span begin="00:01.000" end="00:02.000">lead</span> <span begin="00:02.000" end="00:03.000">line</span></p>` +
+		`<p begin="00:03.000" end="00:04.000"><span begin="00:03.000" end="00:04.000">next</span></p></div>` +
+		`<div><p begin="00:05.000" end="00:06.000"><span begin="00:05.000" end="00:06.000">verse</span></p></div>` +
+		`</body></tt>`
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	out := ExportTTMLTextWithOptions(lyric, ExportOptions{LineBreaks: true})
+	paragraphs := 0
+	for _, row := range strings.Split(out, "\n") {
+		if strings.HasPrefix(row, " ") {
+			t.Fatalf("line breaks should not indent: %q", row)
+		}
+		if strings.HasPrefix(row, "<p ") {
+			paragraphs++
+			if !strings.HasSuffix(row, "</p>") {
+				t.Fatalf("paragraph split across lines: %q", row)
+			}
+		}
+	}
+	if paragraphs != 3 {
+		t.Fatalf("expected 3 paragraph lines, got %d:\n%s", paragraphs, out)
+	}
+
+	reparsed, err := ParseLyric(out)
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if !reparsed.Equal(lyric) || reparsed.WasPretty {
+		t.Fatalf("line-broken output did not round trip (WasPretty=%v):\n%s", reparsed.WasPretty, out)
+	}
+	if ExportTTMLText(reparsed, false) != ExportTTMLText(lyric, false) {
+		t.Fatalf("line breaks leaked into the lyric")
+	}
+}
+
+func TestReExportKeepsPrettiness(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml"><body><div>` +
+		`<p begin="00:01.000" end="00:03.000"><span begin="00:01.000" end="00:02.000">lead</span> <span begin="00:02.000" end="00:03.000">line</span></p>` +
+		`</div></body></tt>`
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if lyric.WasPretty {
+		t.Fatalf("compact input detected as pretty")
+	}
+
+	for _, pretty := range []bool{false, true} {
+		text := ExportTTMLText(lyric, pretty)
+		parsed, err := ParseLyric(text)
+		if err != nil {
+			t.Fatalf("reparse failed: %v", err)
+		}
+		if parsed.WasPretty != pretty {
+			t.Fatalf("WasPretty = %v for pretty=%v output", parsed.WasPretty, pretty)
+		}
+		if got, want := ReExport(parsed, ExportOptions{}), ExportTTMLText(parsed, pretty); got != want {
+			t.Fatalf("re-export changed layout (pretty=%v):\n got: %s\nwant: %s", pretty, got, want)
+		}
+	}
+}
+
+func TestExportBackgroundAsSiblingParagraph(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata"><body><div>` +
+		`<p begin="00:01.000" end="00:03.000"><span begin="00:01.000" end="00:02.000">lead</span> <span begin="00:02.000" end="00:03.000">line</span>` +
+		`<span ttm:role="x-bg"><span begin="00:02.000" end="00:03.000">(echo)</span><span ttm:role="x-translation">回声</span></span></p>` +
+		`<p begin="00:03.000" end="00:04.000"><span begin="00:03.000" end="00:04.000">next</span></p>` +
+		`</div></body></tt>`
+	lyric, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	out := ExportTTMLTextWithOptions(lyric, ExportOptions{BackgroundAsSiblingParagraph: true})
+	if !strings.Contains(out, `</p><p ttm:role="x-bg" begin="00:02.000" end="00:03.000" ttm:agent="v1" itunes:key="L1">`) {
+		t.Fatalf("expected a sibling bg paragraph:\n%s", out)
+	}
+	if strings.Contains(out, `<span ttm:role="x-bg"`) {
+		t.Fatalf("bg line should not be nested:\n%s", out)
+	}
+	reparsed, err := ParseLyric(out)
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if !reparsed.Equal(lyric) {
+		t.Fatalf("sibling bg paragraph did not round trip:\n got: %v\nwant: %v", reparsed.LyricLines, lyric.LyricLines)
+	}
+}
+
+func TestExportOmitAgentsAndMetadata(t *testing.T) {
+	lyric := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: "musicName", Value: []string{"Song"}}},
+		LyricLines: []LyricLine{
+			{StartTime: 1000, EndTime: 2000, Words: []LyricWord{{StartTime: 1000, EndTime: 2000, Word: "lead"}}},
+			{StartTime: 2000, EndTime: 3000, IsDuet: true, Words: []LyricWord{{StartTime: 2000, EndTime: 3000, Word: "duet"}}},
+		},
+	}
+
+	out := ExportTTMLTextWithOptions(lyric, ExportOptions{OmitAgents: true})
+	if strings.Contains(out, "ttm:agent") {
+		t.Fatalf("agents should be omitted:\n%s", out)
+	}
+	if !strings.Contains(out, `<amll:meta key="musicName" value="Song"/>`) {
+		t.Fatalf("metadata should be kept:\n%s", out)
+	}
+
+	out = ExportTTMLTextWithOptions(lyric, ExportOptions{OmitMetadata: true})
+	if strings.Contains(out, "<metadata") || strings.Contains(out, "ttm:agent") {
+		t.Fatalf("metadata block should be omitted:\n%s", out)
+	}
+	reparsed, err := ParseLyric(out)
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if len(reparsed.LyricLines) != 2 || reparsed.LyricLines[1].Words[0].Word != "duet" {
+		t.Fatalf("unexpected lines %v", reparsed.LyricLines)
+	}
+}
+
+func TestExportNamespacePrefixes(t *testing.T) {
+	lyric := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: "musicName", Value: []string{"Song"}}},
+		LyricLines: []LyricLine{
+			{StartTime: 1000, EndTime: 2000, Words: []LyricWord{
+				{StartTime: 1000, EndTime: 1500, Word: "oh"},
+				{StartTime: 1500, EndTime: 2000, Word: "damn", Obscene: true},
+			}},
+		},
+	}
+
+	out := ExportTTMLTextWithOptions(lyric, ExportOptions{NamespacePrefixes: map[string]string{
+		"lyr":    nsAMLL,
+		"xmlfoo": nsTTM,
+		"ttm":    nsItunes,
+		"other":  "urn:unused",
+	}})
+	for _, want := range []string{`xmlns:lyr="` + nsAMLL + `"`, `<lyr:meta key="musicName" value="Song"/>`, `lyr:obscene="true"`, `ttm:agent="v1"`, `itunes:key="L1"`} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %s in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "amll:") || strings.Contains(out, "xmlfoo") || strings.Contains(out, "other") {
+		t.Fatalf("unexpected prefixes in:\n%s", out)
+	}
+
+	reparsed, err := ParseLyric(out)
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if !reparsed.Equal(lyric) {
+		t.Fatalf("rebound prefixes did not round trip:\n got: %v\nwant: %v", reparsed, lyric)
+	}
+}
+
+func TestExportLineTimedKeepsAllTokens(t *testing.T) {
+	lyric := TTMLLyric{
+		LyricLines: []LyricLine{
+			{
+				StartTime: 1000,
+				EndTime:   2000,
+				Words: []LyricWord{
+					{Word: " "},
+					{StartTime: 1000, EndTime: 2000, Word: "hello"},
+					{Word: "  "},
+				},
+			},
+		},
+	}
+	out := ExportTTMLText(lyric, false)
+	if !strings.Contains(out, `<p begin="00:01.000" end="00:02.000" ttm:agent="v1" itunes:key="L1"> hello  </p>`) {
+		t.Fatalf("line-timed paragraph lost tokens or timing:\n%s", out)
+	}
+}
+
+func TestParseDivBoundariesRoundTrip(t *testing.T) {
+	lyric := TTMLLyric{
+		LyricLines: []LyricLine{
+			{StartTime: 0, EndTime: 1000, Words: []LyricWord{{StartTime: 0, EndTime: 500, Word: "a"}, {StartTime: 500, EndTime: 1000, Word: "b"}}},
+			{},
+			{StartTime: 2000, EndTime: 3000, Words: []LyricWord{{StartTime: 2000, EndTime: 3000, Word: "c"}}},
+		},
+	}
+	out := ExportTTMLText(lyric, false)
+	if strings.Count(out, "<div ") != 2 {
+		t.Fatalf("expected two divs:\n%s", out)
+	}
+	parsed, err := ParseLyric(out)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(parsed.LyricLines) != 3 || len(parsed.LyricLines[1].Words) != 0 {
+		t.Fatalf("expected an empty separator line between divs: %#v", parsed.LyricLines)
+	}
+	if ExportTTMLText(parsed, false) != out {
+		t.Fatalf("division structure not preserved on re-export")
+	}
+}
+
+func TestPhonemeRoundTrip(t *testing.T) {
+	lyric := TTMLLyric{
+		LyricLines: []LyricLine{
+			{
+				StartTime: 0,
+				EndTime:   1000,
+				Words: []LyricWord{
+					{StartTime: 0, EndTime: 500, Word: "你", Phoneme: "ni3"},
+					{StartTime: 500, EndTime: 1000, Word: "好", Phoneme: "hao3"},
+				},
+			},
+		},
+	}
+	out := ExportTTMLText(lyric, false)
+	if !strings.Contains(out, `amll:phoneme="ni3"`) {
+		t.Fatalf("phoneme not written:\n%s", out)
+	}
+	parsed, err := ParseLyric(out)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if !parsed.Equal(lyric) {
+		t.Fatalf("phoneme round-trip mismatch: %#v", parsed.LyricLines)
+	}
+}
+
+func TestPreserveXMLIDRoundTrip(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata"><body><div>` +
+		`<p begin="00:01.000" end="00:02.000" xml:id="cue-1"><span begin="00:01.000" end="00:01.500" xml:id="cue-1-w1">a</span> <span begin="00:01.500" end="00:02.000">b</span></p>` +
+		`</div></body></tt>`
+
+	plain, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if plain.LyricLines[0].XMLID != "" {
+		t.Fatalf("xml:id must not be captured by default")
+	}
+
+	lyric, err := ParseLyricWithOptions(input, ParseOptions{PreserveXMLID: true})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if lyric.LyricLines[0].XMLID != "cue-1" || lyric.LyricLines[0].Words[0].XMLID != "cue-1-w1" {
+		t.Fatalf("xml:id not captured: %#v", lyric.LyricLines[0])
+	}
+	out := ExportTTMLText(lyric, false)
+	if !strings.Contains(out, `xml:id="cue-1"`) || !strings.Contains(out, `xml:id="cue-1-w1"`) {
+		t.Fatalf("xml:id not re-emitted:\n%s", out)
+	}
+}
+
+func TestParseMetadataKeyOrder(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`<tt xmlns="http://www.w3.org/ns/ttml" xmlns:amll="http://www.example.com/ns/amll"><head><metadata>`)
+	b.WriteString(`<amll:meta key="b" value="1"/><amll:meta key="a" value="1"/><amll:meta key="b" value="2"/>`)
+	const extra = 500
+	for i := 0; i < extra; i++ {
+		fmt.Fprintf(&b, `<amll:meta key="custom%d" value="%d"/>`, i, i)
+	}
+	b.WriteString(`<amll:meta key="a" value="2"/><amll:meta key="b" value="3"/>`)
+	b.WriteString(`</metadata></head><body><div><p begin="00:01.000" end="00:02.000"><span begin="00:01.000" end="00:02.000">x</span></p></div></body></tt>`)
+
+	lyric, err := ParseLyric(b.String())
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(lyric.Metadata) != extra+2 {
+		t.Fatalf("got %d metadata keys, want %d", len(lyric.Metadata), extra+2)
+	}
+	// Keys keep first-seen order; values keep document order.
+	if got := lyric.Metadata[0]; got.Key != "b" || !slices.Equal(got.Value, []string{"1", "2", "3"}) {
+		t.Fatalf("unexpected first key: %#v", got)
+	}
+	if got := lyric.Metadata[1]; got.Key != "a" || !slices.Equal(got.Value, []string{"1", "2"}) {
+		t.Fatalf("unexpected second key: %#v", got)
+	}
+	for i, meta := range lyric.Metadata[2:] {
+		if want := fmt.Sprintf("custom%d", i); meta.Key != want {
+			t.Fatalf("metadata[%d] key = %q, want %q", i+2, meta.Key, want)
+		}
+	}
+}
+
+func TestExportParagraph(t *testing.T) {
+	word := func(start, end float64, text string) LyricWord {
+		return LyricWord{StartTime: start, EndTime: end, Word: text}
+	}
+	lyric := TTMLLyric{
+		Metadata: []TTMLMetadata{{Key: MetaKeyTranslationLanguage, Value: []string{"ja"}}},
+		LyricLines: []LyricLine{
+			{StartTime: 0, EndTime: 1000, TranslatedLyric: "一", Words: []LyricWord{word(0, 500, "a"), word(500, 500, " "), word(500, 1000, "b")}},
+			{StartTime: 600, EndTime: 900, IsBG: true, Words: []LyricWord{word(600, 900, "bg1")}},
+			{StartTime: 700, EndTime: 950, IsBG: true, RomanLyric: "r", Words: []LyricWord{word(700, 950, "bg2")}},
+			{StartTime: 1000, EndTime: 2000, IsDuet: true, Words: []LyricWord{word(1000, 2000, "c")}},
+			{StartTime: 2000, EndTime: 2000},
+			{StartTime: 3000, EndTime: 4000, IsBG: true, Words: []LyricWord{word(3000, 4000, "d")}},
+			{StartTime: 4000, EndTime: 5000, Words: []LyricWord{word(4000, 5000, "e & <f>")}},
+		},
+	}
+	untimed := TTMLLyric{LyricLines: []LyricLine{
+		{Words: []LyricWord{{Word: "plain"}}},
+		{Words: []LyricWord{{Word: "text"}}},
+	}}
+	paragraphs := map[int]string{0: "L1", 3: "L2", 5: "L3", 6: "L4"}
+
+	for name, opts := range map[string]ExportOptions{
+		"compact":    {},
+		"pretty":     {Pretty: true},
+		"linebreaks": {LineBreaks: true},
+		"sibling":    {BackgroundAsSiblingParagraph: true, LineBreaks: true},
+		"dur":        {LineDur: true, NamespacePrefixes: map[string]string{"x": nsAMLL}},
+		"itunes":     {ITunesTranslations: true},
+	} {
+		full := ExportTTMLTextWithOptions(lyric, opts)
+		for idx, key := range paragraphs {
+			got, err := ExportParagraph(lyric, idx, opts)
+			if err != nil {
+				t.Fatalf("%s: line %d: %v", name, idx, err)
+			}
+			if !strings.HasPrefix(got, "<p ") || !strings.Contains(got, `itunes:key="`+key+`"`) || !strings.Contains(full, got) {
+				t.Fatalf("%s: line %d paragraph not found in full export:\n%s\n--- full ---\n%s", name, idx, got, full)
+			}
+		}
+		got, err := ExportParagraph(lyric, 0, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !strings.Contains(got, "bg1") || !strings.Contains(got, "bg2") {
+			t.Fatalf("%s: background lines missing from paragraph:\n%s", name, got)
+		}
+
+		plainFull := ExportTTMLTextWithOptions(untimed, opts)
+		plain, err := ExportParagraph(untimed, 1, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if strings.Contains(plain, "begin=") || !strings.Contains(plainFull, plain) {
+			t.Fatalf("%s: untimed paragraph mismatch:\n%s\n--- full ---\n%s", name, plain, plainFull)
+		}
+	}
+
+	for _, idx := range []int{-1, 1, 2, 4, len(lyric.LyricLines)} {
+		if _, err := ExportParagraph(lyric, idx, ExportOptions{}); err == nil {
+			t.Fatalf("line %d should not export as a paragraph", idx)
+		}
+	}
+}
+
+func TestPreserveITunesKey(t *testing.T) {
+	input := `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata" xmlns:itunes="http://music.apple.com/lyric-ttml-internal"><head><metadata>` +
+		`<iTunesMetadata xmlns="http://music.apple.com/lyric-ttml-internal"><translations><translation type="subtitle" xml:lang="zh-CN">` +
+		`<text for="a1">一</text><text for="a2">二</text><text for="a3">三</text>` +
+		`</translation></translations></iTunesMetadata></metadata></head><body><div>` +
+		`<p begin="00:01.000" end="00:02.000" itunes:key="a1">one</p>` +
+		`<p begin="00:02.000" end="00:03.000" itunes:key="a2">two</p>` +
+		`<p begin="00:03.000" end="00:04.000" itunes:key="a3">three</p>` +
+		`</div></body></tt>`
+
+	plain, err := ParseLyric(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if plain.LyricLines[0].ITunesKey != "" {
+		t.Fatalf("itunes:key must not be captured by default")
+	}
+
+	lyric, err := ParseLyricWithOptions(input, ParseOptions{PreserveITunesKey: true})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if lyric.LyricLines[2].ITunesKey != "a3" || lyric.LyricLines[2].TranslatedLyric != "三" {
+		t.Fatalf("itunes:key not captured: %#v", lyric.LyricLines[2])
+	}
+
+	// Dropping a line keeps the remaining keys and their translation links;
+	// a new line without a key, and a duplicate key, get fresh ones.
+	if err := lyric.RemoveLine(1); err != nil {
+		t.Fatalf("RemoveLine failed: %v", err)
+	}
+	extra := LyricLine{StartTime: 5000, EndTime: 6000, TranslatedLyric: "五", Words: []LyricWord{{StartTime: 5000, EndTime: 6000, Word: "five"}}}
+	dup := LyricLine{StartTime: 6000, EndTime: 7000, ITunesKey: "a1", Words: []LyricWord{{StartTime: 6000, EndTime: 7000, Word: "six"}}}
+	lyric.LyricLines = append(lyric.LyricLines, extra, dup)
+	opts := ExportOptions{ITunesTranslations: true}
+	out := ExportTTMLTextWithOptions(lyric, opts)
+	for _, want := range []string{`itunes:key="a1">one`, `itunes:key="a3">three`, `<text for="a3">三</text>`, `itunes:key="L1">five`, `<text for="L1">五</text>`, `itunes:key="L2">six`} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in export:\n%s", want, out)
+		}
+	}
+	if paragraph, err := ExportParagraph(lyric, 1, opts); err != nil || !strings.Contains(paragraph, `itunes:key="a3"`) || !strings.Contains(out, paragraph) {
+		t.Fatalf("ExportParagraph mismatch (%v):\n%s", err, paragraph)
+	}
+
+	reparsed, err := ParseLyricWithOptions(out, ParseOptions{PreserveITunesKey: true})
+	if err != nil {
+		t.Fatalf("reparse failed: %v", err)
+	}
+	if !reparsed.Equal(lyric) || reparsed.LyricLines[1].ITunesKey != "a3" {
+		t.Fatalf("round trip mismatch: %#v", reparsed.LyricLines)
+	}
+
+	if compat := ExportTTMLTextWithOptions(lyric, ExportOptions{CompatTS: true}); strings.Contains(compat, `"a3"`) {
+		t.Fatalf("CompatTS output must keep generated keys:\n%s", compat)
+	}
+}
@@ -1,14 +1,272 @@
 package ttml
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
 	"math"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
+// BGParenMode controls where the writer places the parentheses that mark a
+// background line.
+type BGParenMode int
+
+const (
+	// BGParenInWord prepends "(" to the first non-blank word and appends ")"
+	// to the last one, matching the TS writer.
+	BGParenInWord BGParenMode = iota
+	// BGParenStandalone emits the parentheses as separate text nodes at the
+	// edges of the x-bg span, leaving word spans untouched.
+	BGParenStandalone
+)
+
+// ExportOptions controls how ExportTTMLTextWithOptions renders TTML.
+type ExportOptions struct {
+	// Pretty indents element-only content.
+	Pretty bool
+	// BGParenMode selects how background line parentheses are written.
+	BGParenMode BGParenMode
+	// ITunesTranslations writes line translations into an
+	// iTunesMetadata/translations block keyed by itunes:key instead of inline
+	// x-translation spans. Each background line of the keyed line gets its
+	// own x-bg span there, in line order.
+	ITunesTranslations bool
+	// CompatTS reproduces the reference TS writer byte for byte: fields the TS
+	// model lacks (xml:id, phoneme, translation language, raw spans) are not written,
+	// BGParenMode and ITunesTranslations are ignored, and line-timed lines
+	// keep only their first word as the TS writer does. The one intentional
+	// difference is that background lines without timed words use the line
+	// timing rather than the TS writer's invalid Infinity timestamps.
+	CompatTS bool
+	// BackgroundAsSiblingParagraph writes background lines as a
+	// <p ttm:role="x-bg"> following their main line instead of a nested x-bg
+	// span, for players that do not understand nested background spans.
+	// Ignored with CompatTS.
+	BackgroundAsSiblingParagraph bool
+	// LineBreaks, without Pretty, starts <head>, <body>, every <div> and
+	// every <p> on a new unindented line, keeping compact output small but
+	// diff-friendly line by line. Paragraph content is unchanged.
+	LineBreaks bool
+	// OmitAgents leaves out the ttm:agent declarations and the ttm:agent
+	// attributes of lines, for players confused by them. Duet lines can then
+	// no longer be told apart.
+	OmitAgents bool
+	// OmitMetadata leaves out the whole <metadata> block: agents (as with
+	// OmitAgents), amll:meta entries and the iTunesMetadata block with
+	// songwriters, iTunes translations and word romanizations.
+	OmitMetadata bool
+	// NamespacePrefixes binds prefixes to namespace URIs, replacing the
+	// default ttm, amll, itunes and ttp prefixes of those namespaces, e.g.
+	// {"lyr": "http://www.example.com/ns/amll"} writes lyr:meta and
+	// xmlns:lyr. Bindings for other URIs, reserved or malformed prefixes and
+	// prefixes already in use are ignored. LyricLine.RawSpans are written
+	// verbatim and keep their source prefixes, so when any line has them the
+	// default declarations are kept alongside the new ones. Ignored with
+	// CompatTS.
+	NamespacePrefixes map[string]string
+	// LineDur writes the timing of <p> elements as begin and dur instead of
+	// begin and end, matching sources that time paragraphs that way. Word
+	// spans keep begin and end. Ignored with CompatTS.
+	LineDur bool
+}
+
+// defaultPrefixes are the prefixes the writer declares, by namespace URI.
+var defaultPrefixes = map[string]string{
+	nsTTM:    "ttm",
+	nsAMLL:   "amll",
+	nsItunes: "itunes",
+	nsTTP:    "ttp",
+}
+
+// defaultTranslationLanguage is the xml:lang used for translations when the
+// lyric carries no MetaKeyTranslationLanguage entry.
+const defaultTranslationLanguage = "zh-CN"
+
 // ExportTTMLText converts a TTMLLyric into TTML XML text.
 // The output mirrors the TS writer behavior.
 func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
+	return ExportTTMLTextWithOptions(ttmlLyric, ExportOptions{Pretty: pretty})
+}
+
+// ExportTTMLTextWithOptions converts a TTMLLyric into TTML XML text using opts.
+func ExportTTMLTextWithOptions(ttmlLyric TTMLLyric, opts ExportOptions) string {
+	return serializeDocument(buildTTMLDocument(ttmlLyric, opts, newParagraphContext(ttmlLyric.LyricLines, opts)), opts.Pretty)
+}
+
+// ReExport is ExportTTMLTextWithOptions with opts.Pretty taken from the
+// lyric's WasPretty hint, so re-exporting a parsed file keeps its layout and
+// diffs show only the edited values.
+func ReExport(ttmlLyric TTMLLyric, opts ExportOptions) string {
+	opts.Pretty = ttmlLyric.WasPretty
+	return ExportTTMLTextWithOptions(ttmlLyric, opts)
+}
+
+// ExportTTML writes the TTML for ttmlLyric to w, serializing the document
+// tree straight to the writer instead of building the whole text in memory.
+// The output is identical to ExportTTMLTextWithOptions.
+func ExportTTML(w io.Writer, ttmlLyric TTMLLyric, opts ExportOptions) error {
+	bw := bufio.NewWriter(w)
+	serializeNode(bw, buildTTMLDocument(ttmlLyric, opts, newParagraphContext(ttmlLyric.LyricLines, opts)), opts.Pretty, 0)
+	// bufio.Writer keeps the first write error, so Flush reports it.
+	return bw.Flush()
+}
+
+// ExportParagraph renders only the <p> of the main line at lineIdx together
+// with the background lines attached to it, byte for byte as that paragraph
+// appears in ExportTTMLTextWithOptions(ttmlLyric, opts). Editors can use it
+// to re-serialize one edited line and splice it into the previous output
+// instead of exporting the whole document again.
+//
+// The lyric-wide inputs of a paragraph (the word-timed/line-timed choice and
+// its itunes:key) are derived from ttmlLyric, so an edit that changes them,
+// such as turning the only multi-word line into a single word, still needs a
+// full export. Data written to <head>, like ITunesTranslations, is not
+// included. lineIdx must refer to a line that starts a paragraph: background
+// lines following another line and the word-less lines that separate <div>s
+// are rejected.
+func ExportParagraph(ttmlLyric TTMLLyric, lineIdx int, opts ExportOptions) (string, error) {
+	lines := ttmlLyric.LyricLines
+	if lineIdx < 0 || lineIdx >= len(lines) {
+		return "", fmt.Errorf("line index %d out of range [0, %d)", lineIdx, len(lines))
+	}
+
+	ctx := newParagraphContext(lines, opts)
+	var ok bool
+	ctx.keyOffset, ok = slices.BinarySearch(ctx.starts, lineIdx)
+	switch {
+	case ok:
+	case len(lines[lineIdx].Words) == 0:
+		return "", fmt.Errorf("line %d has no words and only separates divs", lineIdx)
+	default:
+		return "", fmt.Errorf("line %d is a background line; export its main line instead", lineIdx)
+	}
+	end := lineIdx + 1
+	for end < len(lines) && lines[end].IsBG && len(lines[end].Words) > 0 {
+		end++
+	}
+
+	fragment := ttmlLyric
+	fragment.LyricLines = lines[lineIdx:end]
+	doc := buildTTMLDocument(fragment, opts, ctx)
+
+	// The paragraphs sit at tt > body > div > p; serializing them at that
+	// depth and with the separators the document uses between siblings keeps
+	// the result a substring of the full export.
+	separator := ""
+	if opts.Pretty {
+		separator = "\n" + strings.Repeat("  ", paragraphDepth)
+	} else if opts.LineBreaks {
+		separator = "\n"
+	}
+	var sb strings.Builder
+	for i, p := range findDescendantElements(doc, func(n *xmlNode) bool { return n.Local == "p" }) {
+		if i > 0 {
+			sb.WriteString(separator)
+		}
+		serializeNode(&sb, p, opts.Pretty, paragraphDepth)
+	}
+	return sb.String(), nil
+}
+
+// paragraphDepth is the nesting depth of <p> under the document root.
+const paragraphDepth = 3
+
+// paragraphContext holds the lyric-wide facts that shape each paragraph, so
+// a single paragraph can be rendered exactly as in the full document.
+type paragraphContext struct {
+	// dynamic is set when some line has several non-blank words; every line
+	// then writes its words as timed spans.
+	dynamic bool
+	// untimed is set for plain-text lyrics, whose body carries no timing.
+	untimed bool
+	// starts lists the indices of the lines that begin a <p>, and keys the
+	// itunes:key of each of those paragraphs.
+	starts []int
+	keys   []string
+	// keyOffset is the number of paragraphs before the first one written.
+	keyOffset int
+}
+
+func newParagraphContext(lines []LyricLine, opts ExportOptions) paragraphContext {
+	ctx := paragraphContext{starts: paragraphStarts(lines)}
+	ctx.keys = paragraphKeys(lines, ctx.starts, !opts.CompatTS)
+	hasWords := false
+	for _, line := range lines {
+		count := 0
+		for _, word := range line.Words {
+			if !word.IsBlank() {
+				count++
+			}
+		}
+		hasWords = hasWords || count > 0
+		ctx.dynamic = ctx.dynamic || count > 1
+	}
+	ctx.untimed = hasWords && isUntimedLyric(lines)
+	return ctx
+}
+
+// paragraphStarts replays the writer's grouping and returns the indices of
+// the lines that begin a paragraph: a wordless line closes the current div,
+// and a background line joins the paragraph before it in the same div.
+func paragraphStarts(lines []LyricLine) []int {
+	var starts []int
+	divStart := true
+	for idx, line := range lines {
+		if len(line.Words) == 0 && !divStart {
+			divStart = true
+			continue
+		}
+		if divStart || !line.IsBG {
+			starts = append(starts, idx)
+		}
+		divStart = false
+	}
+	return starts
+}
+
+// paragraphKeys assigns the itunes:key of every paragraph. With preserve
+// set, a paragraph keeps its main line's ITunesKey unless an earlier one
+// already took it; the others get the first unused "L<n>" in order, so
+// lyrics without preserved keys are numbered L1, L2, ... as before.
+func paragraphKeys(lines []LyricLine, starts []int, preserve bool) []string {
+	keys := make([]string, len(starts))
+	used := map[string]bool{}
+	if preserve {
+		for k, idx := range starts {
+			if key := lines[idx].ITunesKey; key != "" && !used[key] {
+				keys[k] = key
+				used[key] = true
+			}
+		}
+	}
+	n := 0
+	for k := range keys {
+		for keys[k] == "" {
+			n++
+			if key := "L" + strconv.Itoa(n); !used[key] {
+				keys[k] = key
+				used[key] = true
+			}
+		}
+	}
+	return keys
+}
+
+// buildTTMLDocument renders ttmlLyric into an XML node tree, shaping the
+// paragraphs by ctx.
+func buildTTMLDocument(ttmlLyric TTMLLyric, opts ExportOptions, ctx paragraphContext) *xmlNode {
+	if opts.CompatTS {
+		opts.BGParenMode = BGParenInWord
+		opts.ITunesTranslations = false
+	}
+	if opts.OmitMetadata {
+		opts.OmitAgents = true
+	}
 	params := make([][]LyricLine, 0)
 	lyric := ttmlLyric.LyricLines
 
@@ -31,12 +289,21 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 		span := newElement("span")
 		span.setAttr("begin", MsToTimestamp(word.StartTime))
 		span.setAttr("end", MsToTimestamp(word.EndTime))
+		if word.XMLID != "" && !opts.CompatTS {
+			span.setAttr("xml:id", word.XMLID)
+		}
 		if word.Obscene {
 			span.setAttr("amll:obscene", "true")
 		}
 		if word.EmptyBeat != 0 && !math.IsNaN(word.EmptyBeat) {
 			span.setAttr("amll:empty-beat", formatNumber(word.EmptyBeat))
 		}
+		if word.Phoneme != "" && !opts.CompatTS {
+			span.setAttr("amll:phoneme", word.Phoneme)
+		}
+		if word.Background && !opts.CompatTS {
+			span.setAttr("amll:bg", "true")
+		}
 		span.appendChild(newText(word.Word))
 		return span
 	}
@@ -61,7 +328,7 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 	for _, line := range lyric {
 		count := 0
 		for _, word := range line.Words {
-			if strings.TrimSpace(word.Word) != "" {
+			if !word.IsBlank() {
 				count++
 				if word.EndTime > word.StartTime {
 					hasAnyTiming = true
@@ -83,6 +350,9 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 		}
 	}
 	ttRoot.setAttr("itunes:timing", timingMode)
+	if !opts.CompatTS {
+		writeDocumentProfile(ttRoot, ttmlLyric.DocumentProfile)
+	}
 
 	doc.appendChild(ttRoot)
 
@@ -100,12 +370,14 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 	}
 
 	metadataEl := newElement("metadata")
-	mainPersonAgent := newElement("ttm:agent")
-	mainPersonAgent.setAttr("type", "person")
-	mainPersonAgent.setAttr("xml:id", "v1")
-	metadataEl.appendChild(mainPersonAgent)
+	if !opts.OmitAgents {
+		mainPersonAgent := newElement("ttm:agent")
+		mainPersonAgent.setAttr("type", "person")
+		mainPersonAgent.setAttr("xml:id", "v1")
+		metadataEl.appendChild(mainPersonAgent)
+	}
 
-	if hasOtherPerson {
+	if hasOtherPerson && !opts.OmitAgents {
 		otherPersonAgent := newElement("ttm:agent")
 		otherPersonAgent.setAttr("type", "other")
 		otherPersonAgent.setAttr("xml:id", "v2")
@@ -116,7 +388,7 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 	var songwriterMeta *TTMLMetadata
 	for i := range ttmlLyric.Metadata {
 		meta := &ttmlLyric.Metadata[i]
-		if meta.Key == "songwriter" {
+		if meta.Key == MetaKeySongwriter {
 			for _, v := range meta.Value {
 				if strings.TrimSpace(v) != "" {
 					songwriterMeta = meta
@@ -145,9 +417,20 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 		}
 	}
 
+	translationLanguage := defaultTranslationLanguage
+	for _, meta := range ttmlLyric.Metadata {
+		if opts.CompatTS {
+			break
+		}
+		if meta.Key == MetaKeyTranslationLanguage && len(meta.Value) > 0 && strings.TrimSpace(meta.Value[0]) != "" {
+			translationLanguage = strings.TrimSpace(meta.Value[0])
+			break
+		}
+	}
+
 	// Remaining metadata (AMLL format)
 	for _, meta := range ttmlLyric.Metadata {
-		if meta.Key == "songwriter" {
+		if meta.Key == MetaKeySongwriter || (meta.Key == MetaKeyTranslationLanguage && !opts.CompatTS) {
 			continue
 		}
 		for _, value := range meta.Value {
@@ -158,15 +441,49 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 		}
 	}
 
-	head.appendChild(metadataEl)
+	if !opts.OmitMetadata {
+		head.appendChild(metadataEl)
+	}
+
+	// createTranslationSpan writes the inline x-translation span, as timed
+	// spans when the line carries TranslatedWords.
+	createTranslationSpan := func(line LyricLine) *xmlNode {
+		span := newElement("span")
+		span.setAttr("ttm:role", "x-translation")
+		span.setAttr("xml:lang", translationLanguage)
+		if len(line.TranslatedWords) == 0 || opts.CompatTS {
+			text := line.TranslatedLyric
+			if text == "" {
+				text, _, _ = joinLineWords(line.TranslatedWords)
+			}
+			span.appendChild(newText(text))
+			return span
+		}
+		for _, word := range line.TranslatedWords {
+			if word.IsBlank() {
+				span.appendChild(newText(word.Word))
+			} else {
+				span.appendChild(createWordElement(word))
+			}
+		}
+		return span
+	}
 
-	i := 0
+	i := ctx.keyOffset
+	// The bg fields hold one item per background line of the entry's main
+	// line, in order; each is written as its own x-bg span.
 	type romanizationEntry struct {
 		key  string
 		main []LyricWord
-		bg   []LyricWord
+		bg   [][]LyricWord
 	}
 	var romanizationEntries []romanizationEntry
+	type translationEntry struct {
+		key  string
+		main string
+		bg   []string
+	}
+	var translationEntries []translationEntry
 
 	guessDuration := float64(0)
 	if len(lyric) > 0 {
@@ -174,19 +491,7 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 	}
 	body.setAttr("dur", MsToTimestamp(guessDuration))
 
-	isDynamicLyric := false
-	for _, line := range lyric {
-		count := 0
-		for _, word := range line.Words {
-			if strings.TrimSpace(word.Word) != "" {
-				count++
-			}
-		}
-		if count > 1 {
-			isDynamicLyric = true
-			break
-		}
-	}
+	isDynamicLyric := ctx.dynamic
 
 	for _, param := range params {
 		paramDiv := newElement("div")
@@ -207,22 +512,33 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 
 			lineP.setAttr("begin", MsToTimestamp(beginTime))
 			lineP.setAttr("end", MsToTimestamp(endTime))
-			if line.IsDuet {
-				lineP.setAttr("ttm:agent", "v2")
-			} else {
-				lineP.setAttr("ttm:agent", "v1")
+			if !opts.OmitAgents {
+				agent := "v1"
+				if line.IsDuet {
+					agent = "v2"
+				}
+				lineP.setAttr("ttm:agent", agent)
 			}
 
+			itunesKey := ctx.keys[i]
 			i++
-			itunesKey := "L" + strconv.Itoa(i)
 			lineP.setAttr("itunes:key", itunesKey)
+			if line.XMLID != "" && !opts.CompatTS {
+				lineP.setAttr("xml:id", line.XMLID)
+			}
+			if role := lineRoleAttr(line.Role); role != "" && !opts.CompatTS {
+				lineP.setAttr("ttm:role", role)
+			}
 
 			mainWords := line.Words
-			var bgWords []LyricWord
+			var bgWords [][]LyricWord
+			if isDynamicLyric && needsPreservedSpace(line.Words) {
+				lineP.setAttr("xml:space", "preserve")
+			}
 
 			if isDynamicLyric {
 				for _, word := range line.Words {
-					if strings.TrimSpace(word.Word) == "" {
+					if word.IsBlank() {
 						lineP.appendChild(newText(word.Word))
 					} else {
 						lineP.appendChild(createWordElement(word))
@@ -230,25 +546,34 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 				}
 				lineP.setAttr("begin", MsToTimestamp(line.StartTime))
 				lineP.setAttr("end", MsToTimestamp(line.EndTime))
-			} else {
+			} else if len(line.Words) > 0 && opts.CompatTS {
 				word := line.Words[0]
 				lineP.appendChild(newText(word.Word))
 				lineP.setAttr("begin", MsToTimestamp(word.StartTime))
 				lineP.setAttr("end", MsToTimestamp(word.EndTime))
+			} else if len(line.Words) > 0 {
+				text, start, end := joinLineWords(line.Words)
+				lineP.appendChild(newText(text))
+				lineP.setAttr("begin", MsToTimestamp(start))
+				lineP.setAttr("end", MsToTimestamp(end))
 			}
 
-			var nextLine *LyricLine
-			if lineIndex+1 < len(param) {
-				nextLine = &param[lineIndex+1]
-			}
-
-			if nextLine != nil && nextLine.IsBG {
+			var bgParagraphs []*xmlNode
+			var bgTranslations []string
+			// Every background line directly following the main line becomes
+			// an x-bg span of it, in order, so the parser restores the same
+			// sequence. The iTunes blocks keep that order too.
+			for lineIndex+1 < len(param) && param[lineIndex+1].IsBG {
 				lineIndex++
-				bgLine := *nextLine
-				bgWords = bgLine.Words
+				bgLine := param[lineIndex]
+				bgWords = append(bgWords, bgLine.Words)
+				bgTranslations = append(bgTranslations, bgLine.TranslatedLyric)
 
 				bgLineSpan := newElement("span")
 				bgLineSpan.setAttr("ttm:role", "x-bg")
+				if bgLine.XMLID != "" && !opts.CompatTS {
+					bgLineSpan.setAttr("xml:id", bgLine.XMLID)
+				}
 
 				if isDynamicLyric {
 					beginTime := math.Inf(1)
@@ -257,7 +582,7 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 					firstWordIndex := -1
 					lastWordIndex := -1
 					for idx, word := range bgLine.Words {
-						if strings.TrimSpace(word.Word) != "" {
+						if !word.IsBlank() {
 							if firstWordIndex == -1 {
 								firstWordIndex = idx
 							}
@@ -265,37 +590,54 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 						}
 					}
 
+					if opts.BGParenMode == BGParenStandalone {
+						bgLineSpan.appendChild(newText("("))
+					}
 					for wordIndex, word := range bgLine.Words {
-						if strings.TrimSpace(word.Word) == "" {
+						if word.IsBlank() {
 							bgLineSpan.appendChild(newText(word.Word))
 						} else {
 							span := createWordElement(word)
-							if wordIndex == firstWordIndex && len(span.Children) > 0 && span.Children[0].Type == nodeText {
-								span.Children[0].Text = "(" + span.Children[0].Text
-							}
-							if wordIndex == lastWordIndex && len(span.Children) > 0 && span.Children[0].Type == nodeText {
-								span.Children[0].Text = span.Children[0].Text + ")"
+							if opts.BGParenMode == BGParenInWord {
+								if wordIndex == firstWordIndex {
+									prependSpanText(span, "(")
+								}
+								if wordIndex == lastWordIndex {
+									appendSpanText(span, ")")
+								}
 							}
 							bgLineSpan.appendChild(span)
 							beginTime = math.Min(beginTime, word.StartTime)
 							endTime = math.Max(endTime, word.EndTime)
 						}
 					}
+					if opts.BGParenMode == BGParenStandalone {
+						bgLineSpan.appendChild(newText(")"))
+					}
+					if firstWordIndex == -1 {
+						// No timed words: fall back to the line timing instead of +Inf.
+						beginTime = bgLine.StartTime
+						endTime = bgLine.EndTime
+					}
 					bgLineSpan.setAttr("begin", MsToTimestamp(beginTime))
 					bgLineSpan.setAttr("end", MsToTimestamp(endTime))
-				} else {
+				} else if len(bgLine.Words) > 0 && opts.CompatTS {
 					word := bgLine.Words[0]
 					bgLineSpan.appendChild(newText("(" + word.Word + ")"))
 					bgLineSpan.setAttr("begin", MsToTimestamp(word.StartTime))
 					bgLineSpan.setAttr("end", MsToTimestamp(word.EndTime))
+				} else if len(bgLine.Words) > 0 {
+					text, start, end := joinLineWords(bgLine.Words)
+					bgLineSpan.appendChild(newText("(" + text + ")"))
+					bgLineSpan.setAttr("begin", MsToTimestamp(start))
+					bgLineSpan.setAttr("end", MsToTimestamp(end))
+				} else {
+					bgLineSpan.setAttr("begin", MsToTimestamp(bgLine.StartTime))
+					bgLineSpan.setAttr("end", MsToTimestamp(bgLine.EndTime))
 				}
 
-				if bgLine.TranslatedLyric != "" {
-					span := newElement("span")
-					span.setAttr("ttm:role", "x-translation")
-					span.setAttr("xml:lang", "zh-CN")
-					span.appendChild(newText(bgLine.TranslatedLyric))
-					bgLineSpan.appendChild(span)
+				if (bgLine.TranslatedLyric != "" || len(bgLine.TranslatedWords) > 0) && !opts.ITunesTranslations {
+					bgLineSpan.appendChild(createTranslationSpan(bgLine))
 				}
 
 				if bgLine.RomanLyric != "" {
@@ -304,16 +646,38 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 					span.appendChild(newText(bgLine.RomanLyric))
 					bgLineSpan.appendChild(span)
 				}
+				if !opts.CompatTS {
+					appendRawSpans(bgLineSpan, bgLine.RawSpans)
+				}
+
+				if isDynamicLyric && needsPreservedSpace(bgLine.Words) {
+					bgLineSpan.setAttr("xml:space", "preserve")
+				}
 
-				lineP.appendChild(bgLineSpan)
+				if opts.BackgroundAsSiblingParagraph && !opts.CompatTS {
+					// The parser attaches a sibling x-bg paragraph to the
+					// preceding main line, so it shares the main line's key.
+					bgLineSpan.Name, bgLineSpan.Local = "p", "p"
+					if agent, ok := lineP.attrValue("ttm:agent"); ok {
+						bgLineSpan.setAttr("ttm:agent", agent)
+					}
+					bgLineSpan.setAttr("itunes:key", itunesKey)
+					bgParagraphs = append(bgParagraphs, bgLineSpan)
+				} else {
+					lineP.appendChild(bgLineSpan)
+				}
 			}
 
-			if line.TranslatedLyric != "" {
-				span := newElement("span")
-				span.setAttr("ttm:role", "x-translation")
-				span.setAttr("xml:lang", "zh-CN")
-				span.appendChild(newText(line.TranslatedLyric))
-				lineP.appendChild(span)
+			if opts.ITunesTranslations {
+				for len(bgTranslations) > 0 && bgTranslations[len(bgTranslations)-1] == "" {
+					bgTranslations = bgTranslations[:len(bgTranslations)-1]
+				}
+				entry := translationEntry{key: itunesKey, main: line.TranslatedLyric, bg: bgTranslations}
+				if entry.main != "" || len(entry.bg) > 0 {
+					translationEntries = append(translationEntries, entry)
+				}
+			} else if line.TranslatedLyric != "" || len(line.TranslatedWords) > 0 {
+				lineP.appendChild(createTranslationSpan(line))
 			}
 
 			if line.RomanLyric != "" {
@@ -322,24 +686,11 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 				span.appendChild(newText(line.RomanLyric))
 				lineP.appendChild(span)
 			}
-
-			hasRoman := false
-			for _, word := range mainWords {
-				if strings.TrimSpace(word.RomanWord) != "" {
-					hasRoman = true
-					break
-				}
-			}
-			if !hasRoman {
-				for _, word := range bgWords {
-					if strings.TrimSpace(word.RomanWord) != "" {
-						hasRoman = true
-						break
-					}
-				}
+			if !opts.CompatTS {
+				appendRawSpans(lineP, line.RawSpans)
 			}
 
-			if hasRoman {
+			if hasRomanWord(mainWords) || slices.ContainsFunc(bgWords, hasRomanWord) {
 				romanizationEntries = append(romanizationEntries, romanizationEntry{
 					key:  itunesKey,
 					main: mainWords,
@@ -348,15 +699,46 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 			}
 
 			paramDiv.appendChild(lineP)
+			for _, bgParagraph := range bgParagraphs {
+				paramDiv.appendChild(bgParagraph)
+			}
 		}
 
 		body.appendChild(paramDiv)
 	}
 
-	if len(romanizationEntries) > 0 {
-		itunesMeta := newElement("iTunesMetadata")
+	var itunesMeta *xmlNode
+	if len(translationEntries) > 0 || len(romanizationEntries) > 0 {
+		itunesMeta = newElement("iTunesMetadata")
 		itunesMeta.setAttr("xmlns", nsItunes)
+	}
+
+	if len(translationEntries) > 0 {
+		translations := newElement("translations")
+		translation := newElement("translation")
+		translation.setAttr("type", "subtitle")
+		translation.setAttr("xml:lang", translationLanguage)
+		for _, entry := range translationEntries {
+			textEl := newElement("text")
+			textEl.setAttr("for", entry.key)
+			if entry.main != "" {
+				textEl.appendChild(newText(entry.main))
+			}
+			for _, bg := range entry.bg {
+				bgSpan := newElement("span")
+				bgSpan.setAttr("ttm:role", "x-bg")
+				if bg != "" {
+					bgSpan.appendChild(newText("(" + bg + ")"))
+				}
+				textEl.appendChild(bgSpan)
+			}
+			translation.appendChild(textEl)
+		}
+		translations.appendChild(translation)
+		itunesMeta.appendChild(translations)
+	}
 
+	if len(romanizationEntries) > 0 {
 		transliterations := newElement("transliterations")
 		transliteration := newElement("transliteration")
 
@@ -364,7 +746,7 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 			key := entry.key
 			words := struct {
 				main []LyricWord
-				bg   []LyricWord
+				bg   [][]LyricWord
 			}{main: entry.main, bg: entry.bg}
 			textEl := newElement("text")
 			textEl.setAttr("for", key)
@@ -372,19 +754,20 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 			for _, word := range words.main {
 				if strings.TrimSpace(word.RomanWord) != "" {
 					textEl.appendChild(createRomanizationSpan(word))
-				} else if strings.TrimSpace(word.Word) == "" && len(textEl.Children) > 0 {
+				} else if word.IsBlank() && len(textEl.Children) > 0 {
 					textEl.appendChild(newText(word.Word))
 				}
 			}
 
-			hasBgRoman := false
-			for _, word := range words.bg {
-				if strings.TrimSpace(word.RomanWord) != "" {
-					hasBgRoman = true
-					break
+			// Background lines without romanization still get an empty span
+			// when a later one has some, so spans pair up with lines by order.
+			lastBg := -1
+			for bgIndex, bgWords := range words.bg {
+				if hasRomanWord(bgWords) {
+					lastBg = bgIndex
 				}
 			}
-			if hasBgRoman {
+			for _, bgWords := range words.bg[:lastBg+1] {
 				bgSpan := newElement("span")
 				bgSpan.setAttr("ttm:role", "x-bg")
 
@@ -393,7 +776,7 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 					index int
 				}
 				var romanBgWords []indexedWord
-				for idx, word := range words.bg {
+				for idx, word := range bgWords {
 					if strings.TrimSpace(word.RomanWord) != "" {
 						romanBgWords = append(romanBgWords, indexedWord{word: word, index: idx})
 					}
@@ -409,9 +792,9 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 					}
 					bgSpan.appendChild(span)
 
-					if iw.index > -1 && iw.index < len(words.bg)-1 {
-						nextWord := words.bg[iw.index+1]
-						if strings.TrimSpace(nextWord.Word) == "" {
+					if iw.index > -1 && iw.index < len(bgWords)-1 {
+						nextWord := bgWords[iw.index+1]
+						if nextWord.IsBlank() {
 							bgSpan.appendChild(newText(nextWord.Word))
 						}
 					}
@@ -425,12 +808,319 @@ func ExportTTMLText(ttmlLyric TTMLLyric, pretty bool) string {
 
 		transliterations.appendChild(transliteration)
 		itunesMeta.appendChild(transliterations)
+	}
+
+	if itunesMeta != nil {
 		metadataEl.appendChild(itunesMeta)
 	}
 
+	if !opts.CompatTS && ctx.untimed {
+		stripTiming(body)
+	}
+
 	ttRoot.appendChild(body)
 
-	return serializeDocument(doc, pretty)
+	if opts.LineBreaks && !opts.Pretty {
+		breakLines(ttRoot)
+	}
+	if opts.LineDur && !opts.CompatTS {
+		for _, p := range findDescendantElements(body, func(n *xmlNode) bool { return n.Name == "p" }) {
+			endToDur(p)
+		}
+	}
+	if len(opts.NamespacePrefixes) > 0 && !opts.CompatTS {
+		renames := prefixRenames(opts.NamespacePrefixes)
+		renamePrefixes(ttRoot, renames)
+		// Raw spans are verbatim markup that renamePrefixes cannot reach, so
+		// keep the default declarations they may use next to the new ones.
+		if hasRawSpans(ttmlLyric.LyricLines) {
+			for _, old := range slices.Sorted(maps.Keys(renames)) {
+				if uri, ok := ttRoot.attrValue("xmlns:" + renames[old]); ok {
+					ttRoot.setAttr("xmlns:"+old, uri)
+				}
+			}
+		}
+	}
+
+	return doc
+}
+
+// breakLines puts a newline before each child of node and before its closing
+// tag, descending into <body> and <div> so every paragraph gets its own line.
+// Whitespace between these elements is insignificant to TTML parsers.
+func breakLines(node *xmlNode) {
+	children := node.Children
+	node.Children = nil
+	for _, child := range children {
+		node.appendChild(newText("\n"))
+		node.appendChild(child)
+		if child.Type == nodeElement && (child.Local == "body" || child.Local == "div") {
+			breakLines(child)
+		}
+	}
+	node.appendChild(newText("\n"))
+}
+
+// isUntimedLyric reports whether every line and word timing is zero, i.e. the
+// lyric is plain text.
+func isUntimedLyric(lines []LyricLine) bool {
+	for _, line := range lines {
+		if line.StartTime != 0 || line.EndTime != 0 {
+			return false
+		}
+		for _, word := range line.Words {
+			if word.StartTime != 0 || word.EndTime != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// stripTiming drops begin/end/dur from el and its descendants and unwraps
+// word spans (spans without ttm:role) into plain text, producing the body of
+// an itunes:timing="None" document.
+func stripTiming(el *xmlNode) {
+	attrs := el.Attrs[:0]
+	for _, attr := range el.Attrs {
+		if attr.Name != "begin" && attr.Name != "end" && attr.Name != "dur" {
+			attrs = append(attrs, attr)
+		}
+	}
+	el.Attrs = attrs
+
+	children := make([]*xmlNode, 0, len(el.Children))
+	for _, child := range el.Children {
+		if child.Type != nodeElement {
+			children = append(children, child)
+			continue
+		}
+		stripTiming(child)
+		if _, hasRole := child.attrValue("ttm:role"); child.Local == "span" && !hasRole {
+			for _, grandchild := range child.Children {
+				grandchild.Parent = el
+				children = append(children, grandchild)
+			}
+			continue
+		}
+		children = append(children, child)
+	}
+	el.Children = children
+}
+
+// endToDur replaces the end attribute of node with the equivalent dur,
+// keeping its position among the attributes.
+func endToDur(node *xmlNode) {
+	beginStr, ok := node.attrValue("begin")
+	if !ok {
+		return
+	}
+	for i, attr := range node.Attrs {
+		if attr.Name != "end" {
+			continue
+		}
+		begin, err := ParseTimespan(beginStr)
+		if err != nil {
+			return
+		}
+		end, err := ParseTimespan(attr.Value)
+		if err != nil {
+			return
+		}
+		node.Attrs[i] = xmlAttr{Name: "dur", Local: "dur", Value: MsToTimestamp(math.Max(end-begin, 0))}
+		return
+	}
+}
+
+// prefixRenames maps default prefixes to the prefixes bindings chooses for
+// their namespaces. When several prefixes bind one URI the smallest wins.
+func prefixRenames(bindings map[string]string) map[string]string {
+	prefixes := make([]string, 0, len(bindings))
+	for prefix := range bindings {
+		prefixes = append(prefixes, prefix)
+	}
+	slices.Sort(prefixes)
+
+	renames := map[string]string{}
+	used := map[string]bool{"xml": true, "xmlns": true}
+	for _, prefix := range defaultPrefixes {
+		used[prefix] = true
+	}
+	for _, prefix := range prefixes {
+		old, ok := defaultPrefixes[bindings[prefix]]
+		if !ok || renames[old] != "" || used[prefix] || !isXMLPrefix(prefix) {
+			continue
+		}
+		renames[old] = prefix
+		used[prefix] = true
+	}
+	return renames
+}
+
+// isXMLPrefix reports whether prefix is a plain NCName that does not start
+// with the reserved "xml".
+func isXMLPrefix(prefix string) bool {
+	if prefix == "" || strings.HasPrefix(strings.ToLower(prefix), "xml") {
+		return false
+	}
+	for i, r := range prefix {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// renamePrefixes rewrites qualified element and attribute names, and the
+// matching xmlns: declarations, below node according to renames.
+func renamePrefixes(node *xmlNode, renames map[string]string) {
+	if len(renames) == 0 {
+		return
+	}
+	rename := func(name string) string {
+		prefix, local, ok := strings.Cut(name, ":")
+		if !ok {
+			return name
+		}
+		if prefix == "xmlns" {
+			if to, ok := renames[local]; ok {
+				return "xmlns:" + to
+			}
+			return name
+		}
+		if to, ok := renames[prefix]; ok {
+			return to + ":" + local
+		}
+		return name
+	}
+	if node.Type == nodeElement {
+		node.Name = rename(node.Name)
+		for i := range node.Attrs {
+			node.Attrs[i].Name = rename(node.Attrs[i].Name)
+		}
+	}
+	for _, child := range node.Children {
+		renamePrefixes(child, renames)
+	}
+}
+
+// writeDocumentProfile emits the non-empty DocumentProfile fields on the
+// <tt> root, declaring the ttp namespace only when a ttp attribute is used.
+func writeDocumentProfile(ttRoot *xmlNode, profile DocumentProfile) {
+	if profile.Profile != "" || profile.CellResolution != "" || profile.FrameRate != "" {
+		ttRoot.setAttr("xmlns:ttp", nsTTP)
+	}
+	if profile.Lang != "" {
+		ttRoot.setAttr("xml:lang", profile.Lang)
+	}
+	if profile.Profile != "" {
+		ttRoot.setAttr("ttp:profile", profile.Profile)
+	}
+	if profile.CellResolution != "" {
+		ttRoot.setAttr("ttp:cellResolution", profile.CellResolution)
+	}
+	if profile.FrameRate != "" {
+		ttRoot.setAttr("ttp:frameRate", profile.FrameRate)
+	}
+}
+
+// appendRawSpans appends each of spans to node as verbatim markup.
+func appendRawSpans(node *xmlNode, spans []string) {
+	for _, span := range spans {
+		node.appendChild(&xmlNode{Type: nodeRaw, Text: span})
+	}
+}
+
+// hasRawSpans reports whether any of lines carries RawSpans.
+func hasRawSpans(lines []LyricLine) bool {
+	for _, line := range lines {
+		if len(line.RawSpans) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// lineRoleAttr returns the <p> ttm:role for a lead-line role. Background is
+// expressed through the x-bg span instead.
+func lineRoleAttr(role LineRole) string {
+	switch role {
+	case LineRoleHarmony:
+		return "x-harmony"
+	case LineRoleAdLib:
+		return "x-adlib"
+	default:
+		return ""
+	}
+}
+
+// joinLineWords concatenates every token of a line-timed line so no text is
+// dropped, taking the timing from its non-blank words (or the first token
+// when all of them are blank).
+func joinLineWords(words []LyricWord) (string, float64, float64) {
+	var sb strings.Builder
+	start := math.Inf(1)
+	end := float64(0)
+	for _, word := range words {
+		sb.WriteString(word.Word)
+		if word.IsBlank() {
+			continue
+		}
+		start = math.Min(start, word.StartTime)
+		end = math.Max(end, word.EndTime)
+	}
+	if math.IsInf(start, 1) {
+		start = words[0].StartTime
+		end = words[0].EndTime
+	}
+	return sb.String(), start, end
+}
+
+// hasRomanWord reports whether any of words carries a romanization.
+func hasRomanWord(words []LyricWord) bool {
+	for _, word := range words {
+		if strings.TrimSpace(word.RomanWord) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// needsPreservedSpace reports whether words has a whitespace token other than
+// a single space. TTML's default whitespace handling would collapse such
+// tokens, so the paragraph is marked xml:space="preserve".
+func needsPreservedSpace(words []LyricWord) bool {
+	for _, word := range words {
+		if word.IsBlank() && word.Word != " " && word.Word != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// prependSpanText prefixes the span's leading text node, creating one when
+// the span has no text child so the prefix is never dropped.
+func prependSpanText(span *xmlNode, prefix string) {
+	if len(span.Children) > 0 && span.Children[0].Type == nodeText {
+		span.Children[0].Text = prefix + span.Children[0].Text
+		return
+	}
+	text := newText(prefix)
+	text.Parent = span
+	span.Children = append([]*xmlNode{text}, span.Children...)
+}
+
+// appendSpanText suffixes the span's trailing text node, creating one when
+// the span has no text child so the suffix is never dropped.
+func appendSpanText(span *xmlNode, suffix string) {
+	if n := len(span.Children); n > 0 && span.Children[n-1].Type == nodeText {
+		span.Children[n-1].Text += suffix
+		return
+	}
+	span.appendChild(newText(suffix))
 }
 
 func serializeDocument(doc *xmlNode, pretty bool) string {
@@ -12,10 +12,49 @@ type TTMLMetadata struct {
 	Error bool
 }
 
+// Well-known metadata keys used by AMLL tooling.
+const (
+	MetaKeyMusicName             = "musicName"
+	MetaKeyArtists               = "artists"
+	MetaKeyAlbum                 = "album"
+	MetaKeySongwriter            = "songwriter"
+	MetaKeyISRC                  = "isrc"
+	MetaKeyNcmMusicID            = "ncmMusicId"
+	MetaKeyQQMusicID             = "qqMusicId"
+	MetaKeySpotifyID             = "spotifyId"
+	MetaKeyAppleMusicID          = "appleMusicId"
+	MetaKeyTTMLAuthorGithub      = "ttmlAuthorGithub"
+	MetaKeyTTMLAuthorGithubLogin = "ttmlAuthorGithubLogin"
+	// MetaKeyTranslationLanguage holds the xml:lang of the line translations.
+	// The TTML writer expresses it through xml:lang instead of amll:meta.
+	MetaKeyTranslationLanguage = "translationLanguage"
+)
+
+// DocumentProfile holds presentation attributes of the <tt> root that some
+// players depend on. Empty fields are not written.
+type DocumentProfile struct {
+	// Lang is the document xml:lang.
+	Lang string
+	// Profile is ttp:profile.
+	Profile string
+	// CellResolution is ttp:cellResolution, e.g. "40 24".
+	CellResolution string
+	// FrameRate is ttp:frameRate.
+	FrameRate string
+}
+
 // TTMLLyric is the container for parsed lyrics and metadata.
 type TTMLLyric struct {
-	Metadata   []TTMLMetadata
+	Metadata []TTMLMetadata
+	// LyricLines is in document order, each main line directly followed by
+	// its background lines. The writer keeps that order and AMLX stores lines
+	// as given, so line positions survive TTML and AMLX round trips.
 	LyricLines []LyricLine
+	// DocumentProfile is carried through TTML only; AMLX does not store it.
+	DocumentProfile DocumentProfile
+	// WasPretty records that the parsed TTML was indented, so ReExport can
+	// keep the original layout. Set by the parser only.
+	WasPretty bool
 }
 
 // LyricWord represents a single word (or whitespace token) in a lyric line.
@@ -29,6 +68,49 @@ type LyricWord struct {
 	EmptyBeat    float64
 	RomanWord    string
 	RomanWarning bool
+	// Phoneme carries an optional pronunciation (IPA, toned pinyin, ...).
+	Phoneme string
+	// XMLID is the source span's xml:id, kept when ParseOptions.PreserveXMLID is set.
+	XMLID string
+	// Background marks a whispered/background word inside a lead line
+	// (amll:bg="true" in TTML), as opposed to a whole IsBG line.
+	Background bool
+	// InstrumentalMarker marks an intentionally empty timed word, such as a
+	// placeholder for an instrumental section (an empty timed span in TTML,
+	// read only with ParseOptions.InstrumentalMarkers). Markers are never
+	// IsBlank, so they keep their timing and are kept on export.
+	InstrumentalMarker bool
+}
+
+// LineRole classifies the vocal part a line carries.
+type LineRole uint8
+
+const (
+	// LineRoleLead is the main vocal and the zero value.
+	LineRoleLead LineRole = iota
+	// LineRoleBackground is a background vocal. It is derived from IsBG by
+	// EffectiveRole and is not a valid value for LyricLine.Role.
+	LineRoleBackground
+	// LineRoleHarmony is a harmony part, ttm:role="x-harmony" in TTML.
+	LineRoleHarmony
+	// LineRoleAdLib is an ad-lib ("oh", "ah", ...), ttm:role="x-adlib" in TTML.
+	LineRoleAdLib
+)
+
+// String returns the role name, e.g. "adlib".
+func (r LineRole) String() string {
+	switch r {
+	case LineRoleLead:
+		return "lead"
+	case LineRoleBackground:
+		return "background"
+	case LineRoleHarmony:
+		return "harmony"
+	case LineRoleAdLib:
+		return "adlib"
+	default:
+		return "LineRole(" + strconv.Itoa(int(r)) + ")"
+	}
 }
 
 // LyricLine represents a single lyric line.
@@ -37,12 +119,101 @@ type LyricLine struct {
 	ID              string
 	Words           []LyricWord
 	TranslatedLyric string
+	// TranslatedWords optionally times the translation segment by segment.
+	// When set the TTML writer emits them as timed spans and TranslatedLyric
+	// is only the plain-text fallback. AMLX does not store them.
+	TranslatedWords []LyricWord
 	RomanLyric      string
 	IsBG            bool
 	IsDuet          bool
 	StartTime       float64
 	EndTime         float64
 	IgnoreSync      bool
+	// XMLID is the source element's xml:id, kept when ParseOptions.PreserveXMLID is set.
+	XMLID string
+	// ITunesKey is the source paragraph's itunes:key, kept on main lines when
+	// ParseOptions.PreserveITunesKey is set. The writer reuses it so the for=
+	// references of the iTunes translation and transliteration blocks stay
+	// stable when lines are added or removed. AMLX does not store it.
+	ITunesKey string
+	// Role is the harmony/ad-lib refinement of a lead line. IsBG stays the
+	// stored background marker rather than being derived from Role, because
+	// it is an exported field that callers and the rest of the package read
+	// and set directly; use EffectiveRole to read the two together. Role
+	// must be LineRoleLead on IsBG lines: a background line cannot also be a
+	// harmony or ad-lib part, and EncodeBinary and Validate reject it.
+	Role LineRole
+	// RawSpans holds, as written in the source, the spans whose ttm:role the
+	// parser does not understand, kept when ParseOptions.PreserveUnknownRoles
+	// is set. The writer re-emits them verbatim at the end of the line,
+	// without applying ExportOptions.NamespacePrefixes. AMLX does not store
+	// them.
+	RawSpans []string
+}
+
+// EffectiveRole returns Role, reporting IsBG lines whose Role is left as
+// LineRoleLead as LineRoleBackground.
+func (l LyricLine) EffectiveRole() LineRole {
+	if l.IsBG && l.Role == LineRoleLead {
+		return LineRoleBackground
+	}
+	return l.Role
+}
+
+// roleProblem describes why Role cannot be stored on the line, or returns "".
+func (l LyricLine) roleProblem() string {
+	switch {
+	case l.Role == LineRoleBackground:
+		return "role background is expressed by IsBG, not Role"
+	case l.Role > LineRoleAdLib:
+		return "unknown role " + l.Role.String()
+	case l.IsBG && l.Role != LineRoleLead:
+		return "background line cannot have role " + l.Role.String()
+	}
+	return ""
+}
+
+// Duration returns EndTime-StartTime, clamped at zero like the AMLX encoder
+// does for words that end before they start.
+func (w LyricWord) Duration() float64 {
+	return clampDuration(w.StartTime, w.EndTime)
+}
+
+// Duration returns EndTime-StartTime, clamped at zero.
+func (l LyricLine) Duration() float64 {
+	return clampDuration(l.StartTime, l.EndTime)
+}
+
+// TimingMode classifies the line with the writer's itunes:timing heuristic:
+// "None" when no non-blank word has a positive duration, "Word" when it has
+// more than one non-blank word, and "Line" otherwise.
+func (l LyricLine) TimingMode() string {
+	count := 0
+	timed := false
+	for _, word := range l.Words {
+		if word.IsBlank() {
+			continue
+		}
+		count++
+		if word.EndTime > word.StartTime {
+			timed = true
+		}
+	}
+	switch {
+	case !timed:
+		return "None"
+	case count > 1:
+		return "Word"
+	}
+	return "Line"
+}
+
+func clampDuration(start, end float64) float64 {
+	// Written so that NaN times also yield zero.
+	if d := end - start; d > 0 {
+		return d
+	}
+	return 0
 }
 
 var uidCounter uint64
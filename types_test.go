@@ -0,0 +1,45 @@
+package ttml
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDuration(t *testing.T) {
+	cases := []struct {
+		start, end, want float64
+	}{
+		{1000, 1500, 500},
+		{1000, 1000, 0},
+		{1500, 1000, 0},
+		{math.NaN(), 1000, 0},
+	}
+	for _, c := range cases {
+		word := LyricWord{StartTime: c.start, EndTime: c.end}
+		if got := word.Duration(); got != c.want {
+			t.Fatalf("word %v-%v: expected %v, got %v", c.start, c.end, c.want, got)
+		}
+		line := LyricLine{StartTime: c.start, EndTime: c.end}
+		if got := line.Duration(); got != c.want {
+			t.Fatalf("line %v-%v: expected %v, got %v", c.start, c.end, c.want, got)
+		}
+	}
+}
+
+func TestLineTimingMode(t *testing.T) {
+	cases := []struct {
+		words []LyricWord
+		want  string
+	}{
+		{nil, "None"},
+		{[]LyricWord{{Word: "untimed"}, {Word: " "}, {Word: "line"}}, "None"},
+		{[]LyricWord{{StartTime: 1000, EndTime: 2000, Word: "chorus line"}}, "Line"},
+		{[]LyricWord{{Word: " "}, {StartTime: 1000, EndTime: 2000, Word: "chorus"}, {Word: " "}}, "Line"},
+		{[]LyricWord{{StartTime: 1000, EndTime: 1500, Word: "word"}, {Word: " "}, {StartTime: 1500, EndTime: 2000, Word: "synced"}}, "Word"},
+	}
+	for _, c := range cases {
+		if got := (LyricLine{Words: c.words}).TimingMode(); got != c.want {
+			t.Fatalf("%v: expected %s, got %s", c.words, c.want, got)
+		}
+	}
+}
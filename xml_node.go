@@ -2,8 +2,13 @@ package ttml
 
 import (
 	"encoding/xml"
+	"fmt"
 	"io"
+	"regexp"
 	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
 )
 
 type nodeType int
@@ -12,6 +17,8 @@ const (
 	nodeDocument nodeType = iota
 	nodeElement
 	nodeText
+	// nodeRaw is markup written out verbatim.
+	nodeRaw
 )
 
 type xmlAttr struct {
@@ -134,8 +141,42 @@ func (n *xmlNode) innerXML() string {
 	return sb.String()
 }
 
+// xmlDeclEncoding captures the encoding label of an XML declaration.
+var xmlDeclEncoding = regexp.MustCompile(`^\x{feff}?\s*<\?xml\s[^>]*?\bencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// decodeCharset transcodes input to UTF-8 from enc or, when enc is nil, from
+// the non-UTF-8 encoding named by its XML declaration. Input without such a
+// declaration is returned unchanged.
+func decodeCharset(input string, enc encoding.Encoding) (string, error) {
+	if enc == nil {
+		match := xmlDeclEncoding.FindStringSubmatch(input)
+		if match == nil {
+			return input, nil
+		}
+		declared, err := htmlindex.Get(match[1])
+		if err != nil {
+			return "", fmt.Errorf("不支持的字符编码 %q：%w", match[1], err)
+		}
+		if name, _ := htmlindex.Name(declared); name == "utf-8" {
+			return input, nil
+		}
+		enc = declared
+	}
+	decoded, err := enc.NewDecoder().String(input)
+	if err != nil {
+		return "", fmt.Errorf("字符编码转换失败：%w", err)
+	}
+	return decoded, nil
+}
+
+// parseXMLDocument parses UTF-8 input; use decodeCharset first for other
+// encodings. A declared encoding is assumed to be applied already.
 func parseXMLDocument(input string) (*xmlNode, error) {
 	decoder := xml.NewDecoder(strings.NewReader(input))
+	decoder.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	// Hand-written TTML often uses HTML entities such as &nbsp; or &rsquo;,
+	// which are not predefined in XML and would otherwise abort the parse.
+	decoder.Entity = xml.HTMLEntity
 	doc := &xmlNode{Type: nodeDocument}
 
 	stack := []*xmlNode{doc}
@@ -242,15 +283,18 @@ func isNamespaceDecl(attr xml.Attr) bool {
 }
 
 func prefixForURI(uri string, scope map[string]string) string {
-	if uri == "" {
+	if uri == "" || scope[""] == uri {
 		return ""
 	}
+	// A URI may be bound to several prefixes; pick the smallest so the
+	// result does not depend on map order.
+	found, best := false, ""
 	for prefix, space := range scope {
-		if space == uri {
-			return prefix
+		if space == uri && (!found || prefix < best) {
+			found, best = true, prefix
 		}
 	}
-	return ""
+	return best
 }
 
 func qualifyName(prefix, local string) string {
@@ -260,92 +304,98 @@ func qualifyName(prefix, local string) string {
 	return prefix + ":" + local
 }
 
-func serializeNode(sb *strings.Builder, node *xmlNode, pretty bool, depth int) {
+func serializeNode(w io.StringWriter, node *xmlNode, pretty bool, depth int) {
 	switch node.Type {
 	case nodeDocument:
 		for _, child := range node.Children {
-			serializeNode(sb, child, pretty, depth)
+			serializeNode(w, child, pretty, depth)
 		}
 	case nodeText:
-		if pretty && strings.TrimSpace(node.Text) == "" {
-			return
-		}
-		sb.WriteString(escapeText(node.Text))
+		w.WriteString(escapeText(node.Text))
+	case nodeRaw:
+		w.WriteString(node.Text)
 	case nodeElement:
-		sb.WriteString("<")
-		sb.WriteString(node.Name)
+		w.WriteString("<")
+		w.WriteString(node.Name)
 		for _, attr := range node.Attrs {
-			sb.WriteString(" ")
-			sb.WriteString(attr.Name)
-			sb.WriteString(`="`)
-			sb.WriteString(escapeAttr(attr.Value))
-			sb.WriteString(`"`)
+			w.WriteString(" ")
+			w.WriteString(attr.Name)
+			w.WriteString(`="`)
+			w.WriteString(escapeAttr(attr.Value))
+			w.WriteString(`"`)
 		}
 		if len(node.Children) == 0 {
-			sb.WriteString("/>")
+			w.WriteString("/>")
 			return
 		}
-		sb.WriteString(">")
+		w.WriteString(">")
 
 		indent := pretty && shouldIndent(node)
 		if indent {
-			sb.WriteString("\n")
+			w.WriteString("\n")
 		}
 		for _, child := range node.Children {
 			if indent {
-				sb.WriteString(strings.Repeat("  ", depth+1))
+				w.WriteString(strings.Repeat("  ", depth+1))
 			}
-			serializeNode(sb, child, pretty, depth+1)
+			serializeNode(w, child, pretty, depth+1)
 			if indent {
-				sb.WriteString("\n")
+				w.WriteString("\n")
 			}
 		}
 		if indent {
-			sb.WriteString(strings.Repeat("  ", depth))
+			w.WriteString(strings.Repeat("  ", depth))
 		}
-		sb.WriteString("</")
-		sb.WriteString(node.Name)
-		sb.WriteString(">")
+		w.WriteString("</")
+		w.WriteString(node.Name)
+		w.WriteString(">")
 	}
 }
 
 func shouldIndent(node *xmlNode) bool {
+	if space, _ := node.attrValue("xml:space"); space == "preserve" {
+		return false
+	}
 	hasElement := false
 	for _, child := range node.Children {
-		if child.Type == nodeElement {
+		if child.Type == nodeElement || child.Type == nodeRaw {
 			hasElement = true
 		}
 		if child.Type == nodeText {
-			if strings.TrimSpace(child.Text) != "" {
-				// Significant text content disables indentation to avoid changing mixed content.
-				return false
-			}
+			// Any text, even whitespace between words, is content; indenting
+			// around it would change the words on re-parse.
+			return false
 		}
 	}
 	return hasElement
 }
 
+// The replacers are built once; constructing one per call dominated the
+// export time of long documents.
+var (
+	textEscaper = strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+	)
+	attrEscaper = strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+		`"`, "&quot;",
+	)
+)
+
 func escapeText(input string) string {
 	if input == "" {
 		return ""
 	}
-	replacer := strings.NewReplacer(
-		"&", "&amp;",
-		"<", "&lt;",
-	)
-	return replacer.Replace(input)
+	return textEscaper.Replace(input)
 }
 
 func escapeAttr(input string) string {
 	if input == "" {
 		return ""
 	}
-	replacer := strings.NewReplacer(
-		"&", "&amp;",
-		"<", "&lt;",
-		`"`, "&quot;",
-	)
-	return replacer.Replace(input)
+	return attrEscaper.Replace(input)
 }
 
 func findElementsByPath(root *xmlNode, path []string) []*xmlNode {